@@ -0,0 +1,145 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BucketStart returns the start of the interval-long bucket containing t.
+// Buckets are aligned to the Unix epoch, so they line up across sensors and queries.
+func BucketStart(t time.Time, interval time.Duration) time.Time {
+	return t.Truncate(interval).UTC()
+}
+
+// AddToSummary folds one reading into a bucket summary.
+func AddToSummary(summary *models.VibrationSummary, v models.VibrationData) {
+	if summary.Channels == nil {
+		summary.Channels = map[string]models.ChannelSummary{}
+	}
+
+	for _, channel := range models.VibrationChannels {
+		value := float64(channel.Value(v))
+		current, seen := summary.Channels[channel.Field]
+		if !seen || value < current.Min {
+			current.Min = value
+		}
+		if !seen || value > current.Max {
+			current.Max = value
+		}
+		current.Sum += value
+		current.SumSq += value * value
+		summary.Channels[channel.Field] = current
+	}
+
+	summary.Count++
+	if v.WarnLevel > summary.MaxWarnLevel {
+		summary.MaxWarnLevel = v.WarnLevel
+	}
+}
+
+// MergeSummary folds the totals of another bucket summary into summary.
+func MergeSummary(summary *models.VibrationSummary, other models.VibrationSummary) {
+	if other.Count == 0 {
+		return
+	}
+	if summary.Channels == nil {
+		summary.Channels = map[string]models.ChannelSummary{}
+	}
+
+	for field, theirs := range other.Channels {
+		ours, seen := summary.Channels[field]
+		if !seen || summary.Count == 0 {
+			summary.Channels[field] = theirs
+			continue
+		}
+		ours.Min = math.Min(ours.Min, theirs.Min)
+		ours.Max = math.Max(ours.Max, theirs.Max)
+		ours.Sum += theirs.Sum
+		ours.SumSq += theirs.SumSq
+		summary.Channels[field] = ours
+	}
+
+	summary.Count += other.Count
+	if other.MaxWarnLevel > summary.MaxWarnLevel {
+		summary.MaxWarnLevel = other.MaxWarnLevel
+	}
+}
+
+// BucketStats derives the requested metrics of every channel from a bucket summary.
+func BucketStats(summary models.VibrationSummary, metrics []string) models.VibrationBucket {
+	bucket := models.VibrationBucket{
+		Start:        summary.Start,
+		Count:        summary.Count,
+		MaxWarnLevel: summary.MaxWarnLevel,
+		Stats:        map[string]map[string]models.ChannelStats{},
+	}
+
+	for _, channel := range models.VibrationChannels {
+		totals := summary.Channels[channel.Field]
+		var stats models.ChannelStats
+		for _, metric := range metrics {
+			switch metric {
+			case models.MetricRMS:
+				stats.RMS = ratio(totals.SumSq, summary.Count, math.Sqrt)
+			case models.MetricAvg:
+				stats.Avg = ratio(totals.Sum, summary.Count, nil)
+			case models.MetricMax:
+				stats.Max = &totals.Max
+			case models.MetricMin:
+				stats.Min = &totals.Min
+			}
+		}
+
+		if bucket.Stats[channel.Unit] == nil {
+			bucket.Stats[channel.Unit] = map[string]models.ChannelStats{}
+		}
+		bucket.Stats[channel.Unit][channel.Axis] = stats
+	}
+
+	return bucket
+}
+
+// ratio returns total/count, optionally transformed, or nil for an empty bucket.
+func ratio(total float64, count int64, transform func(float64) float64) *float64 {
+	if count == 0 {
+		return nil
+	}
+	value := total / float64(count)
+	if transform != nil {
+		value = transform(value)
+	}
+	return &value
+}
+
+// MergeSummaries combines summaries per sensor into interval-long buckets,
+// ordered by bucket start and then sensor.
+func MergeSummaries(summaries []models.VibrationSummary, interval time.Duration) []models.VibrationSummary {
+	type key struct {
+		sensorID primitive.ObjectID
+		start    time.Time
+	}
+	buckets := map[key]*models.VibrationSummary{}
+	for _, summary := range summaries {
+		k := key{summary.SensorID, BucketStart(summary.Start, interval)}
+		if buckets[k] == nil {
+			buckets[k] = &models.VibrationSummary{SensorID: k.sensorID, Start: k.start}
+		}
+		MergeSummary(buckets[k], summary)
+	}
+
+	results := make([]models.VibrationSummary, 0, len(buckets))
+	for _, bucket := range buckets {
+		results = append(results, *bucket)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if !results[i].Start.Equal(results[j].Start) {
+			return results[i].Start.Before(results[j].Start)
+		}
+		return results[i].SensorID.Hex() < results[j].SensorID.Hex()
+	})
+	return results
+}
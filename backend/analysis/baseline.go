@@ -0,0 +1,154 @@
+package analysis
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// minBaselineStd floors the standard deviation in mm/s so a perfectly steady training
+// window does not turn every small change into an infinite score.
+const minBaselineStd = 0.01
+
+// DefaultBaselineSettings fills in what a retrain or reset request leaves out.
+var DefaultBaselineSettings = models.BaselineSettings{
+	Method:     models.BaselineMethodMeanStd,
+	Alpha:      0.01,
+	MinSamples: 30,
+	ScoreLimit: 3,
+	AlertAfter: 5,
+}
+
+// ValidateBaselineSettings checks a method and the ranges of the numeric settings.
+func ValidateBaselineSettings(settings models.BaselineSettings) error {
+	switch settings.Method {
+	case models.BaselineMethodMeanStd, models.BaselineMethodEWMA:
+	default:
+		return errors.New("method must be mean_std or ewma")
+	}
+
+	switch {
+	case settings.Alpha <= 0 || settings.Alpha >= 1:
+		return errors.New("alpha must be between 0 and 1")
+	case settings.MinSamples < 2:
+		return errors.New("min_samples must be at least 2")
+	case settings.ScoreLimit <= 0:
+		return errors.New("score_limit must be positive")
+	case settings.AlertAfter < 1:
+		return errors.New("alert_after must be at least 1")
+	}
+	return nil
+}
+
+// NewBaseline returns an untrained baseline that learns until learnUntil.
+func NewBaseline(sensorID primitive.ObjectID, settings models.BaselineSettings, learnUntil time.Time) models.Baseline {
+	buckets := 1
+	if settings.ByHour {
+		buckets = 24
+	}
+	return models.Baseline{
+		SensorID:         sensorID,
+		BaselineSettings: settings,
+		Status:           models.BaselineStatusLearning,
+		LearnUntil:       learnUntil,
+		Buckets:          make([]models.BaselineBucket, buckets),
+	}
+}
+
+// baselineAxis pairs the statistics of one axis with a reading's velocity on it.
+type baselineAxis struct {
+	stats *models.BaselineStats
+	value float64
+}
+
+// baselineAxes pairs each axis's statistics in bucket with its velocity in v.
+func baselineAxes(bucket *models.BaselineBucket, v models.VibrationData) []baselineAxis {
+	return []baselineAxis{
+		{&bucket.X, float64(v.X_Axismm_s)},
+		{&bucket.Y, float64(v.Y_Axismm_s)},
+		{&bucket.Z, float64(v.Z_Axismm_s)},
+	}
+}
+
+// baselineBucket returns the bucket covering t: the only one, or that of its UTC hour.
+func baselineBucket(b *models.Baseline, t time.Time) *models.BaselineBucket {
+	if b.ByHour && len(b.Buckets) == 24 {
+		return &b.Buckets[t.UTC().Hour()]
+	}
+	return &b.Buckets[0]
+}
+
+// TrainBaseline folds a reading into the statistics of its bucket.
+func TrainBaseline(b *models.Baseline, v models.VibrationData) {
+	for _, axis := range baselineAxes(baselineBucket(b, v.Timestamp), v) {
+		updateStats(axis.stats, axis.value, b.Method, b.Alpha)
+	}
+}
+
+func updateStats(stats *models.BaselineStats, x float64, method string, alpha float64) {
+	stats.Count++
+	if stats.Count == 1 {
+		stats.Mean, stats.Variance = x, 0
+		return
+	}
+
+	delta := x - stats.Mean
+	if method == models.BaselineMethodEWMA {
+		increment := alpha * delta
+		stats.Mean += increment
+		stats.Variance = (1 - alpha) * (stats.Variance + delta*increment)
+		return
+	}
+
+	// Welford's update of the mean and population variance
+	stats.Mean += delta / float64(stats.Count)
+	stats.Variance += (delta*(x-stats.Mean) - stats.Variance) / float64(stats.Count)
+}
+
+// ScoreReading returns the largest deviation of any axis from its bucket mean, in
+// standard deviations. ok is false when the bucket has too few samples to judge.
+func ScoreReading(b models.Baseline, v models.VibrationData) (score float64, ok bool) {
+	for _, axis := range baselineAxes(baselineBucket(&b, v.Timestamp), v) {
+		if axis.stats.Count < b.MinSamples {
+			return 0, false
+		}
+		std := math.Max(math.Sqrt(axis.stats.Variance), minBaselineStd)
+		score = math.Max(score, math.Abs(axis.value-axis.stats.Mean)/std)
+	}
+	return score, true
+}
+
+// ObserveReading advances a baseline by one reading. While learning it trains on the
+// reading, switching to ready once the reading is past LearnUntil. When ready it scores
+// the reading and tracks the streak of abnormal readings; an EWMA baseline also keeps
+// learning from normal readings. It returns the score, nil when none could be given,
+// and whether the streak has reached AlertAfter.
+func ObserveReading(b *models.Baseline, v models.VibrationData, now time.Time) (*float64, bool) {
+	b.UpdatedAt = now
+	if b.Status == models.BaselineStatusLearning {
+		if v.Timestamp.Before(b.LearnUntil) {
+			TrainBaseline(b, v)
+			return nil, false
+		}
+		b.Status = models.BaselineStatusReady
+		b.TrainedAt = now
+	}
+
+	score, ok := ScoreReading(*b, v)
+	if !ok {
+		return nil, false
+	}
+
+	if score > b.ScoreLimit {
+		b.Streak++
+	} else {
+		b.Streak = 0
+		if b.Method == models.BaselineMethodEWMA {
+			TrainBaseline(b, v)
+		}
+	}
+	return &score, b.Streak >= b.AlertAfter
+}
@@ -0,0 +1,103 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestUpdateStats(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		values   []float64
+		mean     float64
+		variance float64
+	}{
+		{name: "mean/std constant", method: models.BaselineMethodMeanStd, values: []float64{3, 3, 3, 3, 3}, mean: 3},
+		{name: "ewma constant", method: models.BaselineMethodEWMA, values: []float64{3, 3, 3, 3, 3}, mean: 3},
+		{name: "single value", method: models.BaselineMethodMeanStd, values: []float64{7}, mean: 7},
+		// The textbook example with population standard deviation 2
+		{name: "mean/std known answer", method: models.BaselineMethodMeanStd, values: []float64{2, 4, 4, 4, 5, 5, 7, 9}, mean: 5, variance: 4},
+		// With alpha 0.5 after 0 then 2: the mean moves halfway and the variance is (1-α)·α·δ²
+		{name: "ewma known answer", method: models.BaselineMethodEWMA, values: []float64{0, 2}, mean: 1, variance: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stats models.BaselineStats
+			for _, value := range tt.values {
+				updateStats(&stats, value, tt.method, 0.5)
+			}
+			if stats.Count != int64(len(tt.values)) || !near(stats.Mean, tt.mean, 1e-12) || !near(stats.Variance, tt.variance, 1e-12) {
+				t.Errorf("count %d, mean %g, variance %g; want %d, %g, %g", stats.Count, stats.Mean, stats.Variance, len(tt.values), tt.mean, tt.variance)
+			}
+		})
+	}
+}
+
+// steady returns a reading at t with the same velocity on every axis.
+func steady(t time.Time, velocity float32) models.VibrationData {
+	return models.VibrationData{Timestamp: t, X_Axismm_s: velocity, Y_Axismm_s: velocity, Z_Axismm_s: velocity}
+}
+
+func TestObserveReadingOfConstantInput(t *testing.T) {
+	for _, method := range []string{models.BaselineMethodMeanStd, models.BaselineMethodEWMA} {
+		t.Run(method, func(t *testing.T) {
+			start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			settings := DefaultBaselineSettings
+			settings.Method = method
+			b := NewBaseline(primitive.NewObjectID(), settings, start.Add(time.Hour))
+
+			// Learn for an hour of identical readings, then keep seeing them
+			for i := 0; i < 120; i++ {
+				at := start.Add(time.Duration(i) * time.Minute)
+				score, alert := ObserveReading(&b, steady(at, 2.5), at)
+				if alert {
+					t.Fatalf("alert on a steady reading at minute %d", i)
+				}
+				if at.Before(b.LearnUntil) {
+					continue
+				}
+				if score == nil || *score != 0 {
+					t.Fatalf("score %v at minute %d, want 0", score, i)
+				}
+			}
+
+			stats := b.Buckets[0].X
+			if b.Status != models.BaselineStatusReady || stats.Mean != 2.5 || stats.Variance != 0 {
+				t.Fatalf("%s baseline with mean %g and variance %g, want ready with 2.5 and 0", b.Status, stats.Mean, stats.Variance)
+			}
+
+			// The variance is zero, so the floor sets the scale: 0.1 mm/s off is 10 std
+			at := start.Add(3 * time.Hour)
+			for i := 1; i <= settings.AlertAfter; i++ {
+				score, alert := ObserveReading(&b, steady(at, 2.6), at)
+				if score == nil || !near(*score, 0.1/minBaselineStd, 1e-5) {
+					t.Fatalf("score %v, want %g", score, 0.1/minBaselineStd)
+				}
+				if alert != (i == settings.AlertAfter) {
+					t.Errorf("alert %t after %d abnormal readings, want it from %d", alert, i, settings.AlertAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestScoreReadingNeedsMinSamples(t *testing.T) {
+	at := time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC)
+	settings := DefaultBaselineSettings
+	settings.ByHour = true
+	b := NewBaseline(primitive.NewObjectID(), settings, at)
+	for i := int64(0); i < settings.MinSamples; i++ {
+		TrainBaseline(&b, steady(at, 1))
+	}
+
+	if _, ok := ScoreReading(b, steady(at, 1)); !ok {
+		t.Error("no score in the trained hour")
+	}
+	if _, ok := ScoreReading(b, steady(at.Add(time.Hour), 1)); ok {
+		t.Error("scored in an hour with no samples")
+	}
+}
@@ -0,0 +1,59 @@
+package analysis
+
+import (
+	"math"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+)
+
+// Multiples of SensorConfig.AlarmThs (mm/s) at which a reading escalates.
+// Reaching AlarmThs itself raises a Warning.
+const (
+	CriticalFactor  = 2.0
+	EmergencyFactor = 4.0
+)
+
+// VelocityMagnitude returns the vector magnitude of the X/Y/Z velocity in mm/s.
+func VelocityMagnitude(v models.VibrationData) float64 {
+	return magnitude(v.X_Axismm_s, v.Y_Axismm_s, v.Z_Axismm_s)
+}
+
+// AccelerationMagnitude returns the vector magnitude of the X/Y/Z acceleration in g.
+func AccelerationMagnitude(v models.VibrationData) float64 {
+	return magnitude(v.X_Axisg, v.Y_Axisg, v.Z_Axisg)
+}
+
+func magnitude(x, y, z float32) float64 {
+	fx, fy, fz := float64(x), float64(y), float64(z)
+	return math.Sqrt(fx*fx + fy*fy + fz*fz)
+}
+
+// ClassifyVibration returns the warning level of a reading under the sensor's configuration.
+func ClassifyVibration(cfg models.SensorConfig, v models.VibrationData) int {
+	return ClassifyMagnitude(cfg, VelocityMagnitude(v), AccelerationMagnitude(v))
+}
+
+// ClassifyMagnitude maps a velocity (mm/s) and acceleration (g) magnitude to a warning level.
+// Velocity is compared to AlarmThs and its Critical/Emergency multiples; an acceleration
+// at or above GMax, the top of the sensor's range, is always an Emergency.
+// Thresholds that are not configured (zero) are skipped.
+func ClassifyMagnitude(cfg models.SensorConfig, velocity, accelerationG float64) int {
+	level := models.WarningLevelNormal
+
+	if ths := float64(cfg.AlarmThs); ths > 0 {
+		switch {
+		case velocity >= ths*EmergencyFactor:
+			level = models.WarningLevelEmergency
+		case velocity >= ths*CriticalFactor:
+			level = models.WarningLevelCritical
+		case velocity >= ths:
+			level = models.WarningLevelWarning
+		}
+	}
+
+	if cfg.GMax > 0 && accelerationG >= float64(cfg.GMax) {
+		level = models.WarningLevelEmergency
+	}
+
+	return level
+}
@@ -0,0 +1,227 @@
+package analysis
+
+import (
+	"errors"
+	"math"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+)
+
+const (
+	// StandardGravity converts acceleration in g to mm/s².
+	StandardGravity = 9806.65
+
+	// velocityLowCutoff drops spectral lines below the ISO 10816 band from velocity RMS;
+	// integrating acceleration amplifies them by 1/f.
+	velocityLowCutoff = 10.0
+
+	// defaultBandCount is the number of equal bands up to FMax used when a sensor sets none.
+	defaultBandCount = 4
+)
+
+// SeriesFeatures computes the features of one axis from its samples. Peak and RMS are
+// taken from the values as given; crest factor is zero for a flat-zero signal.
+func SeriesFeatures(samples []float64) models.AxisFeatures {
+	var features models.AxisFeatures
+	if len(samples) == 0 {
+		return features
+	}
+
+	n := float64(len(samples))
+	lowest, highest := samples[0], samples[0]
+	mean, sumSq := 0.0, 0.0
+	for _, s := range samples {
+		lowest = math.Min(lowest, s)
+		highest = math.Max(highest, s)
+		features.Peak = math.Max(features.Peak, math.Abs(s))
+		mean += s
+		sumSq += s * s
+	}
+	mean /= n
+	features.PeakToPeak = highest - lowest
+	features.RMS = math.Sqrt(sumSq / n)
+	if features.RMS > 0 {
+		features.CrestFactor = features.Peak / features.RMS
+	}
+
+	// Central moments for the shape of the distribution
+	var m2, m3, m4 float64
+	for _, s := range samples {
+		d := s - mean
+		m2 += d * d
+		m3 += d * d * d
+		m4 += d * d * d * d
+	}
+	m2, m3, m4 = m2/n, m3/n, m4/n
+	if m2 > 0 {
+		features.Skewness = m3 / math.Pow(m2, 1.5)
+		features.Kurtosis = m4 / (m2 * m2)
+	}
+	return features
+}
+
+// Bands returns the configured frequency bands of a sensor, or defaultBandCount equal
+// bands up to FMax.
+func Bands(cfg models.SensorConfig) []models.FrequencyBand {
+	if len(cfg.Bands) > 0 {
+		return cfg.Bands
+	}
+
+	width := float64(cfg.FMax) / defaultBandCount
+	bands := make([]models.FrequencyBand, defaultBandCount)
+	for i := range bands {
+		bands[i] = models.FrequencyBand{Low: float64(i) * width, High: float64(i+1) * width}
+	}
+	// Include the line on FMax in the top band
+	bands[defaultBandCount-1].High = math.Nextafter(float64(cfg.FMax), math.Inf(1))
+	return bands
+}
+
+// ValidateBands checks that every band is a non-empty, non-negative frequency range.
+func ValidateBands(bands []models.FrequencyBand) error {
+	for _, band := range bands {
+		if band.Low < 0 || band.High <= band.Low {
+			return errors.New("Each band needs 0 <= low < high")
+		}
+	}
+	return nil
+}
+
+// BandEnergies sums the power (mean square, unit²) of the bins in each band.
+func BandEnergies(power []float64, binWidth float64, bands []models.FrequencyBand) []float64 {
+	energies := make([]float64, len(bands))
+	for bin, p := range power {
+		frequency := float64(bin) * binWidth
+		for i, band := range bands {
+			if frequency >= band.Low && frequency < band.High {
+				energies[i] += p
+			}
+		}
+	}
+	return energies
+}
+
+// VelocityRMS integrates an acceleration power spectrum in g² to the overall velocity
+// RMS in mm/s, over bins at or above velocityLowCutoff.
+func VelocityRMS(power []float64, binWidth float64) float64 {
+	energy := 0.0
+	for bin, p := range power {
+		frequency := float64(bin) * binWidth
+		if frequency < velocityLowCutoff {
+			continue
+		}
+		scale := StandardGravity / (2 * math.Pi * frequency)
+		energy += p * scale * scale
+	}
+	return math.Sqrt(energy)
+}
+
+// WaveformFeatures computes the features of every captured axis of a waveform in g,
+// with band energies and velocity taken from its power spectrum.
+func WaveformFeatures(cfg models.SensorConfig, w models.Waveform) models.FeatureSet {
+	features := models.FeatureSet{
+		SensorID:   w.SensorID,
+		Source:     models.FeatureSourceWaveform,
+		WaveformID: w.ID,
+		Timestamp:  w.Timestamp,
+		Unit:       "g",
+		Samples:    w.Samples,
+		Bands:      Bands(cfg),
+		Axes:       map[string]models.AxisFeatures{},
+	}
+
+	axes := []struct {
+		name    string
+		samples []float32
+	}{
+		{"x", w.X},
+		{"y", w.Y},
+		{"z", w.Z},
+	}
+
+	var velocity, acceleration [3]float64
+	for i, axis := range axes {
+		if len(axis.samples) == 0 {
+			continue
+		}
+
+		// Features describe the vibration around the static offset, as the spectrum does
+		axisFeatures := SeriesFeatures(removeMean(axis.samples))
+		power, binWidth := powerSpectrum(axis.samples, w.SampleRate)
+		axisFeatures.BandEnergies = BandEnergies(power, binWidth, features.Bands)
+		features.Axes[axis.name] = axisFeatures
+
+		velocity[i] = VelocityRMS(power, binWidth)
+		acceleration[i] = axisFeatures.Peak
+	}
+
+	features.Velocity = vectorLength(velocity)
+	features.Acceleration = vectorLength(acceleration)
+	features.WarnLevel = ClassifyMagnitude(cfg, features.Velocity, features.Acceleration)
+	return features
+}
+
+// WindowFeatures computes the features of a window of scalar readings from their
+// X/Y/Z velocity in mm/s. Readings must be in time order.
+func WindowFeatures(cfg models.SensorConfig, readings []models.VibrationData) (models.FeatureSet, error) {
+	if len(readings) < 2 {
+		return models.FeatureSet{}, errors.New("A feature window needs at least 2 readings")
+	}
+
+	from := readings[0].Timestamp
+	features := models.FeatureSet{
+		SensorID:  readings[0].SensorID,
+		Source:    models.FeatureSourceWindow,
+		Timestamp: readings[len(readings)-1].Timestamp,
+		From:      &from,
+		Unit:      "mm_s",
+		Samples:   len(readings),
+		Axes:      map[string]models.AxisFeatures{},
+	}
+
+	axes := []struct {
+		name         string
+		velocity     func(models.VibrationData) float32
+		acceleration func(models.VibrationData) float32
+	}{
+		{"x", func(v models.VibrationData) float32 { return v.X_Axismm_s }, func(v models.VibrationData) float32 { return v.X_Axisg }},
+		{"y", func(v models.VibrationData) float32 { return v.Y_Axismm_s }, func(v models.VibrationData) float32 { return v.Y_Axisg }},
+		{"z", func(v models.VibrationData) float32 { return v.Z_Axismm_s }, func(v models.VibrationData) float32 { return v.Z_Axisg }},
+	}
+
+	var velocity, acceleration [3]float64
+	for i, axis := range axes {
+		samples := make([]float64, len(readings))
+		for j, reading := range readings {
+			samples[j] = float64(axis.velocity(reading))
+			acceleration[i] = math.Max(acceleration[i], math.Abs(float64(axis.acceleration(reading))))
+		}
+
+		axisFeatures := SeriesFeatures(samples)
+		features.Axes[axis.name] = axisFeatures
+		velocity[i] = axisFeatures.RMS
+	}
+
+	features.Velocity = vectorLength(velocity)
+	features.Acceleration = vectorLength(acceleration)
+	features.WarnLevel = ClassifyMagnitude(cfg, features.Velocity, features.Acceleration)
+	return features, nil
+}
+
+func removeMean(samples []float32) []float64 {
+	mean := 0.0
+	for _, s := range samples {
+		mean += float64(s)
+	}
+	mean /= float64(len(samples))
+
+	centred := make([]float64, len(samples))
+	for i, s := range samples {
+		centred[i] = float64(s) - mean
+	}
+	return centred
+}
+
+func vectorLength(v [3]float64) float64 {
+	return math.Sqrt(v[0]*v[0] + v[1]*v[1] + v[2]*v[2])
+}
@@ -0,0 +1,169 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+)
+
+// near reports whether got is within tolerance of want, relative to want when it is not zero.
+func near(got, want, tolerance float64) bool {
+	if want == 0 {
+		return math.Abs(got) <= tolerance
+	}
+	return math.Abs(got-want) <= tolerance*math.Abs(want)
+}
+
+func TestSeriesFeatures(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []float64
+		want    models.AxisFeatures
+	}{
+		{name: "empty"},
+		{name: "flat zero", samples: []float64{0, 0, 0, 0}},
+		{
+			name:    "square wave",
+			samples: []float64{1, -1, 1, -1},
+			want:    models.AxisFeatures{Peak: 1, PeakToPeak: 2, RMS: 1, CrestFactor: 1, Kurtosis: 1},
+		},
+		{
+			name:    "single spike",
+			samples: []float64{0, 0, 0, 4},
+			// Deviations from the mean of 1 give central moments m2 = 3, m3 = 6, m4 = 21
+			want: models.AxisFeatures{Peak: 4, PeakToPeak: 4, RMS: 2, CrestFactor: 2, Skewness: 6 / math.Pow(3, 1.5), Kurtosis: 21.0 / 9},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SeriesFeatures(tt.samples)
+			fields := []struct {
+				name      string
+				got, want float64
+			}{
+				{"peak", got.Peak, tt.want.Peak},
+				{"peak to peak", got.PeakToPeak, tt.want.PeakToPeak},
+				{"RMS", got.RMS, tt.want.RMS},
+				{"crest factor", got.CrestFactor, tt.want.CrestFactor},
+				{"skewness", got.Skewness, tt.want.Skewness},
+				{"kurtosis", got.Kurtosis, tt.want.Kurtosis},
+			}
+			for _, field := range fields {
+				if !near(field.got, field.want, 1e-9) {
+					t.Errorf("%s %g, want %g", field.name, field.got, field.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBandEnergies(t *testing.T) {
+	// One unit of power per bin, 1 Hz apart: 0 Hz to 8 Hz
+	power := []float64{1, 1, 1, 1, 1, 1, 1, 1, 1}
+	tests := []struct {
+		name  string
+		bands []models.FrequencyBand
+		want  []float64
+	}{
+		{name: "low edge in, high edge out", bands: []models.FrequencyBand{{Low: 0, High: 2}, {Low: 2, High: 4}}, want: []float64{2, 2}},
+		{name: "overlapping bands both count", bands: []models.FrequencyBand{{Low: 1, High: 5}, {Low: 3, High: 6}}, want: []float64{4, 3}},
+		{name: "beyond the spectrum", bands: []models.FrequencyBand{{Low: 20, High: 30}}, want: []float64{0}},
+		{name: "default bands up to fmax", bands: Bands(models.SensorConfig{FMax: 8}), want: []float64{2, 2, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BandEnergies(power, 1, tt.bands)
+			if len(got) != len(tt.want) {
+				t.Fatalf("%d energies, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("band %d energy %g, want %g", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestVelocityRMS(t *testing.T) {
+	// A 1 g peak sine has a mean square of 0.5 g²; at f Hz its velocity RMS is
+	// g/(2πf)/√2 in mm/s
+	velocity := func(frequency float64) float64 {
+		return StandardGravity / (2 * math.Pi * frequency) / math.Sqrt2
+	}
+	tests := []struct {
+		name  string
+		power []float64
+		width float64
+		want  float64
+	}{
+		{name: "no power", power: make([]float64, 101), width: 1},
+		{name: "one bin", power: bin(101, 100, 0.5), width: 1, want: velocity(100)},
+		{name: "bin width scales frequency", power: bin(101, 50, 0.5), width: 2, want: velocity(100)},
+		{name: "below the low cutoff", power: bin(101, 5, 0.5), width: 1},
+		{name: "two bins add in quadrature", power: add(bin(101, 20, 0.5), bin(101, 80, 0.5)), width: 1, want: math.Hypot(velocity(20), velocity(80))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VelocityRMS(tt.power, tt.width); !near(got, tt.want, 1e-9) {
+				t.Errorf("velocity RMS %g mm/s, want %g", got, tt.want)
+			}
+		})
+	}
+}
+
+// bin returns a power spectrum of n bins with power p in bin i only.
+func bin(n, i int, p float64) []float64 {
+	power := make([]float64, n)
+	power[i] = p
+	return power
+}
+
+// add returns the bin-wise sum of two power spectra of the same length.
+func add(a, b []float64) []float64 {
+	sum := make([]float64, len(a))
+	for i := range sum {
+		sum[i] = a[i] + b[i]
+	}
+	return sum
+}
+
+func TestWaveformFeaturesOfSine(t *testing.T) {
+	tests := []struct {
+		name      string
+		frequency float64
+		amplitude float64
+		band      int // The default band holding the frequency
+	}{
+		{name: "1 g at 75 Hz", frequency: 75, amplitude: 1, band: 1},
+		{name: "0.5 g at 40 Hz", frequency: 40, amplitude: 0.5, band: 0},
+		{name: "2 g at 180 Hz", frequency: 180, amplitude: 2, band: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := models.Waveform{SampleRate: 1024, X: sine(4096, 1024, tt.frequency, tt.amplitude, 0.3)}
+			if err := ValidateWaveform(&w); err != nil {
+				t.Fatal(err)
+			}
+			features := WaveformFeatures(models.SensorConfig{FMax: 200, LOR: 200}, w)
+
+			x := features.Axes["x"]
+			meanSquare := tt.amplitude * tt.amplitude / 2
+			total := 0.0
+			for _, energy := range x.BandEnergies {
+				total += energy
+			}
+			if !near(x.BandEnergies[tt.band], meanSquare, 0.02) || !near(total, meanSquare, 0.02) {
+				t.Errorf("band energies %v, want %g in band %d", x.BandEnergies, meanSquare, tt.band)
+			}
+			if !near(x.RMS, tt.amplitude/math.Sqrt2, 0.01) || !near(x.Peak, tt.amplitude, 0.01) {
+				t.Errorf("RMS %g and peak %g, want %g and %g without the offset", x.RMS, x.Peak, tt.amplitude/math.Sqrt2, tt.amplitude)
+			}
+			want := tt.amplitude * StandardGravity / (2 * math.Pi * tt.frequency) / math.Sqrt2
+			if !near(features.Velocity, want, 0.02) {
+				t.Errorf("velocity %g mm/s, want %g", features.Velocity, want)
+			}
+		})
+	}
+}
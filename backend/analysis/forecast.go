@@ -0,0 +1,231 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+)
+
+const (
+	// MinForecastPoints is the number of non-empty buckets a trend needs.
+	MinForecastPoints = 3
+
+	// confidenceZ is the two-sided 95% quantile of the normal distribution.
+	confidenceZ = 1.96
+
+	// maxForecastHours caps projections; crossings further out are reported as none.
+	maxForecastHours = 10 * 365 * 24
+)
+
+// ErrTooFewPoints is returned when a window has too few usable buckets to fit a trend.
+var ErrTooFewPoints = fmt.Errorf("At least %d buckets with readings are needed for a forecast", MinForecastPoints)
+
+// ValidateForecastMethod checks a forecast method name.
+func ValidateForecastMethod(method string) error {
+	switch method {
+	case models.ForecastMethodLinear, models.ForecastMethodExponential, models.ForecastMethodRobust:
+		return nil
+	}
+	return errors.New("method must be linear, exponential or robust")
+}
+
+// SummaryVelocityRMS returns the RMS of the velocity magnitude over the readings of a
+// bucket in mm/s, the aggregate counterpart of VelocityMagnitude. ok is false for an empty bucket.
+func SummaryVelocityRMS(summary models.VibrationSummary) (float64, bool) {
+	if summary.Count == 0 {
+		return 0, false
+	}
+
+	sumSq := 0.0
+	for _, channel := range models.VibrationChannels {
+		if channel.Unit == "mm_s" {
+			sumSq += summary.Channels[channel.Field].SumSq
+		}
+	}
+	return math.Sqrt(sumSq / float64(summary.Count)), true
+}
+
+// VelocityThresholds returns the velocity in mm/s at which each warning level above
+// Normal starts under cfg, as ClassifyMagnitude applies them.
+func VelocityThresholds(cfg models.SensorConfig) map[int]float64 {
+	ths := float64(cfg.AlarmThs)
+	return map[int]float64{
+		models.WarningLevelWarning:   ths,
+		models.WarningLevelCritical:  ths * CriticalFactor,
+		models.WarningLevelEmergency: ths * EmergencyFactor,
+	}
+}
+
+// ForecastVelocity fits a trend with method to the velocity RMS of interval-long bucket
+// summaries ending at to, and projects when it reaches each of the sensor's warning
+// thresholds. Each bucket counts as one point at its midpoint. The exponential method
+// skips buckets with zero velocity, which have no logarithm.
+func ForecastVelocity(cfg models.SensorConfig, method string, summaries []models.VibrationSummary, interval time.Duration, to time.Time) (models.Forecast, error) {
+	if err := ValidateForecastMethod(method); err != nil {
+		return models.Forecast{}, err
+	}
+	if cfg.AlarmThs <= 0 {
+		return models.Forecast{}, errors.New("The sensor has no alarm_ths to forecast against")
+	}
+
+	// Fit in hours relative to the end of the window, so the intercept is the current level
+	var xs, ys []float64
+	for _, summary := range summaries {
+		velocity, ok := SummaryVelocityRMS(summary)
+		if !ok || (method == models.ForecastMethodExponential && velocity <= 0) {
+			continue
+		}
+		if method == models.ForecastMethodExponential {
+			velocity = math.Log(velocity)
+		}
+		xs = append(xs, summary.Start.Add(interval/2).Sub(to).Hours())
+		ys = append(ys, velocity)
+	}
+	if len(xs) < MinForecastPoints {
+		return models.Forecast{}, ErrTooFewPoints
+	}
+
+	var intercept, slope, low, high float64
+	if method == models.ForecastMethodRobust {
+		intercept, slope, low, high = theilSen(xs, ys)
+	} else {
+		intercept, slope, low, high = leastSquares(xs, ys)
+	}
+
+	// level maps a velocity into the space the trend was fitted in
+	level := func(velocity float64) float64 { return velocity }
+	current := intercept
+	if method == models.ForecastMethodExponential {
+		level = math.Log
+		current = math.Exp(intercept)
+	}
+
+	forecast := models.Forecast{
+		Method:    method,
+		Interval:  interval.String(),
+		To:        to,
+		Points:    len(xs),
+		Current:   current,
+		Slope:     slope,
+		SlopeLow:  low,
+		SlopeHigh: high,
+	}
+
+	levels := VelocityThresholds(cfg)
+	for _, warnLevel := range []int{models.WarningLevelWarning, models.WarningLevelCritical, models.WarningLevelEmergency} {
+		threshold := models.ThresholdForecast{Level: warnLevel, Velocity: levels[warnLevel]}
+		target := level(threshold.Velocity)
+
+		if intercept >= target {
+			zero := 0.0
+			threshold.Crossed = true
+			threshold.Hours = &zero
+			threshold.At, threshold.Earliest, threshold.Latest = &to, &to, &to
+		} else {
+			threshold.Hours = hoursToReach(intercept, target, slope)
+			threshold.At = projectedTime(to, threshold.Hours)
+			threshold.Earliest = projectedTime(to, hoursToReach(intercept, target, high))
+			threshold.Latest = projectedTime(to, hoursToReach(intercept, target, low))
+		}
+		forecast.Thresholds = append(forecast.Thresholds, threshold)
+	}
+	return forecast, nil
+}
+
+// hoursToReach returns how many hours a trend at level rising by slope per hour takes to
+// reach target, or nil when it does not rise or would take longer than maxForecastHours.
+func hoursToReach(level, target, slope float64) *float64 {
+	if slope <= 0 {
+		return nil
+	}
+	hours := (target - level) / slope
+	if hours > maxForecastHours {
+		return nil
+	}
+	return &hours
+}
+
+func projectedTime(from time.Time, hours *float64) *time.Time {
+	if hours == nil {
+		return nil
+	}
+	t := from.Add(time.Duration(*hours * float64(time.Hour)))
+	return &t
+}
+
+// leastSquares fits y = intercept + slope·x and returns the 95% confidence bounds of the slope.
+func leastSquares(xs, ys []float64) (intercept, slope, low, high float64) {
+	n := float64(len(xs))
+	meanX, meanY := 0.0, 0.0
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= n
+	meanY /= n
+
+	sxx, sxy := 0.0, 0.0
+	for i := range xs {
+		sxx += (xs[i] - meanX) * (xs[i] - meanX)
+		sxy += (xs[i] - meanX) * (ys[i] - meanY)
+	}
+	if sxx == 0 {
+		return meanY, 0, 0, 0
+	}
+	slope = sxy / sxx
+	intercept = meanY - slope*meanX
+
+	sse := 0.0
+	for i := range xs {
+		residual := ys[i] - intercept - slope*xs[i]
+		sse += residual * residual
+	}
+	margin := confidenceZ * math.Sqrt(sse/(n-2)/sxx)
+	return intercept, slope, slope - margin, slope + margin
+}
+
+// theilSen fits the median of the slopes between every pair of points, and the median
+// intercept under that slope. The slope bounds are the order statistics of the pairwise
+// slopes given by Sen's normal approximation at 95%.
+func theilSen(xs, ys []float64) (intercept, slope, low, high float64) {
+	var slopes []float64
+	for i := range xs {
+		for j := i + 1; j < len(xs); j++ {
+			if xs[j] != xs[i] {
+				slopes = append(slopes, (ys[j]-ys[i])/(xs[j]-xs[i]))
+			}
+		}
+	}
+	if len(slopes) == 0 {
+		return median(ys), 0, 0, 0
+	}
+	sort.Float64s(slopes)
+	slope = median(slopes)
+
+	offsets := make([]float64, len(xs))
+	for i := range xs {
+		offsets[i] = ys[i] - slope*xs[i]
+	}
+	intercept = median(offsets)
+
+	n, pairs := float64(len(xs)), float64(len(slopes))
+	spread := confidenceZ * math.Sqrt(n*(n-1)*(2*n+5)/18)
+	lowRank := max(int(math.Floor((pairs-spread)/2)), 0)
+	highRank := min(int(math.Ceil((pairs+spread)/2)), len(slopes)-1)
+	return intercept, slope, slopes[lowRank], slopes[highRank]
+}
+
+// median returns the median of values, sorting a copy.
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
@@ -0,0 +1,162 @@
+package analysis
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+)
+
+func TestTrendFits(t *testing.T) {
+	xs := []float64{-9, -8, -7, -6, -5, -4, -3, -2, -1, 0}
+	line := make([]float64, len(xs))
+	for i, x := range xs {
+		line[i] = 2 + 0.5*x
+	}
+	outlier := append([]float64(nil), line...)
+	outlier[3] = 100
+
+	tests := []struct {
+		name                    string
+		fit                     func(xs, ys []float64) (float64, float64, float64, float64)
+		ys                      []float64
+		intercept, slope        float64
+		exactBounds, wideBounds bool
+	}{
+		{name: "least squares on a line", fit: leastSquares, ys: line, intercept: 2, slope: 0.5, exactBounds: true},
+		{name: "Theil-Sen on a line", fit: theilSen, ys: line, intercept: 2, slope: 0.5, exactBounds: true},
+		{name: "Theil-Sen ignores an outlier", fit: theilSen, ys: outlier, intercept: 2, slope: 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			intercept, slope, low, high := tt.fit(xs, tt.ys)
+			if !near(intercept, tt.intercept, 1e-12) || !near(slope, tt.slope, 1e-12) {
+				t.Errorf("fit %g + %g·x, want %g + %g·x", intercept, slope, tt.intercept, tt.slope)
+			}
+			if low > slope || high < slope {
+				t.Errorf("slope bounds %g to %g exclude the slope %g", low, high, slope)
+			}
+			if tt.exactBounds && (!near(low, slope, 1e-12) || !near(high, slope, 1e-12)) {
+				t.Errorf("slope bounds %g to %g on an exact line, want %g", low, high, slope)
+			}
+		})
+	}
+
+	// The outlier pulls least squares away from the line that Theil-Sen finds
+	if _, slope, _, _ := leastSquares(xs, outlier); near(slope, 0.5, 0.1) {
+		t.Errorf("least squares slope %g with an outlier, expected it to be pulled off 0.5", slope)
+	}
+}
+
+// series returns hourly bucket summaries ending at to whose velocity RMS at each bucket's
+// midpoint, x hours from to, is velocity(x).
+func series(to time.Time, buckets int, velocity func(x float64) float64) []models.VibrationSummary {
+	summaries := make([]models.VibrationSummary, buckets)
+	for i := range summaries {
+		start := to.Add(-time.Duration(buckets-i) * time.Hour)
+		x := start.Add(30 * time.Minute).Sub(to).Hours()
+		summaries[i].Start = start
+		AddToSummary(&summaries[i], models.VibrationData{X_Axismm_s: float32(velocity(x))})
+	}
+	return summaries
+}
+
+func TestForecastVelocity(t *testing.T) {
+	to := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cfg := models.SensorConfig{AlarmThs: 6} // Warning at 6, Critical at 12, Emergency at 24 mm/s
+	hours := func(values ...float64) []*float64 {
+		pointers := make([]*float64, len(values))
+		for i, value := range values {
+			if !math.IsNaN(value) {
+				pointers[i] = &values[i]
+			}
+		}
+		return pointers
+	}
+	none := math.NaN()
+
+	tests := []struct {
+		name     string
+		method   string
+		velocity func(x float64) float64
+		current  float64
+		slope    float64
+		hours    []*float64 // To Warning, Critical and Emergency; nil when never
+		crossed  int        // Thresholds already crossed
+	}{
+		{
+			name: "linear", method: models.ForecastMethodLinear,
+			velocity: func(x float64) float64 { return 4 + 0.25*x },
+			current:  4, slope: 0.25, hours: hours(8, 32, 80),
+		},
+		{
+			name: "robust", method: models.ForecastMethodRobust,
+			velocity: func(x float64) float64 { return 4 + 0.25*x },
+			current:  4, slope: 0.25, hours: hours(8, 32, 80),
+		},
+		{
+			name: "exponential", method: models.ForecastMethodExponential,
+			velocity: func(x float64) float64 { return 3 * math.Exp(0.1*x) },
+			current:  3, slope: 0.1, hours: hours(10*math.Log(2), 10*math.Log(4), 10*math.Log(8)),
+		},
+		{
+			name: "falling never crosses", method: models.ForecastMethodLinear,
+			velocity: func(x float64) float64 { return 4 - 0.1*x },
+			current:  4, slope: -0.1, hours: hours(none, none, none),
+		},
+		{
+			name: "already past Warning", method: models.ForecastMethodLinear,
+			velocity: func(x float64) float64 { return 8 + 0.25*x },
+			current:  8, slope: 0.25, hours: hours(0, 16, 64), crossed: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forecast, err := ForecastVelocity(cfg, tt.method, series(to, 12, tt.velocity), time.Hour, to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if forecast.Points != 12 || !near(forecast.Current, tt.current, 1e-5) || !near(forecast.Slope, tt.slope, 1e-5) {
+				t.Errorf("%d points, current %g, slope %g; want 12, %g, %g", forecast.Points, forecast.Current, forecast.Slope, tt.current, tt.slope)
+			}
+
+			for i, threshold := range forecast.Thresholds {
+				want := tt.hours[i]
+				switch {
+				case threshold.Crossed != (i < tt.crossed):
+					t.Errorf("level %d crossed %t, want %t", threshold.Level, threshold.Crossed, i < tt.crossed)
+				case want == nil && threshold.Hours != nil:
+					t.Errorf("level %d reached in %g hours, want never", threshold.Level, *threshold.Hours)
+				case want != nil && threshold.Hours == nil:
+					t.Errorf("level %d never reached, want in %g hours", threshold.Level, *want)
+				case want != nil && !near(*threshold.Hours, *want, 1e-4):
+					t.Errorf("level %d reached in %g hours, want %g", threshold.Level, *threshold.Hours, *want)
+				case want != nil && !threshold.At.Equal(to.Add(time.Duration(*threshold.Hours*float64(time.Hour)))):
+					t.Errorf("level %d reached at %s, %g hours after %s", threshold.Level, threshold.At, *threshold.Hours, to)
+				}
+			}
+		})
+	}
+}
+
+func TestForecastVelocityNeedsPoints(t *testing.T) {
+	to := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cfg := models.SensorConfig{AlarmThs: 6}
+	summaries := series(to, 5, func(x float64) float64 { return 4 })
+	// Empty buckets do not count
+	for i := range summaries[:3] {
+		summaries[i] = models.VibrationSummary{Start: summaries[i].Start}
+	}
+
+	if _, err := ForecastVelocity(cfg, models.ForecastMethodLinear, summaries, time.Hour, to); !errors.Is(err, ErrTooFewPoints) {
+		t.Errorf("forecast from 2 points returned %v, want %v", err, ErrTooFewPoints)
+	}
+	if _, err := ForecastVelocity(models.SensorConfig{}, models.ForecastMethodLinear, summaries, time.Hour, to); err == nil {
+		t.Error("forecast without alarm_ths, want an error")
+	}
+	if _, err := ForecastVelocity(cfg, "quadratic", summaries, time.Hour, to); err == nil {
+		t.Error("forecast with an unknown method, want an error")
+	}
+}
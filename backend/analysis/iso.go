@@ -0,0 +1,37 @@
+package analysis
+
+import (
+	"errors"
+	"math"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+)
+
+// MaxAxisVelocity returns the largest RMS velocity (mm/s) across the X/Y/Z axes.
+// ISO 10816 evaluates the highest value measured in any direction.
+func MaxAxisVelocity(v models.VibrationData) float64 {
+	return math.Max(math.Abs(float64(v.X_Axismm_s)),
+		math.Max(math.Abs(float64(v.Y_Axismm_s)), math.Abs(float64(v.Z_Axismm_s))))
+}
+
+// ClassifyISOZone returns the evaluation zone of an RMS velocity (mm/s) under table.
+func ClassifyISOZone(table models.ISOZoneTable, velocity float64) string {
+	switch {
+	case velocity < table.AB:
+		return models.ISOZoneA
+	case velocity < table.BC:
+		return models.ISOZoneB
+	case velocity < table.CD:
+		return models.ISOZoneC
+	default:
+		return models.ISOZoneD
+	}
+}
+
+// ValidateISOZoneTable checks that the zone boundaries are positive and increasing.
+func ValidateISOZoneTable(table models.ISOZoneTable) error {
+	if table.AB <= 0 || table.AB >= table.BC || table.BC >= table.CD {
+		return errors.New("zone boundaries must satisfy 0 < ab < bc < cd")
+	}
+	return nil
+}
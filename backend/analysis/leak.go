@@ -0,0 +1,149 @@
+package analysis
+
+import (
+	"errors"
+	"math"
+	"math/cmplx"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+)
+
+// LocateLeak cross-correlates time-synchronized waveforms a and b from the sensors at
+// either end of segment and estimates where along it a common noise source, such as a
+// leak, lies. Axes captured by both sensors are demeaned and correlated together, and
+// only delays the noise could take to travel the segment are searched. The offset
+// between the waveforms' start times is taken into account. The returned candidate
+// has no IDs set.
+func LocateLeak(segment models.PipeSegment, a, b models.Waveform) (models.LeakCandidate, error) {
+	if a.SampleRate <= 0 || a.SampleRate != b.SampleRate {
+		return models.LeakCandidate{}, errors.New("Both waveforms must have the same sample rate")
+	}
+	if segment.Distance <= 0 || segment.WaveSpeed <= 0 {
+		return models.LeakCandidate{}, errors.New("The segment needs a positive distance and wave speed")
+	}
+
+	var pairs [][2][]float32
+	for _, axis := range [][2][]float32{{a.X, b.X}, {a.Y, b.Y}, {a.Z, b.Z}} {
+		if len(axis[0]) >= 2 && len(axis[1]) >= 2 {
+			pairs = append(pairs, axis)
+		}
+	}
+	if len(pairs) == 0 {
+		return models.LeakCandidate{}, errors.New("The waveforms share no captured axis")
+	}
+
+	lengthA, lengthB := len(pairs[0][0]), len(pairs[0][1])
+	correlation, ok := crossCorrelate(pairs, fftSize(lengthA+lengthB, 0))
+	if !ok {
+		return models.LeakCandidate{}, errors.New("A waveform has no variation to correlate")
+	}
+
+	// Delay of B after A at lag k is k/SampleRate plus the offset of B's start;
+	// it cannot exceed the travel time of the whole segment in either direction
+	offset := b.Timestamp.Sub(a.Timestamp).Seconds()
+	travel := segment.Distance / segment.WaveSpeed
+	low := max(int(math.Ceil((-travel-offset)*a.SampleRate)), -(lengthA - 1))
+	high := min(int(math.Floor((travel-offset)*a.SampleRate)), lengthB-1)
+	if low > high {
+		return models.LeakCandidate{}, errors.New("The waveforms are too far apart in time for the segment's travel time")
+	}
+
+	at := func(lag int) float64 {
+		if lag < 0 {
+			return correlation[len(correlation)+lag]
+		}
+		return correlation[lag]
+	}
+
+	peakLag := low
+	for lag := low; lag <= high; lag++ {
+		if at(lag) > at(peakLag) {
+			peakLag = lag
+		}
+	}
+	peak := at(peakLag)
+
+	// The main lobe runs from the peak down to the nearest local minimum on either side;
+	// the highest value beyond it measures how ambiguous the peak is
+	lobeLow, lobeHigh := peakLag, peakLag
+	for lobeLow > low && at(lobeLow-1) <= at(lobeLow) {
+		lobeLow--
+	}
+	for lobeHigh < high && at(lobeHigh+1) <= at(lobeHigh) {
+		lobeHigh++
+	}
+	secondary := 0.0
+	for lag := low; lag <= high; lag++ {
+		if lag < lobeLow || lag > lobeHigh {
+			secondary = math.Max(secondary, at(lag))
+		}
+	}
+
+	confidence := 0.0
+	if peak > 0 {
+		confidence = math.Min(peak, 1) * (1 - math.Min(secondary/peak, 1))
+	}
+
+	delay := float64(peakLag)/a.SampleRate + offset
+	position := (segment.Distance - segment.WaveSpeed*delay) / 2
+
+	return models.LeakCandidate{
+		OrganizationID: segment.OrganizationID,
+		SegmentID:      segment.ID,
+		SensorA:        segment.SensorA,
+		SensorB:        segment.SensorB,
+		WaveformA:      a.ID,
+		WaveformB:      b.ID,
+		Timestamp:      a.Timestamp,
+		Delay:          delay,
+		DistanceFromA:  math.Min(math.Max(position, 0), segment.Distance),
+		Correlation:    peak,
+		Confidence:     confidence,
+	}, nil
+}
+
+// crossCorrelate returns the normalized cross-correlation r[k] = Σ a[n]·b[n+k] summed over
+// the axis pairs, computed with zero-padded FFTs of size. Negative lags wrap around to the
+// end. ok is false when either side has no energy.
+func crossCorrelate(pairs [][2][]float32, size int) ([]float64, bool) {
+	product := make([]complex128, size)
+	energyA, energyB := 0.0, 0.0
+	for _, pair := range pairs {
+		spectrumA, sumA := paddedFFT(pair[0], size)
+		spectrumB, sumB := paddedFFT(pair[1], size)
+		energyA += sumA
+		energyB += sumB
+		for i := range product {
+			product[i] += cmplx.Conj(spectrumA[i]) * spectrumB[i]
+		}
+	}
+	if energyA == 0 || energyB == 0 {
+		return nil, false
+	}
+
+	// Inverse transform through the forward one: ifft(x) = conj(fft(conj(x))) / size
+	for i := range product {
+		product[i] = cmplx.Conj(product[i])
+	}
+	fft(product)
+
+	norm := float64(size) * math.Sqrt(energyA*energyB)
+	correlation := make([]float64, size)
+	for i, value := range product {
+		correlation[i] = real(value) / norm
+	}
+	return correlation, true
+}
+
+// paddedFFT transforms samples with their mean removed, zero-padded to size, and
+// returns the transform and the energy of the demeaned samples.
+func paddedFFT(samples []float32, size int) ([]complex128, float64) {
+	data := make([]complex128, size)
+	energy := 0.0
+	for i, s := range removeMean(samples) {
+		data[i] = complex(s, 0)
+		energy += s * s
+	}
+	fft(data)
+	return data, energy
+}
@@ -0,0 +1,92 @@
+package analysis
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+)
+
+// leakPair returns waveforms of samples at sampleRate in which the same noise reaches B
+// delay samples after A, with B's capture starting offset after A's.
+func leakPair(samples int, sampleRate float64, delay int, offset time.Duration) (models.Waveform, models.Waveform) {
+	random := rand.New(rand.NewPCG(1, 2))
+	margin := samples
+	noise := make([]float32, samples+2*margin)
+	for i := range noise {
+		noise[i] = float32(random.NormFloat64())
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	shift := int(math.Round(offset.Seconds() * sampleRate))
+	a := models.Waveform{SampleRate: sampleRate, Timestamp: start, X: noise[margin : margin+samples]}
+	b := models.Waveform{SampleRate: sampleRate, Timestamp: start.Add(offset), X: noise[margin+shift-delay : margin+shift-delay+samples]}
+	return a, b
+}
+
+func TestLocateLeak(t *testing.T) {
+	// 1 s of travel time over the segment is 1000 samples each way
+	segment := models.PipeSegment{Distance: 100, WaveSpeed: 100}
+	tests := []struct {
+		name     string
+		delay    int // Samples the noise reaches B after A
+		offset   time.Duration
+		position float64 // (Distance − WaveSpeed·delay)/2
+	}{
+		{name: "midpoint", delay: 0, position: 50},
+		{name: "nearer A", delay: 200, position: 40},
+		{name: "nearer B", delay: -500, position: 75},
+		{name: "at B", delay: -1000, position: 100},
+		{name: "capture offset", delay: 200, offset: 150 * time.Millisecond, position: 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := leakPair(4096, 1000, tt.delay, tt.offset)
+			candidate, err := LocateLeak(segment, a, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want := float64(tt.delay) / 1000; !near(candidate.Delay, want, 1e-9) {
+				t.Errorf("delay %g s, want %g", candidate.Delay, want)
+			}
+			if !near(candidate.DistanceFromA, tt.position, 1e-9) {
+				t.Errorf("leak %g m from A, want %g", candidate.DistanceFromA, tt.position)
+			}
+			if candidate.Correlation < 0.5 || candidate.Confidence < 0.5 {
+				t.Errorf("correlation %g and confidence %g, want a clear peak", candidate.Correlation, candidate.Confidence)
+			}
+		})
+	}
+}
+
+func TestLocateLeakRejects(t *testing.T) {
+	a, b := leakPair(512, 1000, 0, 0)
+	segment := models.PipeSegment{Distance: 100, WaveSpeed: 1000}
+	flat := b
+	flat.X = make([]float32, len(b.X))
+	late := b
+	late.Timestamp = b.Timestamp.Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		segment models.PipeSegment
+		a, b    models.Waveform
+	}{
+		{name: "different sample rates", segment: segment, a: a, b: models.Waveform{SampleRate: 500, X: b.X}},
+		{name: "no distance", segment: models.PipeSegment{WaveSpeed: 1000}, a: a, b: b},
+		{name: "no wave speed", segment: models.PipeSegment{Distance: 100}, a: a, b: b},
+		{name: "no shared axis", segment: segment, a: a, b: models.Waveform{SampleRate: 1000, Y: b.X}},
+		{name: "no variation", segment: segment, a: a, b: flat},
+		{name: "too far apart in time", segment: segment, a: a, b: late},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LocateLeak(tt.segment, tt.a, tt.b); err == nil {
+				t.Error("leak located, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/cmplx"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+)
+
+const (
+	// MaxWaveformSamples bounds the samples per axis of one waveform.
+	MaxWaveformSamples = 1 << 16
+	// maxFFTSize bounds the zero-padded transform length needed to reach the line resolution.
+	maxFFTSize = 1 << 22
+)
+
+// ValidateWaveform checks the sample rate and that every captured axis has the same
+// number of samples, and records that number on the waveform.
+func ValidateWaveform(w *models.Waveform) error {
+	if w.SampleRate <= 0 {
+		return errors.New("sample_rate must be positive")
+	}
+
+	samples := 0
+	for _, axis := range [][]float32{w.X, w.Y, w.Z} {
+		if len(axis) == 0 {
+			continue
+		}
+		if samples != 0 && len(axis) != samples {
+			return errors.New("All axes must have the same number of samples")
+		}
+		samples = len(axis)
+	}
+
+	switch {
+	case samples == 0:
+		return errors.New("At least one axis must have samples")
+	case samples < 2:
+		return errors.New("A waveform needs at least 2 samples per axis")
+	case samples > MaxWaveformSamples:
+		return fmt.Errorf("A waveform may have at most %d samples per axis", MaxWaveformSamples)
+	}
+	w.Samples = samples
+	return nil
+}
+
+// ComputeSpectrum returns the amplitude spectrum of every captured axis of w with the
+// FMax and LOR lines of the sensor's configuration.
+func ComputeSpectrum(cfg models.SensorConfig, w models.Waveform) (models.Spectrum, error) {
+	if cfg.FMax <= 0 || cfg.LOR <= 0 {
+		return models.Spectrum{}, errors.New("Sensor has no fmax and lor configured")
+	}
+
+	fmax := float64(cfg.FMax)
+	if fmax > w.SampleRate/2 {
+		return models.Spectrum{}, fmt.Errorf("fmax %g Hz is above the Nyquist frequency of %g Hz", fmax, w.SampleRate/2)
+	}
+
+	resolution := fmax / float64(cfg.LOR)
+	size := fftSize(w.Samples, w.SampleRate/resolution)
+	if size > maxFFTSize {
+		return models.Spectrum{}, errors.New("fmax and lor need a finer resolution than the sample rate allows")
+	}
+
+	spectrum := models.Spectrum{
+		SensorID:   w.SensorID,
+		WaveformID: w.ID,
+		Timestamp:  w.Timestamp,
+		FMax:       fmax,
+		Lines:      cfg.LOR,
+		Resolution: resolution,
+	}
+	spectrum.X = lineSpectrum(w.X, w.SampleRate, resolution, cfg.LOR, size)
+	spectrum.Y = lineSpectrum(w.Y, w.SampleRate, resolution, cfg.LOR, size)
+	spectrum.Z = lineSpectrum(w.Z, w.SampleRate, resolution, cfg.LOR, size)
+	return spectrum, nil
+}
+
+// fftSize returns the smallest power of two that holds samples and whose bins
+// are no wider than one line, i.e. at least minBins long.
+func fftSize(samples int, minBins float64) int {
+	size := 1
+	for size < samples || float64(size) < minBins {
+		size <<= 1
+	}
+	return size
+}
+
+// lineSpectrum computes the single-sided peak amplitude spectrum of samples with a
+// Hann window, zero-padded to size, and keeps the highest bin within each line.
+func lineSpectrum(samples []float32, sampleRate, resolution float64, lines, size int) []float32 {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	data, windowSum, _ := windowedFFT(samples, size)
+
+	binWidth := sampleRate / float64(size)
+	amplitudes := make([]float32, lines)
+	for line := range amplitudes {
+		centre := float64(line+1) * resolution
+		low := int(math.Ceil((centre - resolution/2) / binWidth))
+		high := int(math.Ceil((centre+resolution/2)/binWidth)) - 1
+		if low < 1 {
+			low = 1
+		}
+		if high > size/2 {
+			high = size / 2
+		}
+
+		peak := 0.0
+		for bin := low; bin <= high; bin++ {
+			if amplitude := 2 * cmplx.Abs(data[bin]) / windowSum; amplitude > peak {
+				peak = amplitude
+			}
+		}
+		amplitudes[line] = float32(peak)
+	}
+	return amplitudes
+}
+
+// powerSpectrum returns the single-sided power (mean square) of samples in each FFT bin
+// from 0 to the Nyquist frequency, and the bin width in Hz. Unlike peak amplitudes, bin
+// powers add up to the signal's mean square, so they can be summed over a band.
+func powerSpectrum(samples []float32, sampleRate float64) ([]float64, float64) {
+	size := fftSize(len(samples), 0)
+	data, _, windowSumSq := windowedFFT(samples, size)
+
+	power := make([]float64, size/2+1)
+	for bin := range power {
+		magnitude := cmplx.Abs(data[bin])
+		power[bin] = magnitude * magnitude / (float64(size) * windowSumSq)
+		if bin != 0 && bin != size/2 {
+			power[bin] *= 2
+		}
+	}
+	return power, sampleRate / float64(size)
+}
+
+// windowedFFT transforms samples with their DC offset removed and a Hann window applied,
+// zero-padded to size. It also returns the sum and the sum of squares of the window.
+func windowedFFT(samples []float32, size int) ([]complex128, float64, float64) {
+	// Remove the DC offset so gravity and sensor bias do not leak into the first lines
+	centred := removeMean(samples)
+
+	n := len(samples)
+	data := make([]complex128, size)
+	windowSum, windowSumSq := 0.0, 0.0
+	for i, s := range centred {
+		w := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1))
+		windowSum += w
+		windowSumSq += w * w
+		data[i] = complex(s*w, 0)
+	}
+	fft(data)
+	return data, windowSum, windowSumSq
+}
+
+// fft is an in-place iterative radix-2 Cooley-Tukey transform; len(data) must be a power of two.
+func fft(data []complex128) {
+	n := len(data)
+
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			data[i], data[j] = data[j], data[i]
+		}
+	}
+
+	for length := 2; length <= n; length <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(length)))
+		for start := 0; start < n; start += length {
+			w := complex(1, 0)
+			for k := 0; k < length/2; k++ {
+				even, odd := data[start+k], data[start+k+length/2]*w
+				data[start+k] = even + odd
+				data[start+k+length/2] = even - odd
+				w *= step
+			}
+		}
+	}
+}
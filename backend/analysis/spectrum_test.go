@@ -0,0 +1,115 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+)
+
+// sine returns n samples of amplitude·sin(2π·frequency·t) plus offset at sampleRate.
+func sine(n int, sampleRate, frequency, amplitude, offset float64) []float32 {
+	samples := make([]float32, n)
+	for i := range samples {
+		samples[i] = float32(offset + amplitude*math.Sin(2*math.Pi*frequency*float64(i)/sampleRate))
+	}
+	return samples
+}
+
+func TestComputeSpectrumRecoversSine(t *testing.T) {
+	tests := []struct {
+		name       string
+		sampleRate float64
+		samples    int
+		fmax, lor  int
+		frequency  float64
+		amplitude  float64
+		offset     float64
+	}{
+		{name: "on a line", sampleRate: 1024, samples: 1024, fmax: 200, lor: 200, frequency: 50, amplitude: 1},
+		{name: "other amplitude", sampleRate: 1024, samples: 1024, fmax: 200, lor: 200, frequency: 120, amplitude: 0.25},
+		{name: "DC offset removed", sampleRate: 1024, samples: 1024, fmax: 200, lor: 200, frequency: 10, amplitude: 0.5, offset: 1},
+		{name: "zero-padded to the line resolution", sampleRate: 2048, samples: 1000, fmax: 400, lor: 800, frequency: 100, amplitude: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := models.Waveform{SampleRate: tt.sampleRate, X: sine(tt.samples, tt.sampleRate, tt.frequency, tt.amplitude, tt.offset)}
+			if err := ValidateWaveform(&w); err != nil {
+				t.Fatal(err)
+			}
+			spectrum, err := ComputeSpectrum(models.SensorConfig{FMax: tt.fmax, LOR: tt.lor}, w)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(spectrum.X) != tt.lor || spectrum.Y != nil {
+				t.Fatalf("%d X lines and %d Y lines, want %d and none", len(spectrum.X), len(spectrum.Y), tt.lor)
+			}
+
+			peak := 0
+			for line, amplitude := range spectrum.X {
+				if amplitude > spectrum.X[peak] {
+					peak = line
+				}
+			}
+			// Line i is centred on (i+1)·Resolution
+			if want := int(math.Round(tt.frequency/spectrum.Resolution)) - 1; peak != want {
+				t.Errorf("peak on line %d (%g Hz), want line %d (%g Hz)", peak, float64(peak+1)*spectrum.Resolution, want, tt.frequency)
+			}
+			if got := float64(spectrum.X[peak]); math.Abs(got-tt.amplitude) > 0.01*tt.amplitude {
+				t.Errorf("peak amplitude %g, want %g", got, tt.amplitude)
+			}
+		})
+	}
+}
+
+func TestComputeSpectrumRejectsConfig(t *testing.T) {
+	w := models.Waveform{SampleRate: 1000, X: sine(1000, 1000, 50, 1, 0)}
+	if err := ValidateWaveform(&w); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		cfg  models.SensorConfig
+	}{
+		{name: "no fmax", cfg: models.SensorConfig{LOR: 100}},
+		{name: "no lor", cfg: models.SensorConfig{FMax: 100}},
+		{name: "fmax above Nyquist", cfg: models.SensorConfig{FMax: 600, LOR: 100}},
+		{name: "resolution too fine", cfg: models.SensorConfig{FMax: 500, LOR: 1 << 22}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ComputeSpectrum(tt.cfg, w); err == nil {
+				t.Error("spectrum computed, want an error")
+			}
+		})
+	}
+}
+
+func TestValidateWaveform(t *testing.T) {
+	tests := []struct {
+		name    string
+		w       models.Waveform
+		samples int // Zero when the waveform is invalid
+	}{
+		{name: "one axis", w: models.Waveform{SampleRate: 100, Z: make([]float32, 8)}, samples: 8},
+		{name: "three axes", w: models.Waveform{SampleRate: 100, X: make([]float32, 4), Y: make([]float32, 4), Z: make([]float32, 4)}, samples: 4},
+		{name: "no sample rate", w: models.Waveform{X: make([]float32, 8)}},
+		{name: "axes of different lengths", w: models.Waveform{SampleRate: 100, X: make([]float32, 8), Y: make([]float32, 7)}},
+		{name: "no samples", w: models.Waveform{SampleRate: 100}},
+		{name: "one sample", w: models.Waveform{SampleRate: 100, X: make([]float32, 1)}},
+		{name: "too many samples", w: models.Waveform{SampleRate: 100, X: make([]float32, MaxWaveformSamples+1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateWaveform(&tt.w)
+			switch {
+			case tt.samples == 0 && err == nil:
+				t.Error("waveform accepted, want an error")
+			case tt.samples != 0 && err != nil:
+				t.Errorf("waveform rejected: %v", err)
+			case tt.samples != 0 && tt.w.Samples != tt.samples:
+				t.Errorf("%d samples recorded, want %d", tt.w.Samples, tt.samples)
+			}
+		})
+	}
+}
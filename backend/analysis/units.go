@@ -0,0 +1,115 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+)
+
+const (
+	// unitTolerance is the relative difference allowed between the mm/s² field and g × StandardGravity.
+	unitTolerance = 0.05
+	// unitFloor is the absolute difference in mm/s² always allowed, so near-zero readings
+	// are not flagged over rounding.
+	unitFloor = 1.0
+
+	// sampleRateToFMax is the usual analyzer ratio of sample rate to usable bandwidth.
+	sampleRateToFMax = 2.56
+)
+
+// VelocityBand returns the [low, high] Hz band over which acceleration is integrated to
+// velocity: from the ISO 10816 lower limit to FMax, or to the bandwidth the sample rate
+// supports when FMax is not set.
+func VelocityBand(cfg models.SensorConfig) (float64, float64, error) {
+	high := float64(cfg.FMax)
+	if high <= 0 {
+		high = cfg.SampleRate / sampleRateToFMax
+	}
+	if high <= velocityLowCutoff {
+		return 0, 0, fmt.Errorf("Deriving velocity needs fmax or sample_rate covering more than %g Hz", velocityLowCutoff)
+	}
+	return velocityLowCutoff, high, nil
+}
+
+// IntegrateAcceleration converts an acceleration RMS in mm/s² to a velocity RMS in mm/s,
+// assuming the acceleration is spread evenly over [low, high] Hz. Integrating 1/(2πf)²
+// over that band gives an effective frequency of √(low·high).
+func IntegrateAcceleration(accelerationMMS2, low, high float64) float64 {
+	return accelerationMMS2 / (2 * math.Pi * math.Sqrt(low*high))
+}
+
+// axisUnits addresses the three unit fields of one axis of a reading.
+type axisUnits struct {
+	name         string
+	g, mms2, mms *float32
+}
+
+func readingAxes(v *models.VibrationData) []axisUnits {
+	return []axisUnits{
+		{"x", &v.X_Axisg, &v.X_Axismm_s2, &v.X_Axismm_s},
+		{"y", &v.Y_Axisg, &v.Y_Axismm_s2, &v.Y_Axismm_s},
+		{"z", &v.Z_Axisg, &v.Z_Axismm_s2, &v.Z_Axismm_s},
+	}
+}
+
+// ApplyUnitMode enforces the sensor's unit mode on a reading. Under UnitModeG the mm/s²
+// and mm/s fields are derived from g, replacing anything sent. Otherwise the g and mm/s²
+// fields are checked against each other, and a mismatch either sets UnitMismatch or is
+// returned as an error, as the sensor's UnitMismatch policy says.
+func ApplyUnitMode(cfg models.SensorConfig, v *models.VibrationData) error {
+	if cfg.UnitMode == models.UnitModeG {
+		low, high, err := VelocityBand(cfg)
+		if err != nil {
+			return err
+		}
+
+		for _, axis := range readingAxes(v) {
+			mms2 := float64(*axis.g) * StandardGravity
+			*axis.mms2 = float32(mms2)
+			*axis.mms = float32(IntegrateAcceleration(mms2, low, high))
+		}
+		v.UnitMismatch = false
+		return nil
+	}
+
+	var mismatched []string
+	for _, axis := range readingAxes(v) {
+		expected := float64(*axis.g) * StandardGravity
+		difference := math.Abs(float64(*axis.mms2) - expected)
+		if difference > math.Max(unitTolerance*math.Abs(expected), unitFloor) {
+			mismatched = append(mismatched, axis.name)
+		}
+	}
+
+	v.UnitMismatch = len(mismatched) > 0
+	if v.UnitMismatch && cfg.UnitMismatch == models.UnitMismatchReject {
+		return fmt.Errorf("Acceleration in mm/s² does not match g × %g on axes %v", StandardGravity, mismatched)
+	}
+	return nil
+}
+
+// ValidateUnitConfig checks the unit mode and mismatch policy of a sensor config, and
+// that a sensor in UnitModeG has a band to integrate velocity over.
+func ValidateUnitConfig(cfg models.SensorConfig) error {
+	if cfg.SampleRate < 0 {
+		return errors.New("sample_rate must not be negative")
+	}
+
+	switch cfg.UnitMismatch {
+	case "", models.UnitMismatchFlag, models.UnitMismatchReject:
+	default:
+		return fmt.Errorf("Unknown unit_mismatch: %s", cfg.UnitMismatch)
+	}
+
+	switch cfg.UnitMode {
+	case "", models.UnitModeAll:
+		return nil
+	case models.UnitModeG:
+		_, _, err := VelocityBand(cfg)
+		return err
+	default:
+		return fmt.Errorf("Unknown unit_mode: %s", cfg.UnitMode)
+	}
+}
@@ -1,28 +1,397 @@
 package config
 
 import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Backends selectable for vibration readings.
+const (
+	VibrationStoreMongo    = "mongo"
+	VibrationStorePostgres = "postgres"
 )
 
 type Config struct {
-	JWTSecret string
+	Port string
+
+	// MongoDB connection
+	MongoURI            string
+	MongoDatabase       string
+	MongoConnectTimeout time.Duration
+	MongoPingTimeout    time.Duration
+
+	// Vibration readings are kept in MongoDB, or in PostgreSQL/TimescaleDB
+	// when VibrationStore is "postgres"
+	VibrationStore string
+	PostgresURL    string
+
+	// Rollup job schedule and how far back it re-reads for late readings
+	RollupInterval time.Duration
+	RollupLateness time.Duration
+
+	// How long raw readings and each rollup tier are kept; zero keeps them forever
+	RetentionRaw    time.Duration
+	RetentionMinute time.Duration
+	RetentionHour   time.Duration
+	RetentionDay    time.Duration
+
+	// Leak analysis schedule, how closely the waveforms of neighbouring sensors must be
+	// synchronized to be paired, and the confidence a leak candidate needs to be stored
+	LeakInterval      time.Duration
+	LeakSyncTolerance time.Duration
+	LeakLateness      time.Duration // How long a waveform waits for its partner
+	LeakBackfill      time.Duration // How far back the first analysis of a segment looks
+	LeakMinConfidence float64
+
+	// Trend forecasting: how often every sensor is forecast, the method and the window
+	// and bucket length of the velocity RMS series, and how soon a projected Critical
+	// crossing raises a predictive alert
+	ForecastInterval time.Duration
+	ForecastMethod   string
+	ForecastWindow   time.Duration
+	ForecastBucket   time.Duration
+	ForecastHorizon  time.Duration
+
+	// Offline watchdog: how often it runs, the reporting interval of sensors that do not
+	// configure one, and how many intervals of silence take a sensor offline
+	WatchdogInterval     time.Duration
+	SensorReportInterval time.Duration
+	OfflineMultiple      float64
+
+	// Firmware updates: the largest image accepted, the hex Ed25519 public key image
+	// signatures are checked against (unchecked when empty), and the canary share and
+	// failure rate that new campaigns default to
+	FirmwareMaxSize          int64
+	FirmwarePublicKey        string
+	FirmwareCanaryPercent    float64
+	FirmwareFailureThreshold float64
+
+	// JWT signing and token lifetimes
+	JWTSecret       string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// How long a rotated sensor token keeps working unless the rotation sets its own grace period
+	SensorTokenGrace time.Duration
+	// How long a claim code pre-registered without its own expiry stays valid
+	SensorClaimCodeTTL time.Duration
+	// How many wrong claim codes in a row lock the claim code of a sensor, and for how long
+	SensorClaimMaxFailures int64
+	SensorClaimLockout     time.Duration
+
+	// Credentials for the first super-admin, created at startup when none exists
+	BootstrapAdminUsername string
+	BootstrapAdminPassword string
+	BootstrapAdminEmail    string
+
+	// MQTT ingestion; the listener is disabled when MQTTBrokerURL is empty
+	MQTTBrokerURL string
+	MQTTTopic     string
+	MQTTClientID  string
+	MQTTUsername  string
+	MQTTPassword  string
 }
 
+// MaxForecastBuckets bounds the length of the series a forecast is fitted to.
+const MaxForecastBuckets = 2000
+
+// MaxFirmwareSize bounds FirmwareMaxSize so an image fits in a single MongoDB document.
+const MaxFirmwareSize = 15 << 20
+
 var appConfig *Config
 
-func init() {
-	appConfig = &Config{
-		JWTSecret: getEnv("JWT_SECRET", "your-secret-key"), // Default secret key, should be changed in production
+// Load reads the configuration and validates it. Every setting is looked up
+// first in its environment variable (e.g. MONGO_URI), then under the lower-case
+// key (e.g. mongo_uri) of the optional YAML or TOML file named by CONFIG_FILE,
+// and finally falls back to its default. Required settings have no default.
+func Load() error {
+	src := source{env: true}
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			return err
+		}
+		src.file = values
+	}
+
+	cfg := src.config()
+	if err := errors.Join(append(src.errs, cfg.validate())...); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	appConfig = cfg
+	return nil
+}
+
+// Defaults returns the configuration with every setting at its default, ignoring the
+// environment and config file. Required settings, such as MONGO_URI and JWT_SECRET,
+// are left empty for the caller to fill in.
+func Defaults() *Config {
+	src := source{}
+	return src.config()
+}
+
+// config builds the configuration from the settings src resolves.
+func (src *source) config() *Config {
+	return &Config{
+		Port: src.get("PORT", "8080"),
+
+		MongoURI:            src.get("MONGO_URI", ""),
+		MongoDatabase:       src.get("MONGO_DATABASE", "vibration-sensor"),
+		MongoConnectTimeout: src.duration("MONGO_CONNECT_TIMEOUT", 10*time.Second),
+		MongoPingTimeout:    src.duration("MONGO_PING_TIMEOUT", 5*time.Second),
+
+		VibrationStore: src.get("VIBRATION_STORE", VibrationStoreMongo),
+		PostgresURL:    src.get("POSTGRES_URL", ""),
+
+		RollupInterval: src.duration("ROLLUP_INTERVAL", time.Minute),
+		RollupLateness: src.duration("ROLLUP_LATENESS", 10*time.Minute),
+
+		RetentionRaw:    src.duration("RETENTION_RAW", 30*24*time.Hour),
+		RetentionMinute: src.duration("RETENTION_1M", 365*24*time.Hour),
+		RetentionHour:   src.duration("RETENTION_1H", 5*365*24*time.Hour),
+		RetentionDay:    src.duration("RETENTION_1D", 0),
+
+		LeakInterval:      src.duration("LEAK_INTERVAL", 5*time.Minute),
+		LeakSyncTolerance: src.duration("LEAK_SYNC_TOLERANCE", 10*time.Millisecond),
+		LeakLateness:      src.duration("LEAK_LATENESS", 10*time.Minute),
+		LeakBackfill:      src.duration("LEAK_BACKFILL", 24*time.Hour),
+		LeakMinConfidence: src.float("LEAK_MIN_CONFIDENCE", 0.5),
+
+		ForecastInterval: src.duration("FORECAST_INTERVAL", time.Hour),
+		ForecastMethod:   src.get("FORECAST_METHOD", "linear"),
+		ForecastWindow:   src.duration("FORECAST_WINDOW", 7*24*time.Hour),
+		ForecastBucket:   src.duration("FORECAST_BUCKET", time.Hour),
+		ForecastHorizon:  src.duration("FORECAST_HORIZON", 7*24*time.Hour),
+
+		WatchdogInterval:     src.duration("WATCHDOG_INTERVAL", time.Minute),
+		SensorReportInterval: src.duration("SENSOR_REPORT_INTERVAL", 5*time.Minute),
+		OfflineMultiple:      src.float("OFFLINE_MULTIPLE", 3),
+
+		FirmwareMaxSize:          src.int("FIRMWARE_MAX_SIZE", 8<<20),
+		FirmwarePublicKey:        src.get("FIRMWARE_PUBLIC_KEY", ""),
+		FirmwareCanaryPercent:    src.float("FIRMWARE_CANARY_PERCENT", 10),
+		FirmwareFailureThreshold: src.float("FIRMWARE_FAILURE_THRESHOLD", 0.2),
+
+		JWTSecret:       src.get("JWT_SECRET", ""),
+		AccessTokenTTL:  src.duration("ACCESS_TOKEN_TTL", 24*time.Hour),
+		RefreshTokenTTL: src.duration("REFRESH_TOKEN_TTL", 7*24*time.Hour),
+
+		SensorTokenGrace:       src.duration("SENSOR_TOKEN_GRACE", 24*time.Hour),
+		SensorClaimCodeTTL:     src.duration("SENSOR_CLAIM_CODE_TTL", 90*24*time.Hour),
+		SensorClaimMaxFailures: src.int("SENSOR_CLAIM_MAX_FAILURES", 5),
+		SensorClaimLockout:     src.duration("SENSOR_CLAIM_LOCKOUT", 15*time.Minute),
+
+		BootstrapAdminUsername: src.get("BOOTSTRAP_ADMIN_USERNAME", ""),
+		BootstrapAdminPassword: src.get("BOOTSTRAP_ADMIN_PASSWORD", ""),
+		BootstrapAdminEmail:    src.get("BOOTSTRAP_ADMIN_EMAIL", ""),
+
+		MQTTBrokerURL: src.get("MQTT_BROKER_URL", ""),
+		MQTTTopic:     src.get("MQTT_TOPIC", "sensors/{serial_number}/vibration"),
+		MQTTClientID:  src.get("MQTT_CLIENT_ID", "vibration-sensor-backend"),
+		MQTTUsername:  src.get("MQTT_USERNAME", ""),
+		MQTTPassword:  src.get("MQTT_PASSWORD", ""),
+	}
+}
+
+// validate reports every missing or out-of-range setting at once.
+func (cfg *Config) validate() error {
+	var errs []error
+
+	if cfg.MongoURI == "" {
+		errs = append(errs, errors.New("MONGO_URI is required"))
+	}
+	if cfg.MongoDatabase == "" {
+		errs = append(errs, errors.New("MONGO_DATABASE must not be empty"))
+	}
+	switch cfg.VibrationStore {
+	case VibrationStoreMongo:
+	case VibrationStorePostgres:
+		if cfg.PostgresURL == "" {
+			errs = append(errs, errors.New("POSTGRES_URL is required when VIBRATION_STORE is postgres"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("VIBRATION_STORE %q must be %s or %s", cfg.VibrationStore, VibrationStoreMongo, VibrationStorePostgres))
+	}
+	if cfg.JWTSecret == "" {
+		errs = append(errs, errors.New("JWT_SECRET is required"))
+	} else if len(cfg.JWTSecret) < 32 {
+		errs = append(errs, errors.New("JWT_SECRET must be at least 32 characters"))
+	}
+	if port, err := strconv.Atoi(cfg.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT %q is not a valid port", cfg.Port))
+	}
+
+	positive := map[string]time.Duration{
+		"MONGO_CONNECT_TIMEOUT":  cfg.MongoConnectTimeout,
+		"MONGO_PING_TIMEOUT":     cfg.MongoPingTimeout,
+		"ACCESS_TOKEN_TTL":       cfg.AccessTokenTTL,
+		"REFRESH_TOKEN_TTL":      cfg.RefreshTokenTTL,
+		"ROLLUP_INTERVAL":        cfg.RollupInterval,
+		"LEAK_INTERVAL":          cfg.LeakInterval,
+		"LEAK_SYNC_TOLERANCE":    cfg.LeakSyncTolerance,
+		"FORECAST_INTERVAL":      cfg.ForecastInterval,
+		"FORECAST_WINDOW":        cfg.ForecastWindow,
+		"FORECAST_BUCKET":        cfg.ForecastBucket,
+		"FORECAST_HORIZON":       cfg.ForecastHorizon,
+		"WATCHDOG_INTERVAL":      cfg.WatchdogInterval,
+		"SENSOR_REPORT_INTERVAL": cfg.SensorReportInterval,
+		"SENSOR_CLAIM_CODE_TTL":  cfg.SensorClaimCodeTTL,
+		"SENSOR_CLAIM_LOCKOUT":   cfg.SensorClaimLockout,
+	}
+	for key, value := range positive {
+		if value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", key))
+		}
+	}
+
+	nonNegative := map[string]time.Duration{
+		"ROLLUP_LATENESS":    cfg.RollupLateness,
+		"RETENTION_RAW":      cfg.RetentionRaw,
+		"RETENTION_1M":       cfg.RetentionMinute,
+		"RETENTION_1H":       cfg.RetentionHour,
+		"RETENTION_1D":       cfg.RetentionDay,
+		"LEAK_LATENESS":      cfg.LeakLateness,
+		"LEAK_BACKFILL":      cfg.LeakBackfill,
+		"SENSOR_TOKEN_GRACE": cfg.SensorTokenGrace,
+	}
+	for key, value := range nonNegative {
+		if value < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative", key))
+		}
+	}
+	if cfg.LeakMinConfidence < 0 || cfg.LeakMinConfidence > 1 {
+		errs = append(errs, errors.New("LEAK_MIN_CONFIDENCE must be between 0 and 1"))
+	}
+	if cfg.OfflineMultiple < 1 {
+		errs = append(errs, errors.New("OFFLINE_MULTIPLE must be at least 1"))
+	}
+	if cfg.SensorClaimMaxFailures < 1 {
+		errs = append(errs, errors.New("SENSOR_CLAIM_MAX_FAILURES must be at least 1"))
 	}
+	if cfg.FirmwareMaxSize <= 0 || cfg.FirmwareMaxSize > MaxFirmwareSize {
+		errs = append(errs, fmt.Errorf("FIRMWARE_MAX_SIZE must be between 1 and %d bytes", MaxFirmwareSize))
+	}
+	if cfg.FirmwarePublicKey != "" {
+		if key, err := hex.DecodeString(cfg.FirmwarePublicKey); err != nil || len(key) != ed25519.PublicKeySize {
+			errs = append(errs, fmt.Errorf("FIRMWARE_PUBLIC_KEY must be a %d-byte hex encoded Ed25519 public key", ed25519.PublicKeySize))
+		}
+	}
+	if cfg.FirmwareCanaryPercent < 0 || cfg.FirmwareCanaryPercent > 100 {
+		errs = append(errs, errors.New("FIRMWARE_CANARY_PERCENT must be between 0 and 100"))
+	}
+	if cfg.FirmwareFailureThreshold < 0 || cfg.FirmwareFailureThreshold > 1 {
+		errs = append(errs, errors.New("FIRMWARE_FAILURE_THRESHOLD must be between 0 and 1"))
+	}
+	switch cfg.ForecastMethod {
+	case "linear", "exponential", "robust":
+	default:
+		errs = append(errs, fmt.Errorf("FORECAST_METHOD %q must be linear, exponential or robust", cfg.ForecastMethod))
+	}
+	if cfg.ForecastBucket > 0 && cfg.ForecastWindow/cfg.ForecastBucket > MaxForecastBuckets {
+		errs = append(errs, fmt.Errorf("FORECAST_WINDOW spans more than %d FORECAST_BUCKETs", MaxForecastBuckets))
+	}
+	if cfg.RefreshTokenTTL <= cfg.AccessTokenTTL {
+		errs = append(errs, errors.New("REFRESH_TOKEN_TTL must be longer than ACCESS_TOKEN_TTL"))
+	}
+
+	return errors.Join(errs...)
 }
 
 func GetConfig() *Config {
 	return appConfig
 }
 
-func getEnv(key, defaultValue string) string {
-	if value, exists := os.LookupEnv(key); exists {
+// source resolves settings from the environment, when env is set, and the optional
+// config file, collecting parse errors so they are reported together by Load.
+type source struct {
+	env  bool
+	file map[string]string
+	errs []error
+}
+
+func (s *source) get(key, defaultValue string) string {
+	if value, exists := os.LookupEnv(key); exists && s.env {
+		return value
+	}
+	if value, exists := s.file[strings.ToLower(key)]; exists {
 		return value
 	}
 	return defaultValue
 }
+
+func (s *source) duration(key string, defaultValue time.Duration) time.Duration {
+	raw := s.get(key, "")
+	if raw == "" {
+		return defaultValue
+	}
+	value, err := time.ParseDuration(raw)
+	if err != nil {
+		s.errs = append(s.errs, fmt.Errorf("%s: %v", key, err))
+		return defaultValue
+	}
+	return value
+}
+
+func (s *source) float(key string, defaultValue float64) float64 {
+	raw := s.get(key, "")
+	if raw == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		s.errs = append(s.errs, fmt.Errorf("%s: %v", key, err))
+		return defaultValue
+	}
+	return value
+}
+
+func (s *source) int(key string, defaultValue int64) int64 {
+	raw := s.get(key, "")
+	if raw == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		s.errs = append(s.errs, fmt.Errorf("%s: %v", key, err))
+		return defaultValue
+	}
+	return value
+}
+
+// readFile loads a flat YAML or TOML file, chosen by extension, into string values.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	raw := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("unsupported config file type %q, use .yaml, .yml or .toml", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %v", err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		values[strings.ToLower(key)] = fmt.Sprint(value)
+	}
+	return values, nil
+}
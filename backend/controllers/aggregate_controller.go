@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/analysis"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/rollup"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultAggregateInterval = time.Hour
+	defaultAggregateRange    = 7 * 24 * time.Hour
+	// maxAggregateBuckets bounds the response size of one aggregation request
+	maxAggregateBuckets = 10000
+)
+
+var defaultAggregateMetrics = []string{models.MetricRMS, models.MetricMax, models.MetricMin, models.MetricAvg}
+
+// parseInterval accepts Go durations such as 15m or 1h, plus whole days such as 1d.
+func parseInterval(raw string) (time.Duration, error) {
+	if days, found := strings.CutSuffix(raw, "d"); found {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("Invalid interval: %s", raw)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	interval, err := time.ParseDuration(raw)
+	if err != nil || interval < time.Second {
+		return 0, fmt.Errorf("Invalid interval: %s", raw)
+	}
+	return interval, nil
+}
+
+// parseMetrics splits a comma-separated metric list, rejecting unknown names.
+func parseMetrics(raw string) ([]string, error) {
+	if raw == "" {
+		return defaultAggregateMetrics, nil
+	}
+
+	var metrics []string
+	for _, metric := range strings.Split(raw, ",") {
+		metric = strings.TrimSpace(metric)
+		switch metric {
+		case models.MetricRMS, models.MetricMax, models.MetricMin, models.MetricAvg:
+			metrics = append(metrics, metric)
+		default:
+			return nil, fmt.Errorf("Unknown metric: %s", metric)
+		}
+	}
+	return metrics, nil
+}
+
+// parseAggregateQuery reads the interval and [from, to) range of an aggregation request.
+// The range defaults to the last week.
+func parseAggregateQuery(c *gin.Context, sensorID primitive.ObjectID) (store.AggregateQuery, error) {
+	query := store.AggregateQuery{SensorID: sensorID, Interval: defaultAggregateInterval, To: time.Now()}
+
+	if raw := c.Query("interval"); raw != "" {
+		interval, err := parseInterval(raw)
+		if err != nil {
+			return query, err
+		}
+		query.Interval = interval
+	}
+
+	if raw := c.Query("to"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return query, errors.New("to must be an RFC 3339 time")
+		}
+		query.To = t
+	}
+
+	query.From = query.To.Add(-defaultAggregateRange)
+	if raw := c.Query("from"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return query, errors.New("from must be an RFC 3339 time")
+		}
+		query.From = t
+	}
+
+	if !query.From.Before(query.To) {
+		return query, errors.New("from must be before to")
+	}
+	if query.To.Sub(query.From)/query.Interval > maxAggregateBuckets {
+		return query, fmt.Errorf("Range spans more than %d intervals; use a longer interval", maxAggregateBuckets)
+	}
+	return query, nil
+}
+
+// AggregateVibrations returns per-bucket statistics of a sensor's readings for trend charts.
+func (h *Handler) AggregateVibrations(c *gin.Context) {
+	id := c.Param("id")
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if _, err := h.findScopedSensor(c, objectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found"})
+		return
+	}
+
+	query, err := parseAggregateQuery(c, objectID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	metrics, err := parseMetrics(c.Query("metrics"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts := rollup.OptionsFromConfig(h.Config)
+	summaries, source, err := rollup.Aggregate(context.Background(), h.Stores.Vibrations, h.Stores.Rollups, opts, query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	buckets := make([]models.VibrationBucket, len(summaries))
+	for i, summary := range summaries {
+		buckets[i] = analysis.BucketStats(summary, metrics)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sensor_id": objectID,
+		"interval":  query.Interval.String(),
+		"from":      query.From,
+		"to":        query.To,
+		"metrics":   metrics,
+		"source":    source,
+		"buckets":   buckets,
+	})
+}
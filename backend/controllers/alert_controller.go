@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/middleware"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// activeAlertStatuses are the statuses under which new occurrences are de-duplicated.
+var activeAlertStatuses = []string{models.AlertStatusOpen, models.AlertStatusAcknowledged}
+
+// raiseAlert folds an abnormal occurrence into the sensor's active alert of the
+// given type, opening a new alert when none is open or acknowledged.
+func (h *Handler) raiseAlert(sensor models.Sensor, alertType string, level int, at time.Time, message string) (store.AlertChange, error) {
+	return h.Stores.Alerts.Raise(context.Background(), store.AlertOccurrence{
+		SensorID:       sensor.ID,
+		OrganizationID: sensor.OrganizationID,
+		Type:           alertType,
+		Level:          level,
+		At:             at,
+		Message:        message,
+	})
+}
+
+// trackVibrationAlert opens or updates the threshold alert of a reading above Normal.
+// Failures are logged rather than returned so they never reject the reading itself.
+// Sensors classified by features raise threshold alerts from trackFeatureAlert instead.
+func (h *Handler) trackVibrationAlert(sensor models.Sensor, vibration models.VibrationData) {
+	if vibration.WarnLevel <= models.WarningLevelNormal || sensor.Config.ClassifyBy == models.ClassifyByFeatures {
+		return
+	}
+
+	message := "Warning level " + strconv.Itoa(vibration.WarnLevel) + " reading"
+	change, err := h.raiseAlert(sensor, models.AlertTypeThreshold, vibration.WarnLevel, vibration.Timestamp, message)
+	if err != nil {
+		log.Println("Failed to track alert for sensor", sensor.ID.Hex()+":", err)
+		return
+	}
+	h.notifyAlertChange(change)
+}
+
+// trackFeatureAlert opens or updates the threshold alert of a feature set above Normal,
+// for sensors classified by features.
+func (h *Handler) trackFeatureAlert(sensor models.Sensor, features models.FeatureSet) {
+	if features.WarnLevel <= models.WarningLevelNormal || sensor.Config.ClassifyBy != models.ClassifyByFeatures {
+		return
+	}
+
+	message := "Warning level " + strconv.Itoa(features.WarnLevel) + " " + features.Source + " features"
+	change, err := h.raiseAlert(sensor, models.AlertTypeThreshold, features.WarnLevel, features.Timestamp, message)
+	if err != nil {
+		log.Println("Failed to track alert for sensor", sensor.ID.Hex()+":", err)
+		return
+	}
+	h.notifyAlertChange(change)
+}
+
+// notifyAlertChange queues webhook notifications for a newly opened or escalated alert.
+func (h *Handler) notifyAlertChange(change store.AlertChange) {
+	if h.dispatcher == nil {
+		return
+	}
+
+	switch {
+	case change.Opened:
+		h.dispatcher.NotifyAlert(models.WebhookEventAlertOpened, change.Alert)
+	case change.Escalated:
+		h.dispatcher.NotifyAlert(models.WebhookEventAlertEscalated, change.Alert)
+	}
+}
+
+func (h *Handler) GetAlerts(c *gin.Context) {
+	// Pagination parameters
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	skip := (page - 1) * limit
+
+	query := store.AlertQuery{
+		Scope:  callerScope(c),
+		Status: c.Query("status"),
+		Type:   c.Query("type"),
+		Skip:   int64(skip),
+		Limit:  int64(limit),
+	}
+
+	if sensorID := c.Query("sensor_id"); sensorID != "" {
+		if id, err := primitive.ObjectIDFromHex(sensorID); err == nil {
+			query.SensorID = id
+		}
+	}
+
+	if minLevel := c.Query("min_level"); minLevel != "" {
+		if level, err := strconv.Atoi(minLevel); err == nil {
+			query.MinLevel = level
+		}
+	}
+
+	if startDate := c.Query("start_date"); startDate != "" {
+		if t, err := time.Parse(time.RFC3339, startDate); err == nil {
+			query.From = t
+		}
+	}
+
+	if endDate := c.Query("end_date"); endDate != "" {
+		if t, err := time.Parse(time.RFC3339, endDate); err == nil {
+			query.To = t
+		}
+	}
+
+	alerts, total, err := h.Stores.Alerts.List(context.Background(), query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": alerts,
+		"pagination": gin.H{
+			"total": total,
+			"page":  page,
+			"limit": limit,
+		},
+	})
+}
+
+func (h *Handler) GetAlert(c *gin.Context) {
+	id := c.Param("id")
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	alert, err := h.Stores.Alerts.Get(context.Background(), callerScope(c), objectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, alert)
+}
+
+// AcknowledgeAlert moves an open alert to acknowledged.
+func (h *Handler) AcknowledgeAlert(c *gin.Context) {
+	h.transitionAlert(c, []string{models.AlertStatusOpen}, models.AlertStatusAcknowledged, "acknowledged")
+}
+
+// ResolveAlert closes an open or acknowledged alert.
+func (h *Handler) ResolveAlert(c *gin.Context) {
+	h.transitionAlert(c, activeAlertStatuses, models.AlertStatusResolved, "resolved")
+}
+
+// transitionAlert moves an alert from one of the allowed statuses to status,
+// recording the acting user and optional comment in its history.
+func (h *Handler) transitionAlert(c *gin.Context, from []string, status string, verb string) {
+	id := c.Param("id")
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var request struct {
+		Comment string `json:"comment"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := middleware.GetUserID(c)
+	event := models.AlertEvent{Status: status, UserID: userID, Comment: request.Comment, At: time.Now()}
+
+	alert, err := h.Stores.Alerts.Transition(context.Background(), callerScope(c), objectID, from, event)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, alert)
+	case store.ErrConflict:
+		c.JSON(http.StatusConflict, gin.H{"error": "Alert cannot be " + verb + " in its current status"})
+	case store.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
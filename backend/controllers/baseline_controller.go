@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/analysis"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultBaselineWindow = 7 * 24 * time.Hour
+	// maxBaselineReadings bounds how many readings one retrain reads
+	maxBaselineReadings = 100000
+)
+
+// scoreVibration runs a new reading through its sensor's baseline, setting its anomaly
+// score, and reports whether the run of abnormal readings calls for an alert. Sensors
+// without a baseline are skipped; baseline failures are logged and never reject the reading.
+func (h *Handler) scoreVibration(sensor models.Sensor, vibration *models.VibrationData) bool {
+	vibration.AnomalyScore = nil
+
+	unlock := h.baselineLocks.lock(sensor.ID)
+	defer unlock()
+
+	baseline, err := h.Stores.Baselines.GetBySensor(context.Background(), sensor.ID)
+	if err == store.ErrNotFound {
+		return false
+	}
+	if err != nil {
+		log.Println("Failed to load baseline for sensor", sensor.ID.Hex()+":", err)
+		return false
+	}
+
+	score, alert := analysis.ObserveReading(&baseline, *vibration, time.Now())
+	vibration.AnomalyScore = score
+
+	if err := h.Stores.Baselines.Save(context.Background(), &baseline); err != nil {
+		log.Println("Failed to save baseline for sensor", sensor.ID.Hex()+":", err)
+	}
+	return alert
+}
+
+// trackAnomalyAlert opens or updates the anomaly alert of a reading that completed a run
+// of abnormal scores.
+func (h *Handler) trackAnomalyAlert(sensor models.Sensor, vibration models.VibrationData) {
+	message := fmt.Sprintf("Anomaly score %.1f above the baseline limit", *vibration.AnomalyScore)
+	change, err := h.raiseAlert(sensor, models.AlertTypeAnomaly, models.WarningLevelWarning, vibration.Timestamp, message)
+	if err != nil {
+		log.Println("Failed to track alert for sensor", sensor.ID.Hex()+":", err)
+		return
+	}
+	h.notifyAlertChange(change)
+}
+
+// baselineRequest is the body of a retrain or reset. Settings left out keep their
+// current values, or the defaults for a sensor without a baseline.
+type baselineRequest struct {
+	models.BaselineSettings
+
+	// Retrain: the training window of stored readings, by default the last week
+	From *time.Time `json:"from"`
+	To   *time.Time `json:"to"`
+
+	// Reset: how long to learn from incoming readings, by default a week
+	LearnFor string `json:"learn_for"`
+}
+
+// bindBaselineRequest reads an optional baseline request on top of the sensor's current settings.
+func (h *Handler) bindBaselineRequest(c *gin.Context, sensorID primitive.ObjectID) (baselineRequest, error) {
+	request := baselineRequest{BaselineSettings: analysis.DefaultBaselineSettings}
+	if existing, err := h.Stores.Baselines.GetBySensor(context.Background(), sensorID); err == nil {
+		request.BaselineSettings = existing.BaselineSettings
+	}
+
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			return request, err
+		}
+	}
+	return request, analysis.ValidateBaselineSettings(request.BaselineSettings)
+}
+
+// GetBaseline returns a sensor's baseline.
+func (h *Handler) GetBaseline(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if _, err := h.findScopedSensor(c, objectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found"})
+		return
+	}
+
+	baseline, err := h.Stores.Baselines.GetBySensor(context.Background(), objectID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Baseline not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, baseline)
+}
+
+// RetrainBaseline rebuilds a sensor's baseline from its stored readings in a training
+// window and starts scoring right away.
+func (h *Handler) RetrainBaseline(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if _, err := h.findScopedSensor(c, objectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found"})
+		return
+	}
+
+	request, err := h.bindBaselineRequest(c, objectID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	to := time.Now()
+	if request.To != nil {
+		to = *request.To
+	}
+	from := to.Add(-defaultBaselineWindow)
+	if request.From != nil {
+		from = *request.From
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	readings, total, err := h.Stores.Vibrations.List(context.Background(), store.VibrationQuery{
+		SensorIDs: []primitive.ObjectID{objectID},
+		From:      from,
+		To:        to,
+		Limit:     maxBaselineReadings,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if total > maxBaselineReadings {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Training window holds more than " + strconv.Itoa(maxBaselineReadings) + " readings; use a shorter window"})
+		return
+	}
+	if total < request.MinSamples {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Training window holds %d readings; at least %d are needed", total, request.MinSamples)})
+		return
+	}
+
+	// Train oldest first so an EWMA baseline ends on the most recent behaviour
+	sort.SliceStable(readings, func(i, j int) bool {
+		return readings[i].Timestamp.Before(readings[j].Timestamp)
+	})
+
+	unlock := h.baselineLocks.lock(objectID)
+	defer unlock()
+
+	now := time.Now()
+	baseline := analysis.NewBaseline(objectID, request.BaselineSettings, to)
+	for _, reading := range readings {
+		analysis.TrainBaseline(&baseline, reading)
+	}
+	baseline.Status = models.BaselineStatusReady
+	baseline.TrainedAt = now
+	baseline.UpdatedAt = now
+
+	if err := h.Stores.Baselines.Save(context.Background(), &baseline); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, baseline)
+}
+
+// ResetBaseline discards a sensor's learned statistics and learns again from the
+// readings that arrive during the next learn_for.
+func (h *Handler) ResetBaseline(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if _, err := h.findScopedSensor(c, objectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found"})
+		return
+	}
+
+	request, err := h.bindBaselineRequest(c, objectID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	learnFor := defaultBaselineWindow
+	if request.LearnFor != "" {
+		learnFor, err = parseInterval(request.LearnFor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid learn_for: " + request.LearnFor})
+			return
+		}
+	}
+
+	unlock := h.baselineLocks.lock(objectID)
+	defer unlock()
+
+	now := time.Now()
+	baseline := analysis.NewBaseline(objectID, request.BaselineSettings, now.Add(learnFor))
+	baseline.UpdatedAt = now
+
+	if err := h.Stores.Baselines.Save(context.Background(), &baseline); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, baseline)
+}
@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/analysis"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultFeatureWindow = time.Hour
+	// maxFeatureWindowReadings bounds how many readings one window feature set reads
+	maxFeatureWindowReadings = 10000
+)
+
+// storeFeatures stores a feature set and raises its alert when the sensor is classified by features.
+func (h *Handler) storeFeatures(sensor models.Sensor, features *models.FeatureSet) error {
+	if err := h.Stores.Features.Create(context.Background(), features); err != nil {
+		return errInsertFailed
+	}
+
+	h.trackFeatureAlert(sensor, *features)
+	return nil
+}
+
+// storeWindowFeatures computes and stores the features of readings, which need not be in time order.
+func (h *Handler) storeWindowFeatures(sensor models.Sensor, readings []models.VibrationData) (models.FeatureSet, error) {
+	ordered := make([]models.VibrationData, len(readings))
+	copy(ordered, readings)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Timestamp.Before(ordered[j].Timestamp)
+	})
+
+	features, err := analysis.WindowFeatures(sensor.Config, ordered)
+	if err != nil {
+		return features, err
+	}
+	return features, h.storeFeatures(sensor, &features)
+}
+
+// ComputeWindowFeatures computes the features of a sensor's readings in [from, to],
+// by default the last hour, and stores them.
+func (h *Handler) ComputeWindowFeatures(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	sensor, err := h.findScopedSensor(c, objectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found"})
+		return
+	}
+
+	query := store.VibrationQuery{
+		SensorIDs: []primitive.ObjectID{objectID},
+		To:        time.Now(),
+		Limit:     maxFeatureWindowReadings,
+	}
+	if raw := c.Query("to"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be an RFC 3339 time"})
+			return
+		}
+		query.To = t
+	}
+	query.From = query.To.Add(-defaultFeatureWindow)
+	if raw := c.Query("from"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be an RFC 3339 time"})
+			return
+		}
+		query.From = t
+	}
+	if !query.From.Before(query.To) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	readings, total, err := h.Stores.Vibrations.List(context.Background(), query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if total > maxFeatureWindowReadings {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Window holds more than " + strconv.Itoa(maxFeatureWindowReadings) + " readings; use a shorter window"})
+		return
+	}
+
+	features, err := h.storeWindowFeatures(sensor, readings)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == errInsertFailed {
+			status = http.StatusInternalServerError
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, features)
+}
+
+// GetSensorFeatures lists a sensor's feature sets, newest first, optionally of one source.
+func (h *Handler) GetSensorFeatures(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if _, err := h.findScopedSensor(c, objectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	query := store.FeatureQuery{
+		SensorID: objectID,
+		Source:   c.Query("source"),
+		Skip:     int64((page - 1) * limit),
+		Limit:    int64(limit),
+	}
+
+	if from := c.Query("from"); from != "" {
+		if t, err := time.Parse(time.RFC3339, from); err == nil {
+			query.From = t
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		if t, err := time.Parse(time.RFC3339, to); err == nil {
+			query.To = t
+		}
+	}
+
+	features, total, err := h.Stores.Features.List(context.Background(), query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": features,
+		"pagination": gin.H{
+			"total": total,
+			"page":  page,
+			"limit": limit,
+		},
+	})
+}
+
+// GetFeatureSet returns one feature set.
+func (h *Handler) GetFeatureSet(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	features, err := h.Stores.Features.Get(context.Background(), objectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Feature set not found"})
+		return
+	}
+
+	if _, err := h.findScopedSensor(c, features.SensorID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Feature set not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, features)
+}
@@ -0,0 +1,630 @@
+package controllers
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/middleware"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// firmwareFormOverhead is the room left in an upload for the form fields and multipart
+// framing around the image.
+const firmwareFormOverhead = 64 << 10
+
+// UploadFirmware stores a firmware image posted as multipart form data: the binary in
+// "file", its "version" and base64 "signature", and optionally "notes" and a hex SHA-256
+// "checksum" to verify the upload against.
+func (h *Handler) UploadFirmware(c *gin.Context) {
+	cfg := h.Config
+
+	// Bound the whole request before any of it is parsed, so an oversized upload is
+	// cut off rather than spooled to disk
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, cfg.FirmwareMaxSize+firmwareFormOverhead)
+	if _, err := c.MultipartForm(); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Firmware images may not exceed %d bytes", cfg.FirmwareMaxSize)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Expected multipart form data"})
+		return
+	}
+
+	version := strings.TrimSpace(c.PostForm("version"))
+	signature := strings.TrimSpace(c.PostForm("signature"))
+	if version == "" || signature == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "version and signature are required"})
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	if header.Size > cfg.FirmwareMaxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Firmware images may not exceed %d bytes", cfg.FirmwareMaxSize)})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+	if expected := c.PostForm("checksum"); expected != "" && !strings.EqualFold(expected, checksum) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "checksum does not match the uploaded file"})
+		return
+	}
+
+	signatureBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "signature must be base64 encoded"})
+		return
+	}
+	if cfg.FirmwarePublicKey != "" {
+		key, _ := hex.DecodeString(cfg.FirmwarePublicKey)
+		if !ed25519.Verify(key, data, signatureBytes) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "signature does not verify against the firmware signing key"})
+			return
+		}
+	}
+
+	if _, err := h.Stores.Firmware.GetByVersion(context.Background(), version); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Firmware version already exists"})
+		return
+	}
+
+	userID, _ := middleware.GetUserID(c)
+	image := models.FirmwareImage{
+		Version:   version,
+		Filename:  header.Filename,
+		Size:      int64(len(data)),
+		Checksum:  checksum,
+		Signature: signature,
+		Notes:     c.PostForm("notes"),
+		CreatedBy: userID,
+		CreatedAt: time.Now(),
+	}
+	if err := h.Stores.Firmware.Create(context.Background(), &image, data); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, image)
+}
+
+func (h *Handler) GetFirmwareImages(c *gin.Context) {
+	images, err := h.Stores.Firmware.List(context.Background())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, images)
+}
+
+func (h *Handler) GetFirmwareImage(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	image, err := h.Stores.Firmware.Get(context.Background(), objectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Firmware image not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, image)
+}
+
+// DeleteFirmwareImage removes an image that no running or paused campaign rolls out.
+func (h *Handler) DeleteFirmwareImage(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	campaigns, err := h.Stores.Campaigns.List(context.Background(), "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for _, campaign := range campaigns {
+		if campaign.FirmwareID == objectID && campaignActive(campaign) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Firmware image is used by an active campaign"})
+			return
+		}
+	}
+
+	err = h.Stores.Firmware.Delete(context.Background(), objectID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Firmware image not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Firmware image deleted successfully"})
+}
+
+// campaignActive reports whether a campaign may still offer its update to devices.
+func campaignActive(campaign models.FirmwareCampaign) bool {
+	return campaign.Status == models.CampaignStatusRunning || campaign.Status == models.CampaignStatusPaused
+}
+
+// targetedBy reports whether target selects sensor.
+func targetedBy(target models.CampaignTarget, sensor models.Sensor) bool {
+	if !target.OrganizationID.IsZero() && sensor.OrganizationID != target.OrganizationID {
+		return false
+	}
+	if target.Location != "" && sensor.Location != target.Location {
+		return false
+	}
+	if len(target.Tags) > 0 && !slices.ContainsFunc(target.Tags, func(tag string) bool { return slices.Contains(sensor.Tags, tag) }) {
+		return false
+	}
+	return true
+}
+
+// campaignProgress counts deployments by status.
+func campaignProgress(deployments []models.FirmwareDeployment) models.CampaignProgress {
+	progress := models.CampaignProgress{Total: len(deployments), ByStatus: map[string]int{}}
+	for _, deployment := range deployments {
+		progress.ByStatus[deployment.Status]++
+		if deployment.Finished() {
+			progress.Finished++
+		}
+	}
+	if progress.Finished > 0 {
+		progress.FailureRate = float64(progress.ByStatus[models.DeploymentFailed]) / float64(progress.Finished)
+	}
+	return progress
+}
+
+// CreateFirmwareCampaign starts rolling a firmware image out to the sensors its target
+// selects. A random canary_percent of them is offered the update first; the rest follow
+// once every canary has finished. Without a signing key to verify images against, no
+// campaign is started.
+func (h *Handler) CreateFirmwareCampaign(c *gin.Context) {
+	cfg := h.Config
+	if cfg.FirmwarePublicKey == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Firmware signatures are not verified; set FIRMWARE_PUBLIC_KEY before starting a rollout"})
+		return
+	}
+
+	var request struct {
+		Name             string                `json:"name" binding:"required"`
+		FirmwareID       primitive.ObjectID    `json:"firmware_id" binding:"required"`
+		Target           models.CampaignTarget `json:"target"`
+		CanaryPercent    *float64              `json:"canary_percent"`
+		FailureThreshold *float64              `json:"failure_threshold"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	canaryPercent, failureThreshold := cfg.FirmwareCanaryPercent, cfg.FirmwareFailureThreshold
+	if request.CanaryPercent != nil {
+		canaryPercent = *request.CanaryPercent
+	}
+	if request.FailureThreshold != nil {
+		failureThreshold = *request.FailureThreshold
+	}
+	if canaryPercent < 0 || canaryPercent > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "canary_percent must be between 0 and 100"})
+		return
+	}
+	if failureThreshold < 0 || failureThreshold > 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failure_threshold must be between 0 and 1"})
+		return
+	}
+
+	image, err := h.Stores.Firmware.Get(context.Background(), request.FirmwareID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Firmware image not found"})
+		return
+	}
+
+	sensors, err := h.Stores.Sensors.List(context.Background(), store.Scope{Unrestricted: true})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var targeted []models.Sensor
+	for _, sensor := range sensors {
+		if targetedBy(request.Target, sensor) {
+			targeted = append(targeted, sensor)
+		}
+	}
+	if len(targeted) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The target selects no sensors"})
+		return
+	}
+	rand.Shuffle(len(targeted), func(i, j int) { targeted[i], targeted[j] = targeted[j], targeted[i] })
+
+	canaries := int(math.Ceil(float64(len(targeted)) * canaryPercent / 100))
+	stage := models.CampaignStageCanary
+	if canaries == 0 || canaries == len(targeted) {
+		stage = models.CampaignStageFull
+	}
+
+	now := time.Now()
+	userID, _ := middleware.GetUserID(c)
+	campaign := models.FirmwareCampaign{
+		Name:             request.Name,
+		FirmwareID:       image.ID,
+		FirmwareVersion:  image.Version,
+		Target:           request.Target,
+		CanaryPercent:    canaryPercent,
+		FailureThreshold: failureThreshold,
+		Status:           models.CampaignStatusRunning,
+		Stage:            stage,
+		Sensors:          len(targeted),
+		CanarySensors:    canaries,
+		CreatedBy:        userID,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	if err := h.Stores.Campaigns.Create(context.Background(), &campaign); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	deployments := make([]models.FirmwareDeployment, len(targeted))
+	for i, sensor := range targeted {
+		deployments[i] = models.FirmwareDeployment{
+			CampaignID: campaign.ID,
+			SensorID:   sensor.ID,
+			Canary:     i < canaries,
+			Status:     models.DeploymentPending,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+	}
+	if err := h.Stores.Deployments.CreateMany(context.Background(), deployments); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, campaign)
+}
+
+// GetFirmwareCampaigns lists campaigns, newest first, optionally only those in one status.
+func (h *Handler) GetFirmwareCampaigns(c *gin.Context) {
+	campaigns, err := h.Stores.Campaigns.List(context.Background(), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, campaigns)
+}
+
+// GetFirmwareCampaign returns a campaign with the progress of its deployments.
+func (h *Handler) GetFirmwareCampaign(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	campaign, err := h.Stores.Campaigns.Get(context.Background(), objectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Campaign not found"})
+		return
+	}
+
+	deployments, err := h.Stores.Deployments.ListByCampaign(context.Background(), campaign.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"campaign": campaign,
+		"progress": campaignProgress(deployments),
+	})
+}
+
+// GetFirmwareCampaignDeployments lists the per-sensor state of a campaign, optionally
+// only the deployments in one status.
+func (h *Handler) GetFirmwareCampaignDeployments(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if _, err := h.Stores.Campaigns.Get(context.Background(), objectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Campaign not found"})
+		return
+	}
+
+	deployments, err := h.Stores.Deployments.ListByCampaign(context.Background(), objectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	matching := []models.FirmwareDeployment{}
+	for _, deployment := range deployments {
+		if status := c.Query("status"); status == "" || deployment.Status == status {
+			matching = append(matching, deployment)
+		}
+	}
+
+	c.JSON(http.StatusOK, matching)
+}
+
+func (h *Handler) PauseFirmwareCampaign(c *gin.Context) {
+	h.transitionCampaign(c, []string{models.CampaignStatusRunning}, models.CampaignStatusPaused, "", "Paused by operator", "paused")
+}
+
+func (h *Handler) ResumeFirmwareCampaign(c *gin.Context) {
+	h.transitionCampaign(c, []string{models.CampaignStatusPaused}, models.CampaignStatusRunning, "", "", "resumed")
+}
+
+func (h *Handler) CancelFirmwareCampaign(c *gin.Context) {
+	h.transitionCampaign(c, []string{models.CampaignStatusRunning, models.CampaignStatusPaused}, models.CampaignStatusCancelled, "", "", "cancelled")
+}
+
+// PromoteFirmwareCampaign offers the update to every targeted sensor without waiting
+// for the canaries to finish.
+func (h *Handler) PromoteFirmwareCampaign(c *gin.Context) {
+	h.transitionCampaign(c, []string{models.CampaignStatusRunning}, models.CampaignStatusRunning, models.CampaignStageFull, "", "promoted")
+}
+
+// transitionCampaign moves the campaign in the path from one of the from statuses to
+// status and stage, answering with the updated campaign.
+func (h *Handler) transitionCampaign(c *gin.Context, from []string, status, stage, reason, verb string) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	campaign, err := h.Stores.Campaigns.Transition(context.Background(), objectID, from, status, stage, reason, time.Now())
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, campaign)
+	case store.ErrConflict:
+		c.JSON(http.StatusConflict, gin.H{"error": "Campaign cannot be " + verb + " in its current status"})
+	case store.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Campaign not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// evaluateCampaign advances a running campaign after a device finished: it pauses when
+// the failure rate rises above the threshold, moves on from the canary stage once every
+// canary has finished, and completes once every deployment has.
+func (h *Handler) evaluateCampaign(ctx context.Context, campaignID primitive.ObjectID, now time.Time) error {
+	campaign, err := h.Stores.Campaigns.Get(ctx, campaignID)
+	if err != nil {
+		return err
+	}
+	if campaign.Status != models.CampaignStatusRunning {
+		return nil
+	}
+
+	deployments, err := h.Stores.Deployments.ListByCampaign(ctx, campaign.ID)
+	if err != nil {
+		return err
+	}
+	progress := campaignProgress(deployments)
+
+	running := []string{models.CampaignStatusRunning}
+	switch {
+	case progress.Finished > 0 && progress.FailureRate > campaign.FailureThreshold:
+		reason := fmt.Sprintf("Failure rate %.0f%% of %d finished devices exceeds %.0f%%",
+			progress.FailureRate*100, progress.Finished, campaign.FailureThreshold*100)
+		_, err = h.Stores.Campaigns.Transition(ctx, campaign.ID, running, models.CampaignStatusPaused, "", reason, now)
+		if err == nil {
+			log.Println("Firmware campaign", campaign.ID.Hex(), "paused:", reason)
+		}
+	case progress.Finished == progress.Total:
+		_, err = h.Stores.Campaigns.Transition(ctx, campaign.ID, running, models.CampaignStatusCompleted, "", "", now)
+	case campaign.Stage == models.CampaignStageCanary:
+		for _, deployment := range deployments {
+			if deployment.Canary && !deployment.Finished() {
+				return nil
+			}
+		}
+		_, err = h.Stores.Campaigns.Transition(ctx, campaign.ID, running, models.CampaignStatusRunning, models.CampaignStageFull, "", now)
+	}
+	if err == store.ErrConflict {
+		// Changed by an operator or a concurrent report in the meantime
+		return nil
+	}
+	return err
+}
+
+// offeredDeployment returns the oldest unfinished deployment of a sensor that a running
+// campaign currently offers it, with its campaign. ok is false when there is none.
+func (h *Handler) offeredDeployment(ctx context.Context, sensor models.Sensor) (models.FirmwareDeployment, models.FirmwareCampaign, bool, error) {
+	deployments, err := h.Stores.Deployments.ListBySensor(ctx, sensor.ID)
+	if err != nil {
+		return models.FirmwareDeployment{}, models.FirmwareCampaign{}, false, err
+	}
+
+	for _, deployment := range deployments {
+		if deployment.Finished() {
+			continue
+		}
+		campaign, err := h.Stores.Campaigns.Get(ctx, deployment.CampaignID)
+		if err != nil {
+			continue
+		}
+		if campaign.Status == models.CampaignStatusRunning && (campaign.Stage == models.CampaignStageFull || deployment.Canary) {
+			return deployment, campaign, true, nil
+		}
+	}
+	return models.FirmwareDeployment{}, models.FirmwareCampaign{}, false, nil
+}
+
+// GetDeviceFirmware tells a device authenticated with its sensor token whether a firmware
+// update is offered to it, and where to download it.
+func (h *Handler) GetDeviceFirmware(c *gin.Context) {
+	sensor, ok := middleware.GetSensor(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sensor not authenticated"})
+		return
+	}
+	h.markSeen(sensor, nil)
+
+	deployment, campaign, offered, err := h.offeredDeployment(context.Background(), sensor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !offered {
+		c.JSON(http.StatusOK, gin.H{"update_available": false})
+		return
+	}
+
+	image, err := h.Stores.Firmware.Get(context.Background(), campaign.FirmwareID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Firmware image of the campaign is missing"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"update_available": true,
+		"campaign_id":      campaign.ID,
+		"status":           deployment.Status,
+		"version":          image.Version,
+		"size":             image.Size,
+		"checksum":         image.Checksum,
+		"signature":        image.Signature,
+		"download_url":     "/device/firmware/" + campaign.ID.Hex() + "/download",
+	})
+}
+
+// DownloadDeviceFirmware serves the firmware binary of a campaign that currently offers
+// it to the authenticated device.
+func (h *Handler) DownloadDeviceFirmware(c *gin.Context) {
+	sensor, ok := middleware.GetSensor(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sensor not authenticated"})
+		return
+	}
+
+	_, campaign, offered, err := h.offeredDeployment(context.Background(), sensor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !offered || campaign.ID.Hex() != c.Param("id") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No firmware update is offered to this sensor"})
+		return
+	}
+
+	image, err := h.Stores.Firmware.Get(context.Background(), campaign.FirmwareID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Firmware image not found"})
+		return
+	}
+	data, err := h.Stores.Firmware.Data(context.Background(), image.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+image.Filename+`"`)
+	c.Header("X-Firmware-Version", image.Version)
+	c.Header("X-Firmware-Checksum", image.Checksum)
+	c.Data(http.StatusOK, "application/octet-stream", data)
+}
+
+// ReportDeviceFirmware records the progress of the authenticated device in a campaign:
+// downloading, downloaded, installing, succeeded or failed with an error.
+func (h *Handler) ReportDeviceFirmware(c *gin.Context) {
+	sensor, ok := middleware.GetSensor(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sensor not authenticated"})
+		return
+	}
+
+	campaignID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var request struct {
+		Status string `json:"status" binding:"required"`
+		Error  string `json:"error"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	switch request.Status {
+	case models.DeploymentDownloading, models.DeploymentDownloaded, models.DeploymentInstalling,
+		models.DeploymentSucceeded, models.DeploymentFailed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be downloading, downloaded, installing, succeeded or failed"})
+		return
+	}
+	if request.Status != models.DeploymentFailed {
+		request.Error = ""
+	}
+
+	now := time.Now()
+	deployment, err := h.Stores.Deployments.SetStatus(context.Background(), campaignID, sensor.ID, request.Status, request.Error, now)
+	switch err {
+	case nil:
+	case store.ErrConflict:
+		c.JSON(http.StatusConflict, gin.H{"error": "The update has already finished on this sensor"})
+		return
+	case store.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor is not part of the campaign"})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.markSeen(sensor, nil)
+
+	if deployment.Finished() {
+		if err := h.evaluateCampaign(context.Background(), campaignID, now); err != nil {
+			log.Println("Failed to evaluate firmware campaign", campaignID.Hex()+":", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, deployment)
+}
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/analysis"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/config"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/rollup"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var errReadFailed = errors.New("Failed to read readings")
+
+// ForecastRequest selects the velocity RMS series a forecast is fitted to and the
+// horizon within which a projected Critical crossing calls for a predictive alert.
+type ForecastRequest struct {
+	Method   string
+	Window   time.Duration
+	Interval time.Duration
+	Horizon  time.Duration
+}
+
+// ForecastRequestFromConfig returns the configured forecast defaults.
+func ForecastRequestFromConfig(cfg *config.Config) ForecastRequest {
+	return ForecastRequest{
+		Method:   cfg.ForecastMethod,
+		Window:   cfg.ForecastWindow,
+		Interval: cfg.ForecastBucket,
+		Horizon:  cfg.ForecastHorizon,
+	}
+}
+
+// ForecastSensor fits a trend to the sensor's velocity RMS over the request window ending
+// at to, read from the rollups where possible. Failures to read the readings wrap
+// errReadFailed; analysis.ErrTooFewPoints means the window holds too little data.
+func (h *Handler) ForecastSensor(ctx context.Context, sensor models.Sensor, request ForecastRequest, to time.Time) (models.Forecast, error) {
+	query := store.AggregateQuery{
+		SensorID: sensor.ID,
+		Interval: request.Interval,
+		From:     to.Add(-request.Window),
+		To:       to,
+	}
+
+	opts := rollup.OptionsFromConfig(h.Config)
+	summaries, source, err := rollup.Aggregate(ctx, h.Stores.Vibrations, h.Stores.Rollups, opts, query)
+	if err != nil {
+		return models.Forecast{}, fmt.Errorf("%w: %v", errReadFailed, err)
+	}
+
+	forecast, err := analysis.ForecastVelocity(sensor.Config, request.Method, summaries, request.Interval, to)
+	if err != nil {
+		return models.Forecast{}, err
+	}
+	forecast.SensorID = sensor.ID
+	forecast.From = query.From
+	forecast.Source = source
+	forecast.Horizon = request.Horizon.String()
+
+	for _, threshold := range forecast.Thresholds {
+		if threshold.Level == models.WarningLevelCritical && !threshold.Crossed && threshold.Hours != nil {
+			forecast.WithinHorizon = *threshold.Hours <= request.Horizon.Hours()
+		}
+	}
+	return forecast, nil
+}
+
+// TrackPredictiveAlert opens or updates the predictive alert of a sensor whose forecast
+// reaches Critical within the horizon. Sensors already at Critical are left to their
+// threshold alerts.
+func (h *Handler) TrackPredictiveAlert(sensor models.Sensor, forecast models.Forecast) {
+	if !forecast.WithinHorizon {
+		return
+	}
+
+	var critical models.ThresholdForecast
+	for _, threshold := range forecast.Thresholds {
+		if threshold.Level == models.WarningLevelCritical {
+			critical = threshold
+		}
+	}
+
+	message := fmt.Sprintf("Velocity RMS %.2f mm/s projected to reach Critical (%.2f mm/s) in %.1f hours",
+		forecast.Current, critical.Velocity, *critical.Hours)
+	change, err := h.raiseAlert(sensor, models.AlertTypePredictive, models.WarningLevelWarning, forecast.To, message)
+	if err != nil {
+		log.Println("Failed to track alert for sensor", sensor.ID.Hex()+":", err)
+		return
+	}
+	h.notifyAlertChange(change)
+}
+
+// GetSensorForecast projects when a sensor's velocity RMS trend will cross each of its
+// warning thresholds. The method, window and interval default to the configured ones.
+func (h *Handler) GetSensorForecast(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	sensor, err := h.findScopedSensor(c, objectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found"})
+		return
+	}
+
+	request := ForecastRequestFromConfig(h.Config)
+	if method := c.Query("method"); method != "" {
+		request.Method = method
+	}
+	for key, target := range map[string]*time.Duration{"window": &request.Window, "interval": &request.Interval} {
+		if raw := c.Query(key); raw != "" {
+			value, err := parseInterval(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + key + ": " + raw})
+				return
+			}
+			*target = value
+		}
+	}
+	if request.Window/request.Interval > config.MaxForecastBuckets {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Window spans more than %d intervals; use a longer interval", config.MaxForecastBuckets)})
+		return
+	}
+
+	forecast, err := h.ForecastSensor(context.Background(), sensor, request, time.Now())
+	switch {
+	case errors.Is(err, errReadFailed):
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	case err == analysis.ErrTooFewPoints:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, forecast)
+}
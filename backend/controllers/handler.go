@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"sync"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/config"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/leak"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/webhooks"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Handler serves the HTTP API on top of the injected stores and configuration.
+type Handler struct {
+	Stores     *store.Stores
+	Config     *config.Config
+	dispatcher *webhooks.Dispatcher
+
+	// LeakJob runs on-demand leak analyses of pipe segments; they are refused while it is nil
+	LeakJob *leak.Job
+
+	// Serializes the read-score-save cycle of each sensor's baseline
+	baselineLocks *sensorLocks
+}
+
+// NewHandler returns a handler using stores and cfg. dispatcher may be nil, in which
+// case alert changes are not sent to webhooks.
+func NewHandler(stores *store.Stores, cfg *config.Config, dispatcher *webhooks.Dispatcher) *Handler {
+	return &Handler{Stores: stores, Config: cfg, dispatcher: dispatcher, baselineLocks: newSensorLocks()}
+}
+
+// sensorLocks hands out one mutex per sensor.
+type sensorLocks struct {
+	mu    sync.Mutex
+	locks map[primitive.ObjectID]*sync.Mutex
+}
+
+func newSensorLocks() *sensorLocks {
+	return &sensorLocks{locks: map[primitive.ObjectID]*sync.Mutex{}}
+}
+
+// lock locks the mutex of sensorID and returns its unlock function.
+func (l *sensorLocks) lock(sensorID primitive.ObjectID) func() {
+	l.mu.Lock()
+	lock, ok := l.locks[sensorID]
+	if !ok {
+		lock = &sync.Mutex{}
+		l.locks[sensorID] = lock
+	}
+	l.mu.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/config"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/middleware"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SensorStatusUnknown is reported for sensors that have never been seen.
+const SensorStatusUnknown = "unknown"
+
+// ConnectivityOptions decide when a silent sensor counts as offline.
+type ConnectivityOptions struct {
+	ReportInterval  time.Duration // For sensors without a configured report_interval
+	OfflineMultiple float64       // Report intervals of silence before a sensor is offline
+}
+
+// ConnectivityOptionsFromConfig builds connectivity options from the application config.
+func ConnectivityOptionsFromConfig(cfg *config.Config) ConnectivityOptions {
+	return ConnectivityOptions{ReportInterval: cfg.SensorReportInterval, OfflineMultiple: cfg.OfflineMultiple}
+}
+
+// reportInterval returns how often the sensor is expected to report.
+func (o ConnectivityOptions) reportInterval(sensor models.Sensor) time.Duration {
+	if sensor.Config.ReportInterval > 0 {
+		return time.Duration(sensor.Config.ReportInterval) * time.Second
+	}
+	return o.ReportInterval
+}
+
+// OfflineAfter returns how long the sensor may stay silent before it is offline.
+func (o ConnectivityOptions) OfflineAfter(sensor models.Sensor) time.Duration {
+	return time.Duration(float64(o.reportInterval(sensor)) * o.OfflineMultiple)
+}
+
+// markSeen records contact from an authenticated sensor. Only requests the device
+// itself makes count: readings a user uploads for it, which may be historical, say
+// nothing about whether it is still online. Failures are logged rather than returned
+// so they never reject the data the sensor sent.
+func (h *Handler) markSeen(sensor models.Sensor, telemetry *models.SensorTelemetry) {
+	if err := h.Stores.Sensors.MarkSeen(context.Background(), sensor.ID, time.Now(), telemetry); err != nil {
+		log.Println("Failed to record contact from sensor", sensor.ID.Hex()+":", err)
+	}
+}
+
+// CheckConnectivity takes an online sensor offline once it has been silent for longer
+// than opts allow, and opens its connectivity alert. It reports whether it did.
+func (h *Handler) CheckConnectivity(ctx context.Context, sensor models.Sensor, opts ConnectivityOptions, now time.Time) (bool, error) {
+	if sensor.Status != models.SensorStatusOnline || sensor.LastSeenAt == nil {
+		return false, nil
+	}
+
+	offlineAfter := opts.OfflineAfter(sensor)
+	err := h.Stores.Sensors.MarkOffline(ctx, sensor.ID, now.Add(-offlineAfter), now)
+	if err == store.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	message := fmt.Sprintf("No report since %s, expected every %s", sensor.LastSeenAt.UTC().Format(time.RFC3339), opts.reportInterval(sensor))
+	change, err := h.raiseAlert(sensor, models.AlertTypeConnectivity, models.WarningLevelWarning, now, message)
+	if err != nil {
+		return true, err
+	}
+	h.notifyAlertChange(change)
+	return true, nil
+}
+
+// IngestHeartbeat records a heartbeat posted by a device authenticated with its sensor
+// token, keeping its telemetry as the sensor's latest.
+func (h *Handler) IngestHeartbeat(c *gin.Context) {
+	sensor, ok := middleware.GetSensor(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sensor not authenticated"})
+		return
+	}
+
+	var heartbeat models.Heartbeat
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&heartbeat); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	heartbeat.ID = primitive.NilObjectID
+	heartbeat.SensorID = sensor.ID
+	if heartbeat.Timestamp.IsZero() {
+		heartbeat.Timestamp = time.Now()
+	}
+
+	if err := h.Stores.Heartbeats.Create(context.Background(), &heartbeat); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	telemetry := heartbeat.SensorTelemetry
+	h.markSeen(sensor, &telemetry)
+
+	c.JSON(http.StatusCreated, heartbeat)
+}
+
+// sensorStatus returns the connectivity status of a sensor, unknown when never seen.
+func sensorStatus(sensor models.Sensor) string {
+	if sensor.Status == "" {
+		return SensorStatusUnknown
+	}
+	return sensor.Status
+}
+
+// GetSensorStatus returns whether a sensor is online, when it was last seen and its
+// latest telemetry.
+func (h *Handler) GetSensorStatus(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	sensor, err := h.findScopedSensor(c, objectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found"})
+		return
+	}
+
+	opts := ConnectivityOptionsFromConfig(h.Config)
+	c.JSON(http.StatusOK, gin.H{
+		"sensor_id":       sensor.ID,
+		"status":          sensorStatus(sensor),
+		"last_seen_at":    sensor.LastSeenAt,
+		"offline_since":   sensor.OfflineSince,
+		"report_interval": opts.reportInterval(sensor).String(),
+		"offline_after":   opts.OfflineAfter(sensor).String(),
+		"telemetry":       sensor.Telemetry,
+	})
+}
+
+// GetSensorTelemetry lists a sensor's heartbeats, newest first.
+func (h *Handler) GetSensorTelemetry(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if _, err := h.findScopedSensor(c, objectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	query := store.HeartbeatQuery{SensorID: objectID, Skip: int64((page - 1) * limit), Limit: int64(limit)}
+
+	if from := c.Query("from"); from != "" {
+		if t, err := time.Parse(time.RFC3339, from); err == nil {
+			query.From = t
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		if t, err := time.Parse(time.RFC3339, to); err == nil {
+			query.To = t
+		}
+	}
+
+	heartbeats, total, err := h.Stores.Heartbeats.List(context.Background(), query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": heartbeats,
+		"pagination": gin.H{
+			"total": total,
+			"page":  page,
+			"limit": limit,
+		},
+	})
+}
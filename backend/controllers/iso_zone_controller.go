@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/analysis"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// defaultISOZoneTables are seeded into the zone table store at startup.
+// Further tables, such as the ISO 20816 limits for pipeline pumps and compressors,
+// are added through the API without code changes.
+var defaultISOZoneTables = []models.ISOZoneTable{
+	{Class: "iso10816-1:class-i", Standard: "ISO 10816-1", Description: "Small machines, up to 15 kW", AB: 0.71, BC: 1.8, CD: 4.5},
+	{Class: "iso10816-1:class-ii", Standard: "ISO 10816-1", Description: "Medium machines, 15 kW to 75 kW", AB: 1.12, BC: 2.8, CD: 7.1},
+	{Class: "iso10816-1:class-iii", Standard: "ISO 10816-1", Description: "Large machines on rigid foundations", AB: 1.8, BC: 4.5, CD: 11.2},
+	{Class: "iso10816-1:class-iv", Standard: "ISO 10816-1", Description: "Large machines on flexible foundations", AB: 2.8, BC: 7.1, CD: 18.0},
+	{Class: "iso10816-3:group1-rigid", Standard: "ISO 10816-3", Description: "Group 1, 300 kW to 50 MW, rigid foundation", AB: 2.3, BC: 4.5, CD: 7.1},
+	{Class: "iso10816-3:group1-flexible", Standard: "ISO 10816-3", Description: "Group 1, 300 kW to 50 MW, flexible foundation", AB: 3.5, BC: 7.1, CD: 11.0},
+	{Class: "iso10816-3:group2-rigid", Standard: "ISO 10816-3", Description: "Group 2, 15 kW to 300 kW, rigid foundation", AB: 1.4, BC: 2.8, CD: 4.5},
+	{Class: "iso10816-3:group2-flexible", Standard: "ISO 10816-3", Description: "Group 2, 15 kW to 300 kW, flexible foundation", AB: 2.3, BC: 4.5, CD: 7.1},
+}
+
+// InitializeISOZoneTables inserts any default zone table whose class is not stored yet.
+func (h *Handler) InitializeISOZoneTables() error {
+	for _, table := range defaultISOZoneTables {
+		_, err := h.Stores.ISOZones.GetByClass(context.Background(), table.Class)
+		if err == nil {
+			continue
+		}
+		if err != store.ErrNotFound {
+			return err
+		}
+
+		if err := h.Stores.ISOZones.Create(context.Background(), &table); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *Handler) GetISOZoneTables(c *gin.Context) {
+	tables, err := h.Stores.ISOZones.List(context.Background())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tables)
+}
+
+func (h *Handler) CreateISOZoneTable(c *gin.Context) {
+	var table models.ISOZoneTable
+	if err := c.ShouldBindJSON(&table); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := analysis.ValidateISOZoneTable(table); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := h.Stores.ISOZones.GetByClass(context.Background(), table.Class); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Machine class already exists"})
+		return
+	}
+
+	table.ID = primitive.NilObjectID
+	if err := h.Stores.ISOZones.Create(context.Background(), &table); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, table)
+}
+
+func (h *Handler) UpdateISOZoneTable(c *gin.Context) {
+	id := c.Param("id")
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var table models.ISOZoneTable
+	if err := c.ShouldBindJSON(&table); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := analysis.ValidateISOZoneTable(table); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The class name is the key sensors refer to, so it cannot be renamed
+	table.ID = objectID
+	err = h.Stores.ISOZones.Update(context.Background(), table)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Zone table not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Zone table updated successfully"})
+}
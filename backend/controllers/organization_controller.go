@@ -0,0 +1,228 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	errOrganizationRequired = errors.New("organization_id is required")
+	errOrganizationNotFound = errors.New("Organization not found")
+	errInvalidMinLevel      = errors.New("min_level must be between 1 and 4")
+	errInvalidSensorFilter  = errors.New("sensor_ids must reference sensors of the organization")
+)
+
+func (h *Handler) CreateOrganization(c *gin.Context) {
+	var organization models.Organization
+	if err := c.ShouldBindJSON(&organization); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, err := h.Stores.Organizations.GetByName(context.Background(), organization.Name)
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Organization name already exists"})
+		return
+	}
+	if err != store.ErrNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	organization.ID = primitive.NilObjectID
+	organization.CreatedAt = time.Now()
+	if err := h.Stores.Organizations.Create(context.Background(), &organization); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, organization)
+}
+
+func (h *Handler) GetOrganizations(c *gin.Context) {
+	organizations, err := h.Stores.Organizations.List(context.Background())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, organizations)
+}
+
+func (h *Handler) GetOrganization(c *gin.Context) {
+	id := c.Param("id")
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	organization, err := h.Stores.Organizations.Get(context.Background(), objectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, organization)
+}
+
+func (h *Handler) UpdateOrganization(c *gin.Context) {
+	id := c.Param("id")
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var organization models.Organization
+	if err := c.ShouldBindJSON(&organization); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	organization.ID = objectID
+	err = h.Stores.Organizations.Update(context.Background(), organization)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Organization updated successfully"})
+}
+
+// DeleteOrganization removes an organization that no longer owns users or sensors.
+func (h *Handler) DeleteOrganization(c *gin.Context) {
+	id := c.Param("id")
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	scope := store.Scope{OrganizationID: objectID}
+	users, err := h.Stores.Users.List(context.Background(), scope)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(users) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Organization still has users"})
+		return
+	}
+
+	sensors, err := h.Stores.Sensors.List(context.Background(), scope)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(sensors) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Organization still has sensors"})
+		return
+	}
+
+	err = h.Stores.Organizations.Delete(context.Background(), objectID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Organization deleted successfully"})
+}
+
+// legacyOrganizationName names the organization that takes the users and sensors from
+// before organizations existed that name none themselves.
+const legacyOrganizationName = "Default"
+
+// AssignLegacyOrganizations moves the users and sensors stored before organizations
+// existed into one, so they keep their access. A user joins the organization named by
+// their old free-text organization, which is created if needed, or Default when it was
+// blank; a sensor joins its owner's organization, or Default. Super-admins and
+// pre-registered sensors waiting to be claimed are left without one.
+func (h *Handler) AssignLegacyOrganizations() error {
+	ctx := context.Background()
+	named := map[string]primitive.ObjectID{}
+	organizationNamed := func(name string) (primitive.ObjectID, error) {
+		if name == "" {
+			name = legacyOrganizationName
+		}
+		if id, ok := named[name]; ok {
+			return id, nil
+		}
+		organization, err := h.Stores.Organizations.GetByName(ctx, name)
+		if err == store.ErrNotFound {
+			organization = models.Organization{Name: name, CreatedAt: time.Now()}
+			if err = h.Stores.Organizations.Create(ctx, &organization); err == nil {
+				log.Println("Created organization", name, "for users and sensors from before organizations")
+			}
+		}
+		if err != nil {
+			return primitive.NilObjectID, err
+		}
+		named[name] = organization.ID
+		return organization.ID, nil
+	}
+
+	users, err := h.Stores.Users.List(ctx, store.Scope{Unrestricted: true})
+	if err != nil {
+		return err
+	}
+	owners := map[primitive.ObjectID]primitive.ObjectID{}
+	for _, user := range users {
+		if !user.OrganizationID.IsZero() {
+			owners[user.ID] = user.OrganizationID
+			continue
+		}
+		if user.LegacyOrganization == nil || user.Role == models.RoleSuperAdmin {
+			continue
+		}
+
+		organizationID, err := organizationNamed(strings.TrimSpace(*user.LegacyOrganization))
+		if err != nil {
+			return err
+		}
+		err = h.Stores.Users.AssignOrganization(ctx, user.ID, organizationID)
+		if err != nil && err != store.ErrNotFound {
+			return err
+		}
+		owners[user.ID] = organizationID
+	}
+
+	sensors, err := h.Stores.Sensors.List(ctx, store.Scope{Unrestricted: true})
+	if err != nil {
+		return err
+	}
+	for _, sensor := range sensors {
+		if !sensor.OrganizationID.IsZero() || sensor.ClaimCodeHash != "" {
+			continue
+		}
+
+		organizationID, ok := owners[sensor.UserID]
+		if !ok {
+			if organizationID, err = organizationNamed(""); err != nil {
+				return err
+			}
+		}
+		err = h.Stores.Sensors.AssignOrganization(ctx, sensor.ID, organizationID)
+		if err != nil && err != store.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/middleware"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Claim codes are printed on the device, so they are shorter than tokens, but not so
+// short that they can be guessed.
+const minClaimCodeLength = 8
+
+var (
+	errClaimCodeInvalid = errors.New("Unknown serial number or claim code")
+	errClaimCodeExpired = errors.New("The claim code has expired")
+	errSensorClaimed    = errors.New("Sensor has already been claimed")
+	errClaimCodeLocked  = errors.New("Too many wrong claim codes; try again later")
+)
+
+// hashClaimCode hashes a claim code the way sensor tokens are hashed; only the hash is stored.
+func hashClaimCode(code string) string {
+	return middleware.HashSensorToken(code)
+}
+
+// checkClaimCode verifies code against the claim code of sensor at at.
+func checkClaimCode(sensor models.Sensor, code string, at time.Time) error {
+	if sensor.ClaimCodeHash == "" || subtle.ConstantTimeCompare([]byte(sensor.ClaimCodeHash), []byte(hashClaimCode(code))) != 1 {
+		return errClaimCodeInvalid
+	}
+	if sensor.ClaimCodeExpiresAt == nil || !at.Before(*sensor.ClaimCodeExpiresAt) {
+		return errClaimCodeExpired
+	}
+	return nil
+}
+
+// verifyClaimCode checks code against the claim code of sensor at at, unless too many
+// wrong codes have locked it, in which case Retry-After tells when to try again. A wrong
+// code counts towards the lock, so the code cannot be guessed by trying them all.
+func (h *Handler) verifyClaimCode(c *gin.Context, sensor models.Sensor, code string, at time.Time) error {
+	if sensor.ClaimLockedUntil != nil && at.Before(*sensor.ClaimLockedUntil) {
+		retryAfter := int(math.Ceil(sensor.ClaimLockedUntil.Sub(at).Seconds()))
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		return errClaimCodeLocked
+	}
+
+	err := checkClaimCode(sensor, code, at)
+	if err == errClaimCodeInvalid && sensor.ClaimCodeHash != "" {
+		lockedUntil := at.Add(h.Config.SensorClaimLockout)
+		if err := h.Stores.Sensors.FailClaimAttempt(context.Background(), sensor.ID, h.Config.SensorClaimMaxFailures, lockedUntil); err != nil {
+			log.Println("Failed to count wrong claim code of sensor", sensor.ID.Hex()+":", err)
+		}
+	}
+	return err
+}
+
+// claimCodeStatus maps the errors of verifyClaimCode to response statuses.
+func claimCodeStatus(err error) int {
+	switch err {
+	case errClaimCodeExpired:
+		return http.StatusGone
+	case errClaimCodeLocked:
+		return http.StatusTooManyRequests
+	}
+	return http.StatusNotFound
+}
+
+// provisioningStatus tells how far a sensor has come from pre-registration to holding a token.
+func provisioningStatus(sensor models.Sensor) string {
+	switch {
+	case sensor.TokenHash != "" || sensor.TokenIssuedAt != nil || sensor.LegacyToken != "":
+		return models.SensorProvisioned
+	case sensor.OrganizationID.IsZero():
+		return models.SensorUnclaimed
+	}
+	return models.SensorClaimed
+}
+
+// preRegisterSensor creates a sensor without an organization, to be claimed with code
+// until its ClaimCodeExpiresAt, or for the configured time when it has none.
+func (h *Handler) preRegisterSensor(c *gin.Context, sensor models.Sensor, code string, createdBy primitive.ObjectID) (models.Sensor, error) {
+	if !isSuperAdmin(c) {
+		return models.Sensor{}, fmt.Errorf("Only super-admins can pre-register sensor: %s", sensor.SerialNumber)
+	}
+	if sensor.SerialNumber == "" {
+		return models.Sensor{}, errors.New("Serial number is required to pre-register a sensor")
+	}
+	if len(code) < minClaimCodeLength {
+		return models.Sensor{}, fmt.Errorf("Claim code must have at least %d characters for sensor: %s", minClaimCodeLength, sensor.SerialNumber)
+	}
+	now := time.Now()
+	expiresAt := now.Add(h.Config.SensorClaimCodeTTL)
+	if sensor.ClaimCodeExpiresAt != nil {
+		if !sensor.ClaimCodeExpiresAt.After(now) {
+			return models.Sensor{}, fmt.Errorf("Claim code has already expired for sensor: %s", sensor.SerialNumber)
+		}
+		expiresAt = *sensor.ClaimCodeExpiresAt
+	}
+
+	resetCredentials(&sensor)
+	sensor.ClaimCodeHash = hashClaimCode(code)
+	sensor.ClaimCodeExpiresAt = &expiresAt
+	sensor.OrganizationID = primitive.NilObjectID
+	sensor.UserID = primitive.NilObjectID
+	resetConnectivity(&sensor)
+	startConfigHistory(&sensor)
+
+	err := h.Stores.Sensors.Create(context.Background(), &sensor)
+	if err == store.ErrConflict {
+		return models.Sensor{}, errSerialRegistered(sensor.SerialNumber)
+	}
+	if err != nil {
+		return models.Sensor{}, fmt.Errorf("Error creating sensor: %s", sensor.SerialNumber)
+	}
+	if _, err := h.recordConfigVersion(context.Background(), sensor, createdBy, 0); err != nil {
+		log.Println("Failed to record config history of sensor", sensor.ID.Hex()+":", err)
+	}
+	return sensor, nil
+}
+
+// ClaimSensor lets an installer take a pre-registered sensor into their organization and
+// assign its location, proving possession of the device with its claim code. The code
+// stays valid for the device to redeem for its token.
+func (h *Handler) ClaimSensor(c *gin.Context) {
+	var request struct {
+		SerialNumber   string             `json:"serial_number" binding:"required"`
+		ClaimCode      string             `json:"claim_code" binding:"required"`
+		OrganizationID primitive.ObjectID `json:"organization_id"` // Required of super-admins
+		Location       string             `json:"location"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	organizationID, err := h.resolveOrganization(c, request.OrganizationID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sensor, err := h.Stores.Sensors.GetBySerial(context.Background(), request.SerialNumber)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": errClaimCodeInvalid.Error()})
+		return
+	}
+	if provisioningStatus(sensor) != models.SensorUnclaimed {
+		c.JSON(http.StatusConflict, gin.H{"error": errSensorClaimed.Error()})
+		return
+	}
+
+	now := time.Now()
+	if err := h.verifyClaimCode(c, sensor, request.ClaimCode, now); err != nil {
+		c.JSON(claimCodeStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := middleware.GetUserID(c)
+	err = h.Stores.Sensors.Claim(context.Background(), sensor.ID, sensor.ClaimCodeHash, organizationID, userID, request.Location, now)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusConflict, gin.H{"error": errSensorClaimed.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.recordTokenEvent(context.Background(), models.TokenAuditEvent{
+		SensorID:       sensor.ID,
+		OrganizationID: organizationID,
+		Action:         models.TokenActionClaimed,
+		UserID:         userID,
+		RemoteAddr:     c.ClientIP(),
+		At:             now,
+	})
+
+	sensor.OrganizationID = organizationID
+	sensor.UserID = userID
+	sensor.Location = request.Location
+	sensor.ClaimedAt = &now
+	sensor.ClaimedBy = userID
+	c.JSON(http.StatusOK, gin.H{
+		"sensor":              sensor,
+		"provisioning_status": provisioningStatus(sensor),
+	})
+}
@@ -0,0 +1,247 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/middleware"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var errConfigChanged = errors.New("The sensor config was changed concurrently; reload and retry")
+
+// configSyncStatus compares the desired config version of a sensor with the one its
+// device last acknowledged.
+func configSyncStatus(sensor models.Sensor) string {
+	reported := sensor.ReportedConfig
+	switch {
+	case reported == nil || reported.Version != sensor.ConfigVersion:
+		return models.ConfigSyncPending
+	case reported.Status == models.ConfigFailed:
+		return models.ConfigSyncFailed
+	}
+	return models.ConfigSyncInSync
+}
+
+// startConfigHistory sets a new sensor's config as version 1, not yet acknowledged.
+func startConfigHistory(sensor *models.Sensor) {
+	sensor.ConfigVersion = 1
+	sensor.ReportedConfig = nil
+}
+
+// recordConfigVersion adds the sensor's current config to its history.
+func (h *Handler) recordConfigVersion(ctx context.Context, sensor models.Sensor, createdBy primitive.ObjectID, rolledBackFrom int) (models.SensorConfigVersion, error) {
+	version := models.SensorConfigVersion{
+		SensorID:       sensor.ID,
+		Version:        sensor.ConfigVersion,
+		Config:         sensor.Config,
+		CreatedBy:      createdBy,
+		CreatedAt:      time.Now(),
+		RolledBackFrom: rolledBackFrom,
+	}
+	if err := h.Stores.SensorConfigs.Create(ctx, &version); err != nil {
+		return models.SensorConfigVersion{}, errInsertFailed
+	}
+	return version, nil
+}
+
+// pushSensorConfig makes cfg the next desired config version of sensor, for its device
+// to fetch, and records it in the history. It returns errConfigChanged when the version
+// moved on since sensor was read.
+func (h *Handler) pushSensorConfig(ctx context.Context, scope store.Scope, sensor models.Sensor, cfg models.SensorConfig, createdBy primitive.ObjectID, rolledBackFrom int) (models.SensorConfigVersion, error) {
+	err := h.Stores.Sensors.SetConfig(ctx, scope, sensor.ID, sensor.ConfigVersion, cfg)
+	if err == store.ErrNotFound {
+		return models.SensorConfigVersion{}, errConfigChanged
+	}
+	if err != nil {
+		return models.SensorConfigVersion{}, err
+	}
+
+	sensor.Config = cfg
+	sensor.ConfigVersion++
+	return h.recordConfigVersion(ctx, sensor, createdBy, rolledBackFrom)
+}
+
+// GetSensorConfig returns the desired config of a sensor, the version its device last
+// acknowledged and whether the two are in sync.
+func (h *Handler) GetSensorConfig(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	sensor, err := h.findScopedSensor(c, objectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sensor_id":   sensor.ID,
+		"version":     sensor.ConfigVersion,
+		"desired":     sensor.Config,
+		"reported":    sensor.ReportedConfig,
+		"sync_status": configSyncStatus(sensor),
+	})
+}
+
+// GetSensorConfigHistory lists the config versions of a sensor, newest first.
+func (h *Handler) GetSensorConfigHistory(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if _, err := h.findScopedSensor(c, objectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	versions, total, err := h.Stores.SensorConfigs.List(context.Background(), objectID, int64((page-1)*limit), int64(limit))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": versions,
+		"pagination": gin.H{
+			"total": total,
+			"page":  page,
+			"limit": limit,
+		},
+	})
+}
+
+// RollbackSensorConfig restores the config of an earlier version. The restored config
+// becomes a new version, so the device picks it up like any other change.
+func (h *Handler) RollbackSensorConfig(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var request struct {
+		Version int `json:"version" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sensor, err := h.findScopedSensor(c, objectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found"})
+		return
+	}
+
+	target, err := h.Stores.SensorConfigs.Get(context.Background(), sensor.ID, request.Version)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Config version not found"})
+		return
+	}
+
+	// The referenced machine class may have been removed since
+	if err := h.validateSensorConfig(target.Config); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := middleware.GetUserID(c)
+	version, err := h.pushSensorConfig(context.Background(), callerScope(c), sensor, target.Config, userID, target.Version)
+	if err == errConfigChanged {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, version)
+}
+
+// GetDeviceConfig returns the desired config to a device authenticated with its sensor
+// token. Pending is set until the device acknowledges this version.
+func (h *Handler) GetDeviceConfig(c *gin.Context) {
+	sensor, ok := middleware.GetSensor(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sensor not authenticated"})
+		return
+	}
+	h.markSeen(sensor, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"version": sensor.ConfigVersion,
+		"config":  sensor.Config,
+		"pending": configSyncStatus(sensor) != models.ConfigSyncInSync,
+	})
+}
+
+// AckDeviceConfig records that a device applied, or failed to apply, a config version.
+func (h *Handler) AckDeviceConfig(c *gin.Context) {
+	sensor, ok := middleware.GetSensor(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sensor not authenticated"})
+		return
+	}
+
+	var request struct {
+		Version *int   `json:"version" binding:"required"`
+		Status  string `json:"status"` // Defaults to applied
+		Error   string `json:"error"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch request.Status {
+	case "":
+		request.Status = models.ConfigApplied
+	case models.ConfigApplied, models.ConfigFailed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be applied or failed"})
+		return
+	}
+	if *request.Version < 0 || *request.Version > sensor.ConfigVersion {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown config version"})
+		return
+	}
+
+	reported := models.ReportedConfig{
+		Version:    *request.Version,
+		Status:     request.Status,
+		Error:      request.Error,
+		ReportedAt: time.Now(),
+	}
+	if err := h.Stores.Sensors.ReportConfig(context.Background(), sensor.ID, reported); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.markSeen(sensor, nil)
+
+	if reported.Status == models.ConfigFailed {
+		log.Printf("Sensor %s failed to apply config version %d: %s", sensor.ID.Hex(), reported.Version, reported.Error)
+	}
+
+	sensor.ReportedConfig = &reported
+	c.JSON(http.StatusOK, gin.H{
+		"version":     sensor.ConfigVersion,
+		"reported":    reported,
+		"sync_status": configSyncStatus(sensor),
+	})
+}
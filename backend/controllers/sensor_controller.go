@@ -4,55 +4,104 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"reflect"
+	"time"
 
-	"github.com/ThirawatEu/vibration-sensor-gas-pipe/config"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/analysis"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/middleware"
 	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func CreateSensor(c *gin.Context) {
+func (h *Handler) CreateSensor(c *gin.Context) {
 	var sensor models.Sensor
 	if err := c.ShouldBindJSON(&sensor); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	collection := config.GetCollection("sensors")
-	result, err := collection.InsertOne(context.Background(), sensor)
+	if err := h.validateSensorConfig(sensor.Config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	organizationID, err := h.resolveOrganization(c, sensor.OrganizationID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	sensor.OrganizationID = organizationID
+	resetCredentials(&sensor)
+	resetConnectivity(&sensor)
+	startConfigHistory(&sensor)
+
+	err = h.Stores.Sensors.Create(context.Background(), &sensor)
+	if err == store.ErrConflict {
+		c.JSON(http.StatusConflict, gin.H{"error": errSerialRegistered(sensor.SerialNumber).Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	sensor.ID = result.InsertedID.(primitive.ObjectID)
+	userID, _ := middleware.GetUserID(c)
+	if _, err := h.recordConfigVersion(context.Background(), sensor, userID, 0); err != nil {
+		log.Println("Failed to record config history of sensor", sensor.ID.Hex()+":", err)
+	}
+
 	c.JSON(http.StatusCreated, sensor)
 }
 
-func GetSensors(c *gin.Context) {
-	var sensors []models.Sensor
-	collection := config.GetCollection("sensors")
-
-	cursor, err := collection.Find(context.Background(), bson.M{})
+// GetSensors lists the caller's sensors, optionally only those of one connectivity
+// status (online, offline or unknown), config sync status (in_sync, pending or failed)
+// or provisioning status (unclaimed, claimed or provisioned).
+func (h *Handler) GetSensors(c *gin.Context) {
+	sensors, err := h.Stores.Sensors.List(context.Background(), callerScope(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer cursor.Close(context.Background())
 
-	for cursor.Next(context.Background()) {
-		var sensor models.Sensor
-		cursor.Decode(&sensor)
-		sensors = append(sensors, sensor)
+	if status := c.Query("status"); status != "" {
+		matching := []models.Sensor{}
+		for _, sensor := range sensors {
+			if sensorStatus(sensor) == status {
+				matching = append(matching, sensor)
+			}
+		}
+		sensors = matching
+	}
+	if syncStatus := c.Query("config_status"); syncStatus != "" {
+		matching := []models.Sensor{}
+		for _, sensor := range sensors {
+			if configSyncStatus(sensor) == syncStatus {
+				matching = append(matching, sensor)
+			}
+		}
+		sensors = matching
+	}
+	if provisioning := c.Query("provisioning"); provisioning != "" {
+		matching := []models.Sensor{}
+		for _, sensor := range sensors {
+			if provisioningStatus(sensor) == provisioning {
+				matching = append(matching, sensor)
+			}
+		}
+		sensors = matching
 	}
 
 	c.JSON(http.StatusOK, sensors)
 }
 
-func GetSensor(c *gin.Context) {
+func (h *Handler) GetSensor(c *gin.Context) {
 	id := c.Param("id")
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -60,9 +109,7 @@ func GetSensor(c *gin.Context) {
 		return
 	}
 
-	var sensor models.Sensor
-	collection := config.GetCollection("sensors")
-	err = collection.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&sensor)
+	sensor, err := h.findScopedSensor(c, objectID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found"})
 		return
@@ -71,7 +118,10 @@ func GetSensor(c *gin.Context) {
 	c.JSON(http.StatusOK, sensor)
 }
 
-func UpdateSensor(c *gin.Context) {
+// UpdateSensor replaces the details of a sensor. A changed config becomes a new config
+// version for the device to fetch. Details and config are written in one update, so a
+// concurrent config change rejects both.
+func (h *Handler) UpdateSensor(c *gin.Context) {
 	id := c.Param("id")
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -85,41 +135,54 @@ func UpdateSensor(c *gin.Context) {
 		return
 	}
 
-	collection := config.GetCollection("sensors")
-	update := bson.M{
-		"$set": bson.M{
-			"user_id":       sensor.UserID,
-			"serial_number": sensor.SerialNumber,
-			"location":      sensor.Location,
-			"picture":       sensor.Picture,
-			"config": bson.M{
-				"fmax":      sensor.Config.FMax,
-				"lor":       sensor.Config.LOR,
-				"g_max":     sensor.Config.GMax,
-				"alarm_ths": sensor.Config.AlarmThs,
-			},
-		},
-	}
-
-	result, err := collection.UpdateOne(
-		context.Background(),
-		bson.M{"_id": objectID},
-		update,
-	)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.validateSensorConfig(sensor.Config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if result.MatchedCount == 0 {
+	existing, err := h.findScopedSensor(c, objectID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Sensor updated successfully"})
+	sensor.ID = objectID
+	sensor.ConfigVersion = existing.ConfigVersion
+	configChanged := !reflect.DeepEqual(sensor.Config, existing.Config)
+	if configChanged {
+		sensor.ConfigVersion++
+	}
+
+	err = h.Stores.Sensors.Update(context.Background(), callerScope(c), sensor, existing.ConfigVersion)
+	if err == store.ErrNotFound {
+		// Either the sensor is gone or its config version moved on since it was read
+		if _, err := h.findScopedSensor(c, objectID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found"})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": errConfigChanged.Error()})
+		return
+	}
+	if err == store.ErrConflict {
+		c.JSON(http.StatusConflict, gin.H{"error": errSerialRegistered(sensor.SerialNumber).Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if configChanged {
+		userID, _ := middleware.GetUserID(c)
+		if _, err := h.recordConfigVersion(context.Background(), sensor, userID, 0); err != nil {
+			log.Println("Failed to record config history of sensor", sensor.ID.Hex()+":", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sensor updated successfully", "config_version": sensor.ConfigVersion})
 }
 
-func DeleteSensor(c *gin.Context) {
+func (h *Handler) DeleteSensor(c *gin.Context) {
 	id := c.Param("id")
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -127,19 +190,55 @@ func DeleteSensor(c *gin.Context) {
 		return
 	}
 
-	collection := config.GetCollection("sensors")
-	result, err := collection.DeleteOne(context.Background(), bson.M{"_id": objectID})
+	err = h.Stores.Sensors.Delete(context.Background(), callerScope(c), objectID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	if result.DeletedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found"})
-		return
+	c.JSON(http.StatusOK, gin.H{"message": "Sensor deleted successfully"})
+}
+
+// errSerialRegistered reports a serial number that another sensor already has.
+func errSerialRegistered(serialNumber string) error {
+	return fmt.Errorf("Serial number is already registered: %s", serialNumber)
+}
+
+// validateSensorConfig checks the references and enumerated settings of a sensor configuration.
+func (h *Handler) validateSensorConfig(cfg models.SensorConfig) error {
+	if cfg.MachineClass != "" {
+		if _, err := h.Stores.ISOZones.GetByClass(context.Background(), cfg.MachineClass); err != nil {
+			return fmt.Errorf("Unknown machine class: %s", cfg.MachineClass)
+		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Sensor deleted successfully"})
+	if err := analysis.ValidateUnitConfig(cfg); err != nil {
+		return err
+	}
+
+	if cfg.ReportInterval < 0 {
+		return errors.New("report_interval must not be negative")
+	}
+
+	switch cfg.ClassifyBy {
+	case "", models.ClassifyByReading, models.ClassifyByFeatures:
+	default:
+		return fmt.Errorf("Unknown classify_by: %s", cfg.ClassifyBy)
+	}
+	return analysis.ValidateBands(cfg.Bands)
+}
+
+// resetConnectivity clears the connectivity fields of a new sensor, which only
+// ingestion and the offline watchdog may set.
+func resetConnectivity(sensor *models.Sensor) {
+	sensor.Status = ""
+	sensor.LastSeenAt = nil
+	sensor.OfflineSince = nil
+	sensor.Telemetry = nil
 }
 
 func generateTokenHex(length int) (string, error) {
@@ -150,9 +249,14 @@ func generateTokenHex(length int) (string, error) {
 	return hex.EncodeToString(tokenBytes), nil
 }
 
-func RegisterSensor(c *gin.Context) {
+// RegisterSensor issues the first token of a claimed sensor to the device presenting its
+// serial number and claim code, consuming the code. Sensors created without a claim code
+// get their first token from an authenticated rotation, so knowing a serial number is not
+// enough to take over a sensor.
+func (h *Handler) RegisterSensor(c *gin.Context) {
 	var request struct {
 		SerialNumber string `json:"serial_number" binding:"required"`
+		ClaimCode    string `json:"claim_code" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -160,80 +264,145 @@ func RegisterSensor(c *gin.Context) {
 		return
 	}
 
-	collection := config.GetCollection("sensors")
-	var sensor models.Sensor
-
 	// Find sensor by serial number
-	err := collection.FindOne(context.Background(), bson.M{"serial_number": request.SerialNumber}).Decode(&sensor)
+	sensor, err := h.Stores.Sensors.GetBySerial(context.Background(), request.SerialNumber)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found"})
+		c.JSON(http.StatusNotFound, gin.H{"error": errClaimCodeInvalid.Error()})
 		return
 	}
 
-	// Generate 32 bytes token (will become 64 hex characters)
-	tokenString, err := generateTokenHex(32)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating token"})
+	if provisioningStatus(sensor) == models.SensorProvisioned {
+		c.JSON(http.StatusConflict, gin.H{"error": "Sensor is already registered; its token can only be rotated by an authorized user"})
 		return
 	}
 
-	// Update sensor document with the generated token
-	update := bson.M{
-		"$set": bson.M{
-			"token": tokenString,
-		},
+	now := time.Now()
+	if err := h.verifyClaimCode(c, sensor, request.ClaimCode, now); err != nil {
+		c.JSON(claimCodeStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if sensor.ClaimedAt == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Sensor has not been claimed by an installer yet"})
+		return
 	}
 
-	result, err := collection.UpdateOne(
-		context.Background(),
-		bson.M{"_id": sensor.ID},
-		update,
-	)
+	token, err := generateTokenHex(32)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating sensor token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating token"})
 		return
 	}
 
-	// Extra safety: check if the sensor matched
-	if result.MatchedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found during update"})
+	err = h.Stores.Sensors.RedeemClaimCode(context.Background(), sensor.ID, sensor.ClaimCodeHash, middleware.HashSensorToken(token), now)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusConflict, gin.H{"error": "Sensor is already registered; its token can only be rotated by an authorized user"})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating token"})
+		return
+	}
+
+	h.recordTokenEvent(context.Background(), models.TokenAuditEvent{
+		SensorID:       sensor.ID,
+		OrganizationID: sensor.OrganizationID,
+		Action:         models.TokenActionIssued,
+		RemoteAddr:     c.ClientIP(),
+		At:             now,
+	})
 
 	c.JSON(http.StatusOK, gin.H{
-		"token":     tokenString,
+		"token":     token,
 		"sensor_id": sensor.ID.Hex(),
 	})
 }
 
-func BatchRegisterSensors(c *gin.Context) {
-	var sensors []models.Sensor
-	if err := c.ShouldBindJSON(&sensors); err != nil {
+// sensorRegistration is one sensor given to BatchRegisterSensors. With a claim code the
+// sensor is pre-registered for an installer to claim; without one it is registered into
+// an organization straight away and gets its token.
+type sensorRegistration struct {
+	models.Sensor
+	ClaimCode string `json:"claim_code"`
+}
+
+// registeredSensor is a sensor created by BatchRegisterSensors with its token, which is
+// shown only this once. Pre-registered sensors have none yet.
+type registeredSensor struct {
+	models.Sensor
+	Token string `json:"token,omitempty"`
+}
+
+// BatchRegisterSensors creates sensors in bulk: pre-registered ones, as imported from
+// manufacturing with their claim codes by a super-admin, and ready-to-use ones.
+func (h *Handler) BatchRegisterSensors(c *gin.Context) {
+	var registrations []sensorRegistration
+	if err := c.ShouldBindJSON(&registrations); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	collection := config.GetCollection("sensors")
-	var results []models.Sensor
+	var results []registeredSensor
 	var errors []string
+	userID, _ := middleware.GetUserID(c)
+
+	for _, registration := range registrations {
+		sensor := registration.Sensor
+		if err := h.validateSensorConfig(sensor.Config); err != nil {
+			errors = append(errors, "Invalid config for sensor: "+sensor.SerialNumber)
+			continue
+		}
+
+		if registration.ClaimCode != "" {
+			sensor, err := h.preRegisterSensor(c, sensor, registration.ClaimCode, userID)
+			if err != nil {
+				errors = append(errors, err.Error())
+				continue
+			}
+			results = append(results, registeredSensor{Sensor: sensor})
+			continue
+		}
+
+		organizationID, err := h.resolveOrganization(c, sensor.OrganizationID)
+		if err != nil {
+			errors = append(errors, "Invalid organization for sensor: "+sensor.SerialNumber)
+			continue
+		}
+		sensor.OrganizationID = organizationID
 
-	for _, sensor := range sensors {
 		// Generate token for each sensor
 		tokenString, err := generateTokenHex(32)
 		if err != nil {
 			errors = append(errors, "Error generating token for sensor: "+sensor.SerialNumber)
 			continue
 		}
-		sensor.Token = tokenString
-
-		result, err := collection.InsertOne(context.Background(), sensor)
+		now := time.Now()
+		resetCredentials(&sensor)
+		sensor.TokenHash = middleware.HashSensorToken(tokenString)
+		sensor.TokenIssuedAt = &now
+		resetConnectivity(&sensor)
+		startConfigHistory(&sensor)
+
+		err = h.Stores.Sensors.Create(context.Background(), &sensor)
+		if err == store.ErrConflict {
+			errors = append(errors, errSerialRegistered(sensor.SerialNumber).Error())
+			continue
+		}
 		if err != nil {
 			errors = append(errors, "Error creating sensor: "+sensor.SerialNumber)
 			continue
 		}
-
-		sensor.ID = result.InsertedID.(primitive.ObjectID)
-		results = append(results, sensor)
+		if _, err := h.recordConfigVersion(context.Background(), sensor, userID, 0); err != nil {
+			log.Println("Failed to record config history of sensor", sensor.ID.Hex()+":", err)
+		}
+		h.recordTokenEvent(context.Background(), models.TokenAuditEvent{
+			SensorID:       sensor.ID,
+			OrganizationID: sensor.OrganizationID,
+			Action:         models.TokenActionIssued,
+			UserID:         userID,
+			RemoteAddr:     c.ClientIP(),
+			At:             now,
+		})
+
+		results = append(results, registeredSensor{Sensor: sensor, Token: tokenString})
 	}
 
 	response := gin.H{
@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/middleware"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var errTokenChanged = errors.New("The sensor token was changed concurrently; retry")
+
+// resetCredentials clears the credential and claim fields of a new sensor, which only
+// pre-registration, claiming, token issuance, rotation and revocation may set.
+func resetCredentials(sensor *models.Sensor) {
+	sensor.TokenHash = ""
+	sensor.PreviousTokenHash = ""
+	sensor.PreviousTokenExpiresAt = nil
+	sensor.TokenIssuedAt = nil
+	sensor.TokenRevokedAt = nil
+	sensor.LegacyToken = ""
+	sensor.ClaimCodeHash = ""
+	sensor.ClaimCodeExpiresAt = nil
+	sensor.ClaimedAt = nil
+	sensor.ClaimedBy = primitive.NilObjectID
+	sensor.ClaimFailures = 0
+	sensor.ClaimLockedUntil = nil
+}
+
+// recordTokenEvent adds an event to the token audit trail. Failures are logged rather
+// than returned, since the credential change they describe has already been made.
+func (h *Handler) recordTokenEvent(ctx context.Context, event models.TokenAuditEvent) {
+	if err := h.Stores.TokenAudit.Create(ctx, &event); err != nil {
+		log.Println("Failed to audit", event.Action, "token of sensor", event.SensorID.Hex()+":", err)
+	}
+}
+
+// issueSensorToken gives sensor a new token and audits it as action by userID from
+// remoteAddr. A current token stays valid for grace. It returns the plaintext token,
+// which is not stored, and errTokenChanged when the token moved on since sensor was read.
+func (h *Handler) issueSensorToken(ctx context.Context, sensor models.Sensor, action string, userID primitive.ObjectID, remoteAddr string, grace time.Duration) (string, models.TokenAuditEvent, error) {
+	// Generate 32 bytes token (will become 64 hex characters)
+	token, err := generateTokenHex(32)
+	if err != nil {
+		return "", models.TokenAuditEvent{}, err
+	}
+
+	now := time.Now()
+	var graceUntil time.Time
+	if sensor.TokenHash != "" && grace > 0 {
+		graceUntil = now.Add(grace)
+	}
+
+	err = h.Stores.Sensors.RotateToken(ctx, sensor.ID, sensor.TokenHash, middleware.HashSensorToken(token), graceUntil, now)
+	if err == store.ErrNotFound {
+		return "", models.TokenAuditEvent{}, errTokenChanged
+	}
+	if err != nil {
+		return "", models.TokenAuditEvent{}, err
+	}
+
+	event := models.TokenAuditEvent{
+		SensorID:       sensor.ID,
+		OrganizationID: sensor.OrganizationID,
+		Action:         action,
+		UserID:         userID,
+		RemoteAddr:     remoteAddr,
+		At:             now,
+	}
+	if !graceUntil.IsZero() {
+		event.GraceUntil = &graceUntil
+	}
+	h.recordTokenEvent(ctx, event)
+	return token, event, nil
+}
+
+// RotateSensorToken issues a new token for a sensor. Its current token keeps working for
+// the configured grace period, or for the request's grace_period ("0" ends it at once),
+// so the device can switch over without losing data.
+func (h *Handler) RotateSensorToken(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var request struct {
+		GracePeriod string `json:"grace_period"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	grace := h.Config.SensorTokenGrace
+	switch request.GracePeriod {
+	case "":
+	case "0":
+		grace = 0
+	default:
+		grace, err = parseInterval(request.GracePeriod)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid grace_period: " + request.GracePeriod})
+			return
+		}
+	}
+
+	sensor, err := h.findScopedSensor(c, objectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found"})
+		return
+	}
+
+	userID, _ := middleware.GetUserID(c)
+	token, event, err := h.issueSensorToken(context.Background(), sensor, models.TokenActionRotated, userID, c.ClientIP(), grace)
+	if err == errTokenChanged {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error rotating sensor token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":                     token,
+		"sensor_id":                 sensor.ID.Hex(),
+		"previous_token_expires_at": event.GraceUntil,
+	})
+}
+
+// RevokeSensorToken invalidates every token of a sensor at once. The sensor cannot
+// register itself again; it needs a token issued by rotation.
+func (h *Handler) RevokeSensorToken(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	sensor, err := h.findScopedSensor(c, objectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found"})
+		return
+	}
+
+	now := time.Now()
+	if err := h.Stores.Sensors.RevokeToken(context.Background(), sensor.ID, now); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := middleware.GetUserID(c)
+	h.recordTokenEvent(context.Background(), models.TokenAuditEvent{
+		SensorID:       sensor.ID,
+		OrganizationID: sensor.OrganizationID,
+		Action:         models.TokenActionRevoked,
+		UserID:         userID,
+		RemoteAddr:     c.ClientIP(),
+		At:             now,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sensor token revoked successfully"})
+}
+
+// GetSensorTokenAudit lists who issued, rotated and revoked the tokens of a sensor,
+// newest first.
+func (h *Handler) GetSensorTokenAudit(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if _, err := h.findScopedSensor(c, objectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	events, total, err := h.Stores.TokenAudit.List(context.Background(), objectID, int64((page-1)*limit), int64(limit))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": events,
+		"pagination": gin.H{
+			"total": total,
+			"page":  page,
+			"limit": limit,
+		},
+	})
+}
+
+// HashLegacySensorTokens replaces the plaintext tokens stored before tokens were hashed
+// with their hashes. Devices keep using the same tokens.
+func (h *Handler) HashLegacySensorTokens() error {
+	sensors, err := h.Stores.Sensors.List(context.Background(), store.Scope{Unrestricted: true})
+	if err != nil {
+		return err
+	}
+
+	for _, sensor := range sensors {
+		if sensor.LegacyToken == "" {
+			continue
+		}
+		hash := middleware.HashSensorToken(sensor.LegacyToken)
+		err := h.Stores.Sensors.RotateToken(context.Background(), sensor.ID, sensor.TokenHash, hash, time.Time{}, time.Now())
+		if err != nil && err != store.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
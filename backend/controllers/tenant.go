@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/middleware"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// isSuperAdmin reports whether the caller may work across all organizations.
+func isSuperAdmin(c *gin.Context) bool {
+	user, ok := middleware.GetUser(c)
+	return ok && user.Role == models.RoleSuperAdmin
+}
+
+// callerOrganizationID returns the organization of the authenticated user.
+func callerOrganizationID(c *gin.Context) primitive.ObjectID {
+	user, _ := middleware.GetUser(c)
+	return user.OrganizationID
+}
+
+// callerScope restricts store queries to the caller's organization.
+// Super-admins see every organization, so their scope is unrestricted.
+func callerScope(c *gin.Context) store.Scope {
+	if isSuperAdmin(c) {
+		return store.Scope{Unrestricted: true}
+	}
+	return store.Scope{OrganizationID: callerOrganizationID(c)}
+}
+
+// findScopedSensor loads a sensor only if it belongs to the caller's organization.
+func (h *Handler) findScopedSensor(c *gin.Context, sensorID primitive.ObjectID) (models.Sensor, error) {
+	return h.Stores.Sensors.Get(context.Background(), callerScope(c), sensorID)
+}
+
+// scopedSensorIDs returns the IDs of every sensor visible to the caller.
+// The second result is false for super-admins, who are not restricted to a sensor set.
+func (h *Handler) scopedSensorIDs(c *gin.Context) ([]primitive.ObjectID, bool, error) {
+	if isSuperAdmin(c) {
+		return nil, false, nil
+	}
+
+	sensors, err := h.Stores.Sensors.List(context.Background(), callerScope(c))
+	if err != nil {
+		return nil, true, err
+	}
+
+	ids := []primitive.ObjectID{}
+	for _, sensor := range sensors {
+		ids = append(ids, sensor.ID)
+	}
+	return ids, true, nil
+}
+
+// resolveOrganization decides which organization a new record belongs to.
+// Regular users always create records in their own organization; super-admins
+// must name an existing organization explicitly.
+func (h *Handler) resolveOrganization(c *gin.Context, requested primitive.ObjectID) (primitive.ObjectID, error) {
+	if !isSuperAdmin(c) {
+		return callerOrganizationID(c), nil
+	}
+
+	if requested.IsZero() {
+		return primitive.NilObjectID, errOrganizationRequired
+	}
+
+	organization, err := h.Stores.Organizations.Get(context.Background(), requested)
+	if err != nil {
+		return primitive.NilObjectID, errOrganizationNotFound
+	}
+	return organization.ID, nil
+}
@@ -0,0 +1,326 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	errSegmentSensors   = errors.New("sensor_a_id and sensor_b_id must be two different sensors of the organization")
+	errSegmentDistance  = errors.New("distance must be positive")
+	errSegmentWaveSpeed = errors.New("wave_speed must be positive")
+	errDuplicateSegment = errors.New("A segment between these sensors already exists")
+)
+
+// validatePipeSegment checks that a segment joins two different sensors of its
+// organization that no other segment joins, over a positive distance and wave speed.
+func (h *Handler) validatePipeSegment(segment models.PipeSegment) error {
+	switch {
+	case segment.SensorA == segment.SensorB:
+		return errSegmentSensors
+	case segment.Distance <= 0:
+		return errSegmentDistance
+	case segment.WaveSpeed <= 0:
+		return errSegmentWaveSpeed
+	}
+
+	scope := store.Scope{OrganizationID: segment.OrganizationID}
+	for _, sensorID := range []primitive.ObjectID{segment.SensorA, segment.SensorB} {
+		_, err := h.Stores.Sensors.Get(context.Background(), scope, sensorID)
+		if err == store.ErrNotFound {
+			return errSegmentSensors
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	existing, err := h.Stores.PipeSegments.ListBySensor(context.Background(), scope, segment.SensorA)
+	if err != nil {
+		return err
+	}
+	for _, other := range existing {
+		if other.ID != segment.ID && (other.SensorA == segment.SensorB || other.SensorB == segment.SensorB) {
+			return errDuplicateSegment
+		}
+	}
+	return nil
+}
+
+// segmentErrorStatus maps a validatePipeSegment error to its HTTP status.
+func segmentErrorStatus(err error) int {
+	switch err {
+	case errSegmentSensors, errSegmentDistance, errSegmentWaveSpeed:
+		return http.StatusBadRequest
+	case errDuplicateSegment:
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// CreatePipeSegment records that two sensors are neighbours along a stretch of pipe.
+func (h *Handler) CreatePipeSegment(c *gin.Context) {
+	var segment models.PipeSegment
+	if err := c.ShouldBindJSON(&segment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	organizationID, err := h.resolveOrganization(c, segment.OrganizationID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	segment.OrganizationID = organizationID
+	segment.ID = primitive.NilObjectID
+
+	if err := h.validatePipeSegment(segment); err != nil {
+		c.JSON(segmentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	segment.LastAnalyzedAt = time.Time{}
+	segment.CreatedAt = time.Now()
+	segment.UpdatedAt = segment.CreatedAt
+
+	if err := h.Stores.PipeSegments.Create(context.Background(), &segment); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, segment)
+}
+
+func (h *Handler) GetPipeSegments(c *gin.Context) {
+	segments, err := h.Stores.PipeSegments.List(context.Background(), callerScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, segments)
+}
+
+func (h *Handler) GetPipeSegment(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	segment, err := h.Stores.PipeSegments.Get(context.Background(), callerScope(c), objectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pipe segment not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, segment)
+}
+
+// UpdatePipeSegment replaces the sensors, distance, wave speed and name of a segment.
+// Waveforms already analyzed are not analyzed again with the new values.
+func (h *Handler) UpdatePipeSegment(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var segment models.PipeSegment
+	if err := c.ShouldBindJSON(&segment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	existing, err := h.Stores.PipeSegments.Get(context.Background(), callerScope(c), objectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pipe segment not found"})
+		return
+	}
+
+	segment.ID = existing.ID
+	segment.OrganizationID = existing.OrganizationID
+	if err := h.validatePipeSegment(segment); err != nil {
+		c.JSON(segmentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	segment.UpdatedAt = time.Now()
+	if err := h.Stores.PipeSegments.Update(context.Background(), callerScope(c), segment); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Pipe segment updated successfully"})
+}
+
+// DeletePipeSegment removes a segment. Its leak candidates are kept.
+func (h *Handler) DeletePipeSegment(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	err = h.Stores.PipeSegments.Delete(context.Background(), callerScope(c), objectID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pipe segment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Pipe segment deleted successfully"})
+}
+
+// neighbour is a sensor adjacent to another along a pipe segment.
+type neighbour struct {
+	SensorID  primitive.ObjectID `json:"sensor_id"`
+	SegmentID primitive.ObjectID `json:"segment_id"`
+	Name      string             `json:"name"`
+	Distance  float64            `json:"distance"`
+	WaveSpeed float64            `json:"wave_speed"`
+}
+
+// GetSensorNeighbours lists the sensors sharing a pipe segment with a sensor.
+func (h *Handler) GetSensorNeighbours(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if _, err := h.findScopedSensor(c, objectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found"})
+		return
+	}
+
+	segments, err := h.Stores.PipeSegments.ListBySensor(context.Background(), callerScope(c), objectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	neighbours := []neighbour{}
+	for _, segment := range segments {
+		other := segment.SensorB
+		if other == objectID {
+			other = segment.SensorA
+		}
+		neighbours = append(neighbours, neighbour{
+			SensorID:  other,
+			SegmentID: segment.ID,
+			Name:      segment.Name,
+			Distance:  segment.Distance,
+			WaveSpeed: segment.WaveSpeed,
+		})
+	}
+
+	c.JSON(http.StatusOK, neighbours)
+}
+
+// AnalyzePipeSegment runs the leak analysis of a segment now instead of waiting for the
+// next scheduled run, and returns the candidates it stored.
+func (h *Handler) AnalyzePipeSegment(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	segment, err := h.Stores.PipeSegments.Get(context.Background(), callerScope(c), objectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pipe segment not found"})
+		return
+	}
+
+	if h.LeakJob == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Leak analysis is not running"})
+		return
+	}
+
+	candidates, err := h.LeakJob.Analyze(context.Background(), segment, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": candidates})
+}
+
+// GetLeakCandidates lists leak candidates, newest first, optionally of one segment and
+// above a minimum confidence.
+func (h *Handler) GetLeakCandidates(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	query := store.LeakQuery{
+		Scope: callerScope(c),
+		Skip:  int64((page - 1) * limit),
+		Limit: int64(limit),
+	}
+
+	if segmentID := c.Query("segment_id"); segmentID != "" {
+		if id, err := primitive.ObjectIDFromHex(segmentID); err == nil {
+			query.SegmentID = id
+		}
+	}
+
+	if minConfidence := c.Query("min_confidence"); minConfidence != "" {
+		if confidence, err := strconv.ParseFloat(minConfidence, 64); err == nil {
+			query.MinConfidence = confidence
+		}
+	}
+
+	if from := c.Query("from"); from != "" {
+		if t, err := time.Parse(time.RFC3339, from); err == nil {
+			query.From = t
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		if t, err := time.Parse(time.RFC3339, to); err == nil {
+			query.To = t
+		}
+	}
+
+	candidates, total, err := h.Stores.Leaks.List(context.Background(), query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": candidates,
+		"pagination": gin.H{
+			"total": total,
+			"page":  page,
+			"limit": limit,
+		},
+	})
+}
+
+func (h *Handler) GetLeakCandidate(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	candidate, err := h.Stores.Leaks.Get(context.Background(), callerScope(c), objectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Leak candidate not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, candidate)
+}
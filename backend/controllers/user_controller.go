@@ -2,52 +2,106 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"time"
 
-	"github.com/ThirawatEu/vibration-sensor-gas-pipe/config"
 	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func generateTokens(userID primitive.ObjectID) (string, string, time.Time, error) {
+// generateTokens signs a new access and refresh token pair for userID.
+func (h *Handler) generateTokens(userID primitive.ObjectID) (string, string, time.Time, error) {
+	cfg := h.Config
+
 	// Generate access token
 	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"user_id": userID.Hex(),
-		"exp":     time.Now().Add(time.Hour * 24).Unix(), // 24 hours expiration
+		"type":    "access",
+		"exp":     time.Now().Add(cfg.AccessTokenTTL).Unix(),
 	})
 
 	// Generate refresh token
 	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"user_id": userID.Hex(),
-		"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(), // 7 days expiration
+		"type":    "refresh",
+		"exp":     time.Now().Add(cfg.RefreshTokenTTL).Unix(),
 	})
 
 	// Sign the tokens
-	accessTokenString, err := accessToken.SignedString([]byte(config.GetConfig().JWTSecret))
+	accessTokenString, err := accessToken.SignedString([]byte(cfg.JWTSecret))
 	if err != nil {
 		return "", "", time.Time{}, err
 	}
 
-	refreshTokenString, err := refreshToken.SignedString([]byte(config.GetConfig().JWTSecret))
+	refreshTokenString, err := refreshToken.SignedString([]byte(cfg.JWTSecret))
 	if err != nil {
 		return "", "", time.Time{}, err
 	}
 
-	return accessTokenString, refreshTokenString, time.Now().Add(time.Hour * 24), nil
+	return accessTokenString, refreshTokenString, time.Now().Add(cfg.AccessTokenTTL), nil
+}
+
+var errSuperAdminOnly = errors.New("Only super-admins can manage super-admin accounts")
+
+// normalizeRole defaults an empty role to viewer and rejects unknown roles.
+func normalizeRole(user *models.User) error {
+	if user.Role == "" {
+		user.Role = models.RoleViewer
+		return nil
+	}
+	if !models.IsValidRole(user.Role) {
+		return fmt.Errorf("Invalid role: %s", user.Role)
+	}
+	return nil
+}
+
+// assignUserOrganization places a new user in an organization. Super-admin
+// accounts belong to no organization and may only be created by super-admins.
+func (h *Handler) assignUserOrganization(c *gin.Context, user *models.User) error {
+	if user.Role == models.RoleSuperAdmin {
+		if !isSuperAdmin(c) {
+			return errSuperAdminOnly
+		}
+		user.OrganizationID = primitive.NilObjectID
+		return nil
+	}
+
+	organizationID, err := h.resolveOrganization(c, user.OrganizationID)
+	if err != nil {
+		return err
+	}
+	user.OrganizationID = organizationID
+	return nil
 }
 
-func CreateUser(c *gin.Context) {
+func (h *Handler) CreateUser(c *gin.Context) {
 	var user models.User
 	if err := c.ShouldBindJSON(&user); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if err := normalizeRole(&user); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.assignUserOrganization(c, &user); err != nil {
+		status := http.StatusBadRequest
+		if err == errSuperAdminOnly {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Hash the password before storing
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -57,7 +111,7 @@ func CreateUser(c *gin.Context) {
 	user.Password = string(hashedPassword)
 
 	// Generate tokens
-	accessToken, refreshToken, tokenExpiry, err := generateTokens(user.ID)
+	accessToken, refreshToken, tokenExpiry, err := h.generateTokens(user.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating tokens"})
 		return
@@ -67,42 +121,32 @@ func CreateUser(c *gin.Context) {
 	user.RefreshToken = refreshToken
 	user.TokenExpiry = tokenExpiry
 
-	collection := config.GetCollection("users")
-	result, err := collection.InsertOne(context.Background(), user)
-	if err != nil {
+	if err := h.Stores.Users.Create(context.Background(), &user); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	user.ID = result.InsertedID.(primitive.ObjectID)
 	// Don't send password back
 	user.Password = ""
 	c.JSON(http.StatusCreated, user)
 }
 
-func GetUsers(c *gin.Context) {
-	var users []models.User
-	collection := config.GetCollection("users")
-
-	cursor, err := collection.Find(context.Background(), bson.M{})
+func (h *Handler) GetUsers(c *gin.Context) {
+	users, err := h.Stores.Users.List(context.Background(), callerScope(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer cursor.Close(context.Background())
 
-	for cursor.Next(context.Background()) {
-		var user models.User
-		cursor.Decode(&user)
-		// Don't send passwords back
-		user.Password = ""
-		users = append(users, user)
+	// Don't send passwords back
+	for i := range users {
+		users[i].Password = ""
 	}
 
 	c.JSON(http.StatusOK, users)
 }
 
-func GetUser(c *gin.Context) {
+func (h *Handler) GetUser(c *gin.Context) {
 	id := c.Param("id")
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -110,9 +154,7 @@ func GetUser(c *gin.Context) {
 		return
 	}
 
-	var user models.User
-	collection := config.GetCollection("users")
-	err = collection.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&user)
+	user, err := h.Stores.Users.Get(context.Background(), callerScope(c), objectID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
@@ -123,7 +165,7 @@ func GetUser(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
-func UpdateUser(c *gin.Context) {
+func (h *Handler) UpdateUser(c *gin.Context) {
 	id := c.Param("id")
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -147,33 +189,21 @@ func UpdateUser(c *gin.Context) {
 		user.Password = string(hashedPassword)
 	}
 
-	collection := config.GetCollection("users")
-	update := bson.M{
-		"$set": bson.M{
-			"username": user.Username,
-			"password": user.Password,
-		},
+	user.ID = objectID
+	err = h.Stores.Users.Update(context.Background(), callerScope(c), user)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
 	}
-
-	result, err := collection.UpdateOne(
-		context.Background(),
-		bson.M{"_id": objectID},
-		update,
-	)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	if result.MatchedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
-	}
-
 	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully"})
 }
 
-func DeleteUser(c *gin.Context) {
+func (h *Handler) DeleteUser(c *gin.Context) {
 	id := c.Param("id")
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -181,22 +211,20 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
-	collection := config.GetCollection("users")
-	result, err := collection.DeleteOne(context.Background(), bson.M{"_id": objectID})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	err = h.Stores.Users.Delete(context.Background(), callerScope(c), objectID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
-
-	if result.DeletedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
 
-func Login(c *gin.Context) {
+func (h *Handler) Login(c *gin.Context) {
 	var loginData struct {
 		Username string `json:"username"`
 		Password string `json:"password"`
@@ -207,9 +235,7 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	var user models.User
-	collection := config.GetCollection("users")
-	err := collection.FindOne(context.Background(), bson.M{"username": loginData.Username}).Decode(&user)
+	user, err := h.Stores.Users.GetByUsername(context.Background(), loginData.Username)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password", "message": "Login failed"})
 		return
@@ -222,26 +248,14 @@ func Login(c *gin.Context) {
 	}
 
 	// Generate new tokens
-	accessToken, refreshToken, tokenExpiry, err := generateTokens(user.ID)
+	accessToken, refreshToken, tokenExpiry, err := h.generateTokens(user.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating tokens", "message": "Login failed"})
 		return
 	}
 
 	// Update user with new tokens
-	update := bson.M{
-		"$set": bson.M{
-			"token":         accessToken,
-			"refresh_token": refreshToken,
-			"token_expiry":  tokenExpiry,
-		},
-	}
-
-	_, err = collection.UpdateOne(
-		context.Background(),
-		bson.M{"_id": user.ID},
-		update,
-	)
+	err = h.Stores.Users.SetTokens(context.Background(), user.ID, accessToken, refreshToken, tokenExpiry)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating user tokens", "message": "Login failed"})
 		return
@@ -259,7 +273,7 @@ func Login(c *gin.Context) {
 	})
 }
 
-func RefreshToken(c *gin.Context) {
+func (h *Handler) RefreshToken(c *gin.Context) {
 	var refreshData struct {
 		RefreshToken string `json:"refresh_token" binding:"required"`
 	}
@@ -271,47 +285,35 @@ func RefreshToken(c *gin.Context) {
 
 	// Verify refresh token
 	token, err := jwt.Parse(refreshData.RefreshToken, func(token *jwt.Token) (interface{}, error) {
-		return []byte(config.GetConfig().JWTSecret), nil
-	})
+		return []byte(h.Config.JWTSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
 		return
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || !token.Valid {
+	if !ok || !token.Valid || claims["type"] != "refresh" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
 		return
 	}
 
-	userID, err := primitive.ObjectIDFromHex(claims["user_id"].(string))
+	rawUserID, _ := claims["user_id"].(string)
+	userID, err := primitive.ObjectIDFromHex(rawUserID)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID in token"})
 		return
 	}
 
 	// Generate new tokens
-	accessToken, refreshToken, tokenExpiry, err := generateTokens(userID)
+	accessToken, refreshToken, tokenExpiry, err := h.generateTokens(userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating tokens"})
 		return
 	}
 
 	// Update user with new tokens
-	collection := config.GetCollection("users")
-	update := bson.M{
-		"$set": bson.M{
-			"token":         accessToken,
-			"refresh_token": refreshToken,
-			"token_expiry":  tokenExpiry,
-		},
-	}
-
-	_, err = collection.UpdateOne(
-		context.Background(),
-		bson.M{"_id": userID},
-		update,
-	)
+	err = h.Stores.Users.SetTokens(context.Background(), userID, accessToken, refreshToken, tokenExpiry)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating tokens"})
 		return
@@ -324,18 +326,27 @@ func RefreshToken(c *gin.Context) {
 	})
 }
 
-func BatchRegisterUsers(c *gin.Context) {
+func (h *Handler) BatchRegisterUsers(c *gin.Context) {
 	var users []models.User
 	if err := c.ShouldBindJSON(&users); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	collection := config.GetCollection("users")
 	var results []models.User
 	var errors []string
 
 	for _, user := range users {
+		if err := normalizeRole(&user); err != nil {
+			errors = append(errors, "Invalid role for user: "+user.Username)
+			continue
+		}
+
+		if err := h.assignUserOrganization(c, &user); err != nil {
+			errors = append(errors, "Invalid organization for user: "+user.Username)
+			continue
+		}
+
 		// Hash the password before storing
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 		if err != nil {
@@ -345,7 +356,7 @@ func BatchRegisterUsers(c *gin.Context) {
 		user.Password = string(hashedPassword)
 
 		// Generate tokens
-		accessToken, refreshToken, tokenExpiry, err := generateTokens(user.ID)
+		accessToken, refreshToken, tokenExpiry, err := h.generateTokens(user.ID)
 		if err != nil {
 			errors = append(errors, "Error generating tokens for user: "+user.Username)
 			continue
@@ -355,13 +366,11 @@ func BatchRegisterUsers(c *gin.Context) {
 		user.RefreshToken = refreshToken
 		user.TokenExpiry = tokenExpiry
 
-		result, err := collection.InsertOne(context.Background(), user)
-		if err != nil {
+		if err := h.Stores.Users.Create(context.Background(), &user); err != nil {
 			errors = append(errors, "Error creating user: "+user.Username)
 			continue
 		}
 
-		user.ID = result.InsertedID.(primitive.ObjectID)
 		// Don't send password back
 		user.Password = ""
 		results = append(results, user)
@@ -383,3 +392,107 @@ func BatchRegisterUsers(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, response)
 	}
 }
+
+// UpdateUserRole changes a user's role. The last remaining admin cannot be demoted.
+func (h *Handler) UpdateUserRole(c *gin.Context) {
+	id := c.Param("id")
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var request struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !models.IsValidRole(request.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role: " + request.Role})
+		return
+	}
+
+	if request.Role == models.RoleSuperAdmin && !isSuperAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": errSuperAdminOnly.Error()})
+		return
+	}
+
+	user, err := h.Stores.Users.Get(context.Background(), callerScope(c), objectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	// Every organization keeps at least one admin, and the system keeps at least one super-admin
+	if (user.Role == models.RoleAdmin || user.Role == models.RoleSuperAdmin) && request.Role != user.Role {
+		scope := store.Scope{Unrestricted: true}
+		if user.Role == models.RoleAdmin {
+			scope = store.Scope{OrganizationID: user.OrganizationID}
+		}
+		admins, err := h.Stores.Users.CountByRole(context.Background(), scope, user.Role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if admins <= 1 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Cannot demote the last " + user.Role})
+			return
+		}
+	}
+
+	if err := h.Stores.Users.SetRole(context.Background(), objectID, request.Role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User role updated successfully", "role": request.Role})
+}
+
+// BootstrapAdmin creates the first super-admin from the configured bootstrap credentials
+// when none exists yet. An existing user with that username is promoted instead, and
+// leaves their organization like any super-admin.
+// The super-admin then creates organizations and their admins.
+func (h *Handler) BootstrapAdmin() error {
+	count, err := h.Stores.Users.CountByRole(context.Background(), store.Scope{Unrestricted: true}, models.RoleSuperAdmin)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	cfg := h.Config
+	if cfg.BootstrapAdminUsername == "" || cfg.BootstrapAdminPassword == "" {
+		log.Println("No super-admin user exists; set BOOTSTRAP_ADMIN_USERNAME and BOOTSTRAP_ADMIN_PASSWORD to create one")
+		return nil
+	}
+
+	existing, err := h.Stores.Users.GetByUsername(context.Background(), cfg.BootstrapAdminUsername)
+	if err == nil {
+		return h.Stores.Users.SetRole(context.Background(), existing.ID, models.RoleSuperAdmin)
+	}
+	if err != store.ErrNotFound {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(cfg.BootstrapAdminPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	admin := models.User{
+		Username: cfg.BootstrapAdminUsername,
+		Email:    cfg.BootstrapAdminEmail,
+		Password: string(hashedPassword),
+		Role:     models.RoleSuperAdmin,
+	}
+	if err := h.Stores.Users.Create(context.Background(), &admin); err != nil {
+		return err
+	}
+
+	log.Println("Created bootstrap admin user:", admin.Username)
+	return nil
+}
@@ -2,19 +2,22 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
 
-	"github.com/ThirawatEu/vibration-sensor-gas-pipe/config"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/analysis"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/middleware"
 	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func CreateVibration(c *gin.Context) {
+func (h *Handler) CreateVibration(c *gin.Context) {
 	var vibration models.VibrationData
 	if err := c.ShouldBindJSON(&vibration); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -27,23 +30,65 @@ func CreateVibration(c *gin.Context) {
 		return
 	}
 
-	// Check if sensor exists
-	sensorCollection := config.GetCollection("sensors")
-	var sensor models.Sensor
-	err := sensorCollection.FindOne(context.Background(), bson.M{"_id": vibration.SensorID}).Decode(&sensor)
+	// Check if sensor exists in the caller's organization
+	sensor, err := h.findScopedSensor(c, vibration.SensorID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sensor ID"})
 		return
 	}
 
-	// Validate warning ID if provided
-	if !vibration.WarnID.IsZero() {
-		warningCollection := config.GetCollection("warnings")
-		var warning models.Warning
-		err := warningCollection.FindOne(context.Background(), bson.M{"_id": vibration.WarnID}).Decode(&warning)
+	if err := h.prepareVibration(sensor, &vibration); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	anomalous := h.scoreVibration(sensor, &vibration)
+
+	if err := h.Stores.Vibrations.Create(context.Background(), &vibration); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Insert failed"})
+		return
+	}
+
+	h.trackVibrationAlert(sensor, vibration)
+	if anomalous {
+		h.trackAnomalyAlert(sensor, vibration)
+	}
+	c.JSON(http.StatusCreated, vibration)
+}
+
+// prepareVibration applies the sensor's unit mode to a reading, classifies it against the
+// sensor's thresholds and ISO zone table, and fills in the timestamp when the client did
+// not send one. A client-supplied warn_id is kept only when warn_override is set;
+// otherwise it is replaced.
+func (h *Handler) prepareVibration(sensor models.Sensor, vibration *models.VibrationData) error {
+	if err := analysis.ApplyUnitMode(sensor.Config, vibration); err != nil {
+		return err
+	}
+
+	if vibration.WarnOverride {
+		if vibration.WarnID.IsZero() {
+			return errors.New("warn_id is required when warn_override is set")
+		}
+
+		warning, err := h.Stores.Warnings.Get(context.Background(), vibration.WarnID)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid warning ID"})
-			return
+			return fmt.Errorf("Invalid warning ID: %s", vibration.WarnID.Hex())
+		}
+		vibration.WarnLevel = warning.Level
+	} else {
+		level := analysis.ClassifyVibration(sensor.Config, *vibration)
+		warning, err := h.Stores.Warnings.GetByLevel(context.Background(), level)
+		if err != nil {
+			return fmt.Errorf("Warning level %d is not defined", level)
+		}
+		vibration.WarnID = warning.ID
+		vibration.WarnLevel = warning.Level
+	}
+
+	// Evaluate the ISO zone when the sensor has a machine class with a known table
+	vibration.ISOZone = ""
+	if sensor.Config.MachineClass != "" {
+		if table, err := h.Stores.ISOZones.GetByClass(context.Background(), sensor.Config.MachineClass); err == nil {
+			vibration.ISOZone = analysis.ClassifyISOZone(table, analysis.MaxAxisVelocity(*vibration))
 		}
 	}
 
@@ -51,77 +96,190 @@ func CreateVibration(c *gin.Context) {
 		vibration.Timestamp = time.Now()
 	}
 
-	collection := config.GetCollection("vibrations")
-	result, err := collection.InsertOne(context.Background(), vibration)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Insert failed"})
+	return nil
+}
+
+// errInsertFailed marks storage failures, as opposed to validation errors, in StoreSensorVibration.
+var errInsertFailed = errors.New("Insert failed")
+
+// StoreSensorVibration validates and stores a reading sent by an authenticated sensor.
+// The sensor ID always comes from the sensor, never from the payload. It is the
+// shared ingestion path of the device HTTP endpoints and the MQTT listener.
+func (h *Handler) StoreSensorVibration(sensor models.Sensor, vibration *models.VibrationData) error {
+	vibration.ID = primitive.NilObjectID
+	vibration.SensorID = sensor.ID
+	if err := h.prepareVibration(sensor, vibration); err != nil {
+		return err
+	}
+	anomalous := h.scoreVibration(sensor, vibration)
+
+	if err := h.Stores.Vibrations.Create(context.Background(), vibration); err != nil {
+		return errInsertFailed
+	}
+	h.markSeen(sensor, nil)
+
+	h.trackVibrationAlert(sensor, *vibration)
+	if anomalous {
+		h.trackAnomalyAlert(sensor, *vibration)
+	}
+	return nil
+}
+
+// IngestVibration stores a reading posted by a device authenticated with its sensor token.
+func (h *Handler) IngestVibration(c *gin.Context) {
+	sensor, ok := middleware.GetSensor(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sensor not authenticated"})
+		return
+	}
+
+	var vibration models.VibrationData
+	if err := c.ShouldBindJSON(&vibration); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.StoreSensorVibration(sensor, &vibration); err != nil {
+		status := http.StatusBadRequest
+		if err == errInsertFailed {
+			status = http.StatusInternalServerError
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
-	vibration.ID = result.InsertedID.(primitive.ObjectID)
 	c.JSON(http.StatusCreated, vibration)
 }
 
-func GetVibrations(c *gin.Context) {
+// IngestVibrationBatch is the batch variant of IngestVibration.
+func (h *Handler) IngestVibrationBatch(c *gin.Context) {
+	sensor, ok := middleware.GetSensor(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sensor not authenticated"})
+		return
+	}
+
 	var vibrations []models.VibrationData
-	collection := config.GetCollection("vibrations")
+	if err := c.ShouldBindJSON(&vibrations); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(vibrations) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No vibration data provided"})
+		return
+	}
+
+	for i := range vibrations {
+		vibrations[i].ID = primitive.NilObjectID
+		vibrations[i].SensorID = sensor.ID
+		if err := h.prepareVibration(sensor, &vibrations[i]); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Score only once the whole batch is valid, so a rejected batch leaves the baseline alone
+	anomalous := make([]bool, len(vibrations))
+	for i := range vibrations {
+		anomalous[i] = h.scoreVibration(sensor, &vibrations[i])
+	}
+
+	if err := h.Stores.Vibrations.CreateMany(context.Background(), vibrations); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Batch insert failed"})
+		return
+	}
+	h.markSeen(sensor, nil)
 
+	for i, vibration := range vibrations {
+		h.trackVibrationAlert(sensor, vibration)
+		if anomalous[i] {
+			h.trackAnomalyAlert(sensor, vibration)
+		}
+	}
+
+	response := gin.H{
+		"message": "Successfully registered batch of vibration data",
+		"count":   len(vibrations),
+		"data":    vibrations,
+	}
+
+	// A batch is a natural window of readings; its features are best effort
+	if len(vibrations) > 1 {
+		features, err := h.storeWindowFeatures(sensor, vibrations)
+		if err != nil {
+			log.Println("Failed to store window features for sensor", sensor.ID.Hex()+":", err)
+		} else {
+			response["features"] = features
+		}
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+func (h *Handler) GetVibrations(c *gin.Context) {
 	// Pagination parameters
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
 	skip := (page - 1) * limit
 
-	filter := bson.M{}
+	query := store.VibrationQuery{Skip: int64(skip), Limit: int64(limit)}
 
+	var requested primitive.ObjectID
 	if sensorID := c.Query("sensor_id"); sensorID != "" {
 		if id, err := primitive.ObjectIDFromHex(sensorID); err == nil {
-			filter["sensor_id"] = id
+			requested = id
+			query.SensorIDs = []primitive.ObjectID{id}
 		}
 	}
 
 	if warnID := c.Query("warn_id"); warnID != "" {
 		if id, err := primitive.ObjectIDFromHex(warnID); err == nil {
-			filter["warn_id"] = id
+			query.WarnID = id
 		}
 	}
 
+	if warnLevel := c.Query("warn_level"); warnLevel != "" {
+		if level, err := strconv.Atoi(warnLevel); err == nil {
+			query.WarnLevel = level
+		}
+	}
+
+	query.ISOZone = c.Query("iso_zone")
+	query.UnitMismatch = c.Query("unit_mismatch") == "true"
+
 	if startDate := c.Query("start_date"); startDate != "" {
 		if t, err := time.Parse(time.RFC3339, startDate); err == nil {
-			filter["timestamp"] = bson.M{"$gte": t}
+			query.From = t
 		}
 	}
 
 	if endDate := c.Query("end_date"); endDate != "" {
 		if t, err := time.Parse(time.RFC3339, endDate); err == nil {
-			if _, ok := filter["timestamp"]; ok {
-				filter["timestamp"].(bson.M)["$lte"] = t
-			} else {
-				filter["timestamp"] = bson.M{"$lte": t}
-			}
+			query.To = t
 		}
 	}
 
-	// Add pagination options
-	opts := options.Find().
-		SetSkip(int64(skip)).
-		SetLimit(int64(limit)).
-		SetSort(bson.D{{Key: "timestamp", Value: -1}})
-
-	cursor, err := collection.Find(context.Background(), filter, opts)
+	// Only return readings of sensors in the caller's organization
+	sensorIDs, restricted, err := h.scopedSensorIDs(c)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer cursor.Close(context.Background())
-
-	for cursor.Next(context.Background()) {
-		var vib models.VibrationData
-		cursor.Decode(&vib)
-		vibrations = append(vibrations, vib)
+	if restricted {
+		if !requested.IsZero() {
+			visible := []primitive.ObjectID{}
+			for _, id := range sensorIDs {
+				if id == requested {
+					visible = append(visible, id)
+				}
+			}
+			sensorIDs = visible
+		}
+		query.SensorIDs = sensorIDs
 	}
 
-	// Get total count for pagination
-	total, err := collection.CountDocuments(context.Background(), filter)
+	vibrations, total, err := h.Stores.Vibrations.List(context.Background(), query)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -137,7 +295,7 @@ func GetVibrations(c *gin.Context) {
 	})
 }
 
-func GetVibration(c *gin.Context) {
+func (h *Handler) GetVibration(c *gin.Context) {
 	id := c.Param("id")
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -145,9 +303,7 @@ func GetVibration(c *gin.Context) {
 		return
 	}
 
-	var vib models.VibrationData
-	collection := config.GetCollection("vibrations")
-	err = collection.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&vib)
+	vib, err := h.findScopedVibration(c, objectID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Vibration data not found"})
 		return
@@ -156,7 +312,20 @@ func GetVibration(c *gin.Context) {
 	c.JSON(http.StatusOK, vib)
 }
 
-func UpdateVibration(c *gin.Context) {
+// findScopedVibration loads a reading only if its sensor belongs to the caller's organization.
+func (h *Handler) findScopedVibration(c *gin.Context, id primitive.ObjectID) (models.VibrationData, error) {
+	vib, err := h.Stores.Vibrations.Get(context.Background(), id)
+	if err != nil {
+		return vib, err
+	}
+
+	if _, err := h.findScopedSensor(c, vib.SensorID); err != nil {
+		return models.VibrationData{}, err
+	}
+	return vib, nil
+}
+
+func (h *Handler) UpdateVibration(c *gin.Context) {
 	id := c.Param("id")
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -170,43 +339,42 @@ func UpdateVibration(c *gin.Context) {
 		return
 	}
 
-	collection := config.GetCollection("vibrations")
-	update := bson.M{
-		"$set": bson.M{
-			"sensor_id":   vib.SensorID,
-			"warn_id":     vib.WarnID,
-			"timestamp":   vib.Timestamp,
-			"x_axisg":     vib.X_Axisg,
-			"y_axisg":     vib.Y_Axisg,
-			"z_axisg":     vib.Z_Axisg,
-			"x_axismm_s2": vib.X_Axismm_s2,
-			"y_axismm_s2": vib.Y_Axismm_s2,
-			"z_axismm_s2": vib.Z_Axismm_s2,
-			"x_axismm_s":  vib.X_Axismm_s,
-			"y_axismm_s":  vib.Y_Axismm_s,
-			"z_axismm_s":  vib.Z_Axismm_s,
-		},
+	existing, err := h.findScopedVibration(c, objectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vibration data not found"})
+		return
 	}
 
-	result, err := collection.UpdateOne(
-		context.Background(),
-		bson.M{"_id": objectID},
-		update,
-	)
+	sensor, err := h.findScopedSensor(c, vib.SensorID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sensor ID"})
+		return
+	}
+
+	// Re-classify the edited reading unless the client overrides the level
+	if err := h.prepareVibration(sensor, &vib); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if result.MatchedCount == 0 {
+	// The score belongs to the baseline at ingestion time; editing does not rescore
+	vib.AnomalyScore = existing.AnomalyScore
+
+	vib.ID = objectID
+	err = h.Stores.Vibrations.Update(context.Background(), vib)
+	if err == store.ErrNotFound {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Vibration data not found"})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Vibration data updated"})
 }
 
-func DeleteVibration(c *gin.Context) {
+func (h *Handler) DeleteVibration(c *gin.Context) {
 	id := c.Param("id")
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -214,78 +382,73 @@ func DeleteVibration(c *gin.Context) {
 		return
 	}
 
-	collection := config.GetCollection("vibrations")
-	result, err := collection.DeleteOne(context.Background(), bson.M{"_id": objectID})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if _, err := h.findScopedVibration(c, objectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vibration data not found"})
 		return
 	}
 
-	if result.DeletedCount == 0 {
+	err = h.Stores.Vibrations.Delete(context.Background(), objectID)
+	if err == store.ErrNotFound {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Vibration data not found"})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Vibration data deleted"})
 }
 
-func BatchRegisterVibrations(c *gin.Context) {
+func (h *Handler) BatchRegisterVibrations(c *gin.Context) {
 	var vibrations []models.VibrationData
 	if err := c.ShouldBindJSON(&vibrations); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Validate each vibration entry
-	for _, vibration := range vibrations {
+	// Validate each vibration entry, keeping the sensor of each for alert tracking
+	sensors := make([]models.Sensor, len(vibrations))
+	for i := range vibrations {
+		vibration := &vibrations[i]
+
 		// Validate sensor ID
 		if vibration.SensorID.IsZero() {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Sensor ID is required for all entries"})
 			return
 		}
 
-		// Check if sensor exists
-		sensorCollection := config.GetCollection("sensors")
-		var sensor models.Sensor
-		err := sensorCollection.FindOne(context.Background(), bson.M{"_id": vibration.SensorID}).Decode(&sensor)
+		// Check if sensor exists in the caller's organization
+		sensor, err := h.findScopedSensor(c, vibration.SensorID)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sensor ID: " + vibration.SensorID.Hex()})
 			return
 		}
 
-		// Validate warning ID if provided
-		if !vibration.WarnID.IsZero() {
-			warningCollection := config.GetCollection("warnings")
-			var warning models.Warning
-			err := warningCollection.FindOne(context.Background(), bson.M{"_id": vibration.WarnID}).Decode(&warning)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid warning ID: " + vibration.WarnID.Hex()})
-				return
-			}
-		}
-
-		// Set timestamp if not provided
-		if vibration.Timestamp.IsZero() {
-			vibration.Timestamp = time.Now()
+		// Classify the reading and set timestamp if not provided
+		if err := h.prepareVibration(sensor, vibration); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
+		sensors[i] = sensor
 	}
 
-	// Prepare documents for bulk insert
-	documents := make([]interface{}, len(vibrations))
-	for i, vibration := range vibrations {
-		documents[i] = vibration
+	// Score only once the whole batch is valid, so a rejected batch leaves baselines alone
+	anomalous := make([]bool, len(vibrations))
+	for i := range vibrations {
+		anomalous[i] = h.scoreVibration(sensors[i], &vibrations[i])
 	}
 
-	collection := config.GetCollection("vibrations")
-	result, err := collection.InsertMany(context.Background(), documents)
-	if err != nil {
+	if err := h.Stores.Vibrations.CreateMany(context.Background(), vibrations); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Batch insert failed"})
 		return
 	}
 
-	// Update IDs in the response
-	for i, id := range result.InsertedIDs {
-		vibrations[i].ID = id.(primitive.ObjectID)
+	for i, vibration := range vibrations {
+		h.trackVibrationAlert(sensors[i], vibration)
+		if anomalous[i] {
+			h.trackAnomalyAlert(sensors[i], vibration)
+		}
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
@@ -0,0 +1,245 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/analysis"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/middleware"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// StoreSensorWaveform validates a waveform sent by an authenticated sensor, computes its
+// spectrum with the sensor's FMax and LOR and its features, and stores all three.
+func (h *Handler) StoreSensorWaveform(sensor models.Sensor, waveform *models.Waveform) (models.Spectrum, models.FeatureSet, error) {
+	waveform.ID = primitive.NilObjectID
+	waveform.SensorID = sensor.ID
+	if waveform.Timestamp.IsZero() {
+		waveform.Timestamp = time.Now()
+	}
+	if waveform.SampleRate == 0 {
+		waveform.SampleRate = sensor.Config.SampleRate
+	}
+
+	if err := analysis.ValidateWaveform(waveform); err != nil {
+		return models.Spectrum{}, models.FeatureSet{}, err
+	}
+
+	// Compute before storing so a sensor without a usable spectral config stores nothing
+	spectrum, err := analysis.ComputeSpectrum(sensor.Config, *waveform)
+	if err != nil {
+		return models.Spectrum{}, models.FeatureSet{}, err
+	}
+
+	// The waveform is written last, so that no reader or leak analysis sees one without
+	// its spectrum and features. If a write fails, the records before it are removed.
+	waveform.ID = primitive.NewObjectID()
+	spectrum.WaveformID = waveform.ID
+	if err := h.Stores.Spectra.Create(context.Background(), &spectrum); err != nil {
+		return models.Spectrum{}, models.FeatureSet{}, errInsertFailed
+	}
+
+	features := analysis.WaveformFeatures(sensor.Config, *waveform)
+	if err := h.Stores.Features.Create(context.Background(), &features); err != nil {
+		h.discardWaveformResults(spectrum.ID, primitive.NilObjectID)
+		return models.Spectrum{}, models.FeatureSet{}, errInsertFailed
+	}
+
+	if err := h.Stores.Waveforms.Create(context.Background(), waveform); err != nil {
+		h.discardWaveformResults(spectrum.ID, features.ID)
+		return models.Spectrum{}, models.FeatureSet{}, errInsertFailed
+	}
+
+	h.markSeen(sensor, nil)
+	h.trackFeatureAlert(sensor, features)
+	return spectrum, features, nil
+}
+
+// discardWaveformResults removes the spectrum and features stored for a waveform that
+// could not be stored itself. A zero ID is skipped.
+func (h *Handler) discardWaveformResults(spectrumID, featuresID primitive.ObjectID) {
+	if !spectrumID.IsZero() {
+		if err := h.Stores.Spectra.Delete(context.Background(), spectrumID); err != nil {
+			log.Println("Failed to remove spectrum", spectrumID.Hex()+":", err)
+		}
+	}
+	if !featuresID.IsZero() {
+		if err := h.Stores.Features.Delete(context.Background(), featuresID); err != nil {
+			log.Println("Failed to remove features", featuresID.Hex()+":", err)
+		}
+	}
+}
+
+// IngestWaveform stores a time-domain sample block posted by a device authenticated with
+// its sensor token, and returns the computed spectrum and features.
+func (h *Handler) IngestWaveform(c *gin.Context) {
+	sensor, ok := middleware.GetSensor(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sensor not authenticated"})
+		return
+	}
+
+	var waveform models.Waveform
+	if err := c.ShouldBindJSON(&waveform); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	spectrum, features, err := h.StoreSensorWaveform(sensor, &waveform)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == errInsertFailed {
+			status = http.StatusInternalServerError
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"waveform_id": waveform.ID,
+		"samples":     waveform.Samples,
+		"spectrum":    spectrum,
+		"features":    features,
+	})
+}
+
+// parseWaveformQuery reads the pagination and from/to range of a waveform or spectrum listing.
+func parseWaveformQuery(c *gin.Context, sensorID primitive.ObjectID) (store.WaveformQuery, int, int) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	query := store.WaveformQuery{SensorID: sensorID, Skip: int64((page - 1) * limit), Limit: int64(limit)}
+
+	if from := c.Query("from"); from != "" {
+		if t, err := time.Parse(time.RFC3339, from); err == nil {
+			query.From = t
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		if t, err := time.Parse(time.RFC3339, to); err == nil {
+			query.To = t
+		}
+	}
+	return query, page, limit
+}
+
+// GetSensorWaveforms lists a sensor's waveforms, newest first, without their samples.
+func (h *Handler) GetSensorWaveforms(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if _, err := h.findScopedSensor(c, objectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found"})
+		return
+	}
+
+	query, page, limit := parseWaveformQuery(c, objectID)
+	waveforms, total, err := h.Stores.Waveforms.List(context.Background(), query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": waveforms,
+		"pagination": gin.H{
+			"total": total,
+			"page":  page,
+			"limit": limit,
+		},
+	})
+}
+
+// GetSensorSpectra lists a sensor's spectra, newest first, e.g. for waterfall plots.
+func (h *Handler) GetSensorSpectra(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if _, err := h.findScopedSensor(c, objectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found"})
+		return
+	}
+
+	query, page, limit := parseWaveformQuery(c, objectID)
+	spectra, total, err := h.Stores.Spectra.List(context.Background(), query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": spectra,
+		"pagination": gin.H{
+			"total": total,
+			"page":  page,
+			"limit": limit,
+		},
+	})
+}
+
+// GetWaveform returns a waveform with its samples.
+func (h *Handler) GetWaveform(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	waveform, err := h.findScopedWaveform(c, objectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Waveform not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, waveform)
+}
+
+// GetWaveformSpectrum returns the spectrum computed from a waveform.
+func (h *Handler) GetWaveformSpectrum(c *gin.Context) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if _, err := h.findScopedWaveform(c, objectID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Waveform not found"})
+		return
+	}
+
+	spectrum, err := h.Stores.Spectra.GetByWaveform(context.Background(), objectID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Spectrum not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, spectrum)
+}
+
+// findScopedWaveform loads a waveform only if its sensor belongs to the caller's organization.
+func (h *Handler) findScopedWaveform(c *gin.Context, id primitive.ObjectID) (models.Waveform, error) {
+	waveform, err := h.Stores.Waveforms.Get(context.Background(), id)
+	if err != nil {
+		return waveform, err
+	}
+
+	if _, err := h.findScopedSensor(c, waveform.SensorID); err != nil {
+		return models.Waveform{}, err
+	}
+	return waveform, nil
+}
@@ -0,0 +1,266 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/webhooks"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// replayTimeout bounds how long a replay waits for room in the webhook queue.
+const replayTimeout = 5 * time.Second
+
+// validateWebhook applies defaults and checks that the URL is a public http(s) address and
+// that filtered sensors belong to the webhook's organization.
+func (h *Handler) validateWebhook(webhook *models.Webhook) error {
+	if err := webhooks.ValidateURL(webhook.URL); err != nil {
+		return err
+	}
+	if webhook.MinLevel == 0 {
+		webhook.MinLevel = webhooks.DefaultMinLevel
+	}
+	if webhook.MinLevel < models.WarningLevelNormal || webhook.MinLevel > models.WarningLevelEmergency {
+		return errInvalidMinLevel
+	}
+	if webhook.SensorIDs == nil {
+		webhook.SensorIDs = []primitive.ObjectID{}
+	}
+
+	scope := store.Scope{OrganizationID: webhook.OrganizationID}
+	for _, sensorID := range webhook.SensorIDs {
+		_, err := h.Stores.Sensors.Get(context.Background(), scope, sensorID)
+		if err == store.ErrNotFound {
+			return errInvalidSensorFilter
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateWebhook registers a webhook. A signing secret is generated when none is given;
+// this is the only response that includes it.
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	var webhook models.Webhook
+	if err := c.ShouldBindJSON(&webhook); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	organizationID, err := h.resolveOrganization(c, webhook.OrganizationID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	webhook.OrganizationID = organizationID
+
+	if err := h.validateWebhook(&webhook); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if webhook.Secret == "" {
+		secret, err := generateTokenHex(32)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating secret"})
+			return
+		}
+		webhook.Secret = secret
+	}
+
+	webhook.ID = primitive.NilObjectID
+	webhook.Active = true
+	webhook.CreatedAt = time.Now()
+
+	if err := h.Stores.Webhooks.Create(context.Background(), &webhook); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhook)
+}
+
+func (h *Handler) GetWebhooks(c *gin.Context) {
+	hooks, err := h.Stores.Webhooks.List(context.Background(), callerScope(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Don't send secrets back
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+
+	c.JSON(http.StatusOK, hooks)
+}
+
+func (h *Handler) GetWebhook(c *gin.Context) {
+	id := c.Param("id")
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	webhook, err := h.Stores.Webhooks.Get(context.Background(), callerScope(c), objectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	// Don't send secret back
+	webhook.Secret = ""
+	c.JSON(http.StatusOK, webhook)
+}
+
+// UpdateWebhook changes a webhook's target and filters. The secret is only
+// replaced when a new one is sent.
+func (h *Handler) UpdateWebhook(c *gin.Context) {
+	id := c.Param("id")
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var request struct {
+		models.Webhook
+		Active *bool `json:"active"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	existing, err := h.Stores.Webhooks.Get(context.Background(), callerScope(c), objectID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	webhook := request.Webhook
+	webhook.ID = existing.ID
+	webhook.OrganizationID = existing.OrganizationID
+	if err := h.validateWebhook(&webhook); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	webhook.Active = existing.Active
+	if request.Active != nil {
+		webhook.Active = *request.Active
+	}
+	if webhook.Secret == "" {
+		webhook.Secret = existing.Secret
+	}
+
+	if err := h.Stores.Webhooks.Update(context.Background(), webhook); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook updated successfully"})
+}
+
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	id := c.Param("id")
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	err = h.Stores.Webhooks.Delete(context.Background(), callerScope(c), objectID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// GetWebhookDeliveries lists the delivery log of a webhook, newest first.
+func (h *Handler) GetWebhookDeliveries(c *gin.Context) {
+	id := c.Param("id")
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	// Pagination parameters
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	skip := (page - 1) * limit
+
+	query := store.DeliveryQuery{
+		Scope:     callerScope(c),
+		WebhookID: objectID,
+		Status:    c.Query("status"),
+		Skip:      int64(skip),
+		Limit:     int64(limit),
+	}
+
+	deliveries, total, err := h.Stores.Deliveries.List(context.Background(), query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": deliveries,
+		"pagination": gin.H{
+			"total": total,
+			"page":  page,
+			"limit": limit,
+		},
+	})
+}
+
+// ReplayWebhookDelivery sends a failed delivery again with its original payload.
+func (h *Handler) ReplayWebhookDelivery(c *gin.Context) {
+	id := c.Param("id")
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	err = h.Stores.Deliveries.Requeue(context.Background(), callerScope(c), objectID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Failed delivery not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if h.dispatcher != nil {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), replayTimeout)
+		defer cancel()
+		if err := h.dispatcher.Replay(ctx, objectID); err != nil {
+			// Mark it failed again so that it can be replayed once the queue drains
+			attempt := models.DeliveryAttempt{At: time.Now(), Error: "not replayed: webhook queue full"}
+			if err := h.Stores.Deliveries.RecordAttempt(context.Background(), objectID, attempt, models.DeliveryStatusFailed); err != nil {
+				log.Println("Failed to record webhook replay", objectID.Hex()+":", err)
+			}
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Webhook queue is full, try again later"})
+			return
+		}
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Delivery queued for replay"})
+}
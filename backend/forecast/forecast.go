@@ -0,0 +1,81 @@
+// Package forecast periodically projects every sensor's velocity trend and raises
+// predictive alerts for sensors heading into Critical.
+package forecast
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/analysis"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/config"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/controllers"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+)
+
+// Options configures a Job.
+type Options struct {
+	Interval time.Duration // How often the job runs
+	Request  controllers.ForecastRequest
+}
+
+// OptionsFromConfig builds forecast options from the application config.
+func OptionsFromConfig(cfg *config.Config) Options {
+	return Options{
+		Interval: cfg.ForecastInterval,
+		Request:  controllers.ForecastRequestFromConfig(cfg),
+	}
+}
+
+// Job forecasts every sensor with an alarm threshold and raises its predictive alert
+// through the handler, so webhooks are notified like for any other alert.
+type Job struct {
+	handler *controllers.Handler
+	opts    Options
+}
+
+// NewJob returns a job that forecasts through handler.
+func NewJob(handler *controllers.Handler, opts Options) *Job {
+	return &Job{handler: handler, opts: opts}
+}
+
+// Start runs the job once right away and then on every interval in the background.
+func (j *Job) Start() {
+	go func() {
+		ticker := time.NewTicker(j.opts.Interval)
+		defer ticker.Stop()
+
+		for {
+			if err := j.RunOnce(context.Background(), time.Now()); err != nil {
+				log.Println("Forecasting failed:", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// RunOnce forecasts every sensor up to now. Sensors without enough recent data are
+// skipped; other failures are logged and do not stop the remaining sensors.
+func (j *Job) RunOnce(ctx context.Context, now time.Time) error {
+	sensors, err := j.handler.Stores.Sensors.List(ctx, store.Scope{Unrestricted: true})
+	if err != nil {
+		return err
+	}
+
+	for _, sensor := range sensors {
+		if sensor.Config.AlarmThs <= 0 {
+			continue
+		}
+
+		forecast, err := j.handler.ForecastSensor(ctx, sensor, j.opts.Request, now)
+		if err == analysis.ErrTooFewPoints {
+			continue
+		}
+		if err != nil {
+			log.Println("Forecast of sensor", sensor.ID.Hex(), "failed:", err)
+			continue
+		}
+		j.handler.TrackPredictiveAlert(sensor, forecast)
+	}
+	return nil
+}
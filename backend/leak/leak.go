@@ -0,0 +1,205 @@
+// Package leak pairs time-synchronized waveforms from the sensors at either end of each
+// pipe segment and cross-correlates them to find leak candidates.
+package leak
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/analysis"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/config"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Options configures a Job.
+type Options struct {
+	Interval      time.Duration // How often the job runs
+	SyncTolerance time.Duration // Largest start time difference of a waveform pair
+	Lateness      time.Duration // How long a waveform waits for its partner from the other sensor
+	Backfill      time.Duration // How far back the first analysis of a segment looks
+	MinConfidence float64       // Pairs below this confidence are not stored
+}
+
+// OptionsFromConfig builds leak analysis options from the application config.
+func OptionsFromConfig(cfg *config.Config) Options {
+	return Options{
+		Interval:      cfg.LeakInterval,
+		SyncTolerance: cfg.LeakSyncTolerance,
+		Lateness:      cfg.LeakLateness,
+		Backfill:      cfg.LeakBackfill,
+		MinConfidence: cfg.LeakMinConfidence,
+	}
+}
+
+// Job analyzes the new waveforms of every pipe segment and stores leak candidates.
+type Job struct {
+	segments  store.PipeSegmentStore
+	waveforms store.WaveformStore
+	leaks     store.LeakCandidateStore
+	opts      Options
+
+	// Serializes analyses so a segment's waveforms are never paired twice
+	mu sync.Mutex
+}
+
+// NewJob returns a job that reads segments and waveforms and stores candidates in leaks.
+func NewJob(segments store.PipeSegmentStore, waveforms store.WaveformStore, leaks store.LeakCandidateStore, opts Options) *Job {
+	return &Job{segments: segments, waveforms: waveforms, leaks: leaks, opts: opts}
+}
+
+// Start runs the job once right away and then on every interval in the background.
+func (j *Job) Start() {
+	go func() {
+		ticker := time.NewTicker(j.opts.Interval)
+		defer ticker.Stop()
+
+		for {
+			if err := j.RunOnce(context.Background(), time.Now()); err != nil {
+				log.Println("Leak analysis failed:", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// RunOnce analyzes every segment of every organization. A failing segment is logged
+// and does not stop the others.
+func (j *Job) RunOnce(ctx context.Context, now time.Time) error {
+	segments, err := j.segments.List(ctx, store.Scope{Unrestricted: true})
+	if err != nil {
+		return err
+	}
+
+	for _, segment := range segments {
+		if _, err := j.Analyze(ctx, segment, now); err != nil {
+			log.Println("Leak analysis of segment", segment.ID.Hex(), "failed:", err)
+		}
+	}
+	return nil
+}
+
+// Analyze pairs the waveforms of the segment's sensor A taken since the segment was last
+// analyzed with the closest waveform of sensor B within the sync tolerance, correlates
+// each pair, and stores the candidates confident enough. A waveform without a partner is
+// retried on later runs until it is older than the allowed lateness. It returns the
+// stored candidates, oldest first.
+func (j *Job) Analyze(ctx context.Context, segment models.PipeSegment, now time.Time) ([]models.LeakCandidate, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	// Re-read the segment so a concurrent run's progress is not repeated
+	segment, err := j.segments.Get(ctx, store.Scope{Unrestricted: true}, segment.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	since := segment.LastAnalyzedAt
+	if since.IsZero() {
+		since = now.Add(-j.opts.Backfill)
+	}
+
+	waveformsA, err := j.listWaveforms(ctx, segment.SensorA, since.Add(time.Nanosecond), now)
+	if err != nil {
+		return nil, err
+	}
+	if len(waveformsA) == 0 {
+		return nil, nil
+	}
+	waveformsB, err := j.listWaveforms(ctx, segment.SensorB, since.Add(-j.opts.SyncTolerance), now.Add(j.opts.SyncTolerance))
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []models.LeakCandidate{}
+	analyzed := since
+	for _, a := range waveformsA {
+		b, ok := j.partner(a, waveformsB)
+		if !ok && now.Sub(a.Timestamp) < j.opts.Lateness {
+			break
+		}
+
+		if ok {
+			var candidate models.LeakCandidate
+			var found bool
+			candidate, found, err = j.correlate(ctx, segment, a, b, now)
+			if err != nil {
+				break
+			}
+			if found {
+				candidates = append(candidates, candidate)
+			}
+		}
+		analyzed = a.Timestamp
+	}
+
+	// Record progress even after a failure so stored candidates are not found again
+	if analyzed.After(since) {
+		if setErr := j.segments.SetLastAnalyzed(ctx, segment.ID, analyzed); setErr != nil && err == nil {
+			err = setErr
+		}
+	}
+	return candidates, err
+}
+
+// listWaveforms returns the waveforms of a sensor in [from, to] without samples, oldest first.
+func (j *Job) listWaveforms(ctx context.Context, sensorID primitive.ObjectID, from, to time.Time) ([]models.Waveform, error) {
+	waveforms, _, err := j.waveforms.List(ctx, store.WaveformQuery{SensorID: sensorID, From: from, To: to})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(waveforms, func(i, k int) bool {
+		return waveforms[i].Timestamp.Before(waveforms[k].Timestamp)
+	})
+	return waveforms, nil
+}
+
+// partner returns the waveform in candidates closest in time to a, if one starts within
+// the sync tolerance and has a's sample rate.
+func (j *Job) partner(a models.Waveform, candidates []models.Waveform) (models.Waveform, bool) {
+	var best models.Waveform
+	bestOffset := j.opts.SyncTolerance + 1
+	for _, b := range candidates {
+		offset := b.Timestamp.Sub(a.Timestamp)
+		if offset < 0 {
+			offset = -offset
+		}
+		if offset < bestOffset && b.SampleRate == a.SampleRate {
+			best, bestOffset = b, offset
+		}
+	}
+	return best, bestOffset <= j.opts.SyncTolerance
+}
+
+// correlate loads the samples of a pair, locates its noise source and stores the
+// candidate when it reaches the minimum confidence. A pair that cannot be correlated,
+// e.g. because the waveforms share no axis, is logged and skipped.
+func (j *Job) correlate(ctx context.Context, segment models.PipeSegment, a, b models.Waveform, now time.Time) (models.LeakCandidate, bool, error) {
+	a, err := j.waveforms.Get(ctx, a.ID)
+	if err != nil {
+		return models.LeakCandidate{}, false, err
+	}
+	b, err = j.waveforms.Get(ctx, b.ID)
+	if err != nil {
+		return models.LeakCandidate{}, false, err
+	}
+
+	candidate, err := analysis.LocateLeak(segment, a, b)
+	if err != nil {
+		log.Println("Skipping waveforms", a.ID.Hex(), "and", b.ID.Hex(), "of segment", segment.ID.Hex()+":", err)
+		return models.LeakCandidate{}, false, nil
+	}
+	if candidate.Confidence < j.opts.MinConfidence {
+		return models.LeakCandidate{}, false, nil
+	}
+
+	candidate.DetectedAt = now
+	if err := j.leaks.Create(ctx, &candidate); err != nil {
+		return models.LeakCandidate{}, false, err
+	}
+	return candidate, true, nil
+}
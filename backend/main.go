@@ -1,81 +1,119 @@
 package main
 
 import (
+	"context"
 	"log"
-	"os"
 
 	"github.com/ThirawatEu/vibration-sensor-gas-pipe/config"
 	"github.com/ThirawatEu/vibration-sensor-gas-pipe/controllers"
-
-	"github.com/gin-gonic/gin"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/forecast"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/leak"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/mqtt"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/rollup"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/router"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store/mongostore"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store/pgstore"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/watchdog"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/webhooks"
 )
 
 func main() {
+	// Load and validate configuration; refuse to start on missing values
+	err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if config.GetConfig().FirmwarePublicKey == "" {
+		log.Println("FIRMWARE_PUBLIC_KEY is not set, so firmware signatures are not verified and rollouts cannot be started")
+	}
+
 	// Initialize MongoDB connection
-	err := config.ConnectDB()
+	err = config.ConnectDB()
 	if err != nil {
 		log.Fatal("Failed to connect to MongoDB:", err)
 	}
+	stores := mongostore.New(config.Database())
+	if err := mongostore.EnsureIndexes(context.Background(), config.Database()); err != nil {
+		log.Fatal("Failed to create MongoDB indexes:", err)
+	}
+
+	// Keep vibration readings in PostgreSQL/TimescaleDB when configured
+	if cfg := config.GetConfig(); cfg.VibrationStore == config.VibrationStorePostgres {
+		db, err := pgstore.Open(cfg.PostgresURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+
+		if err := pgstore.Migrate(context.Background(), db); err != nil {
+			log.Fatal("Failed to migrate PostgreSQL schema:", err)
+		}
+		stores.Vibrations = pgstore.NewVibrationStore(db)
+		stores.Rollups = pgstore.NewRollupStore(db)
+	}
+
+	// Keep the vibration rollup tiers current and expire old data
+	rollup.NewJob(stores.Vibrations, stores.Rollups, rollup.OptionsFromConfig(config.GetConfig())).Start()
+
+	// Look for leaks between neighbouring sensors
+	leakJob := leak.NewJob(stores.PipeSegments, stores.Waveforms, stores.Leaks, leak.OptionsFromConfig(config.GetConfig()))
+	leakJob.Start()
+
+	// Start the asynchronous webhook dispatcher
+	dispatcher := webhooks.NewDispatcher(stores.Webhooks, stores.Deliveries)
+	dispatcher.Start(4)
+	defer dispatcher.Stop()
+
+	h := controllers.NewHandler(stores, config.GetConfig(), dispatcher)
+	h.LeakJob = leakJob
 
 	// Initialize default warnings
-	err = controllers.InitializeWarnings()
+	err = h.InitializeWarnings()
 	if err != nil {
 		log.Fatal("Failed to initialize warnings:", err)
 	}
 
-	// Initialize Gin router
-	r := gin.Default()
-
-	// Sensor Management Routes
-	// Handles CRUD operations for vibration sensors
-	r.POST("/sensors", controllers.CreateSensor)                        // Create new sensor
-	r.POST("/sensors/batch-register", controllers.BatchRegisterSensors) // Batch register sensors
-	r.GET("/sensors", controllers.GetSensors)                           // Get all sensors
-	r.GET("/sensors/:id", controllers.GetSensor)                        // Get specific sensor
-	r.PUT("/sensors/:id", controllers.UpdateSensor)                     // Update sensor
-	r.DELETE("/sensors/:id", controllers.DeleteSensor)                  // Delete sensor
-	r.POST("/sensors/register", controllers.RegisterSensor)             // Register sensor and get token
-
-	// User Management Routes
-	// Handles user registration, authentication, and management
-	r.POST("/users", controllers.CreateUser)                        // Register new user
-	r.POST("/users/batch-register", controllers.BatchRegisterUsers) // Batch register users
-	r.GET("/users", controllers.GetUsers)                           // Get all users
-	r.GET("/users/:id", controllers.GetUser)                        // Get specific user
-	r.PUT("/users/:id", controllers.UpdateUser)                     // Update user
-	r.DELETE("/users/:id", controllers.DeleteUser)                  // Delete user
-
-	r.POST("/login", controllers.Login)                // User login
-	r.POST("/refresh-token", controllers.RefreshToken) // Refresh access token
-
-	// Warning Management Routes
-	// Handles retrieval of warning information
-	r.GET("/warnings", controllers.GetWarnings)    // Get all warnings
-	r.GET("/warnings/:id", controllers.GetWarning) // Get specific warning
-
-	// Vibration Data Routes
-	r.POST("/vibrations", controllers.CreateVibration)
-	r.POST("/vibrations/batch-register", controllers.BatchRegisterVibrations)
-	r.GET("/vibrations", controllers.GetVibrations)
-	r.GET("/vibrations/:id", controllers.GetVibration)
-	r.PUT("/vibrations/:id", controllers.UpdateVibration)
-	r.DELETE("/vibrations/:id", controllers.DeleteVibration)
-
-	// Health Check Routes
-	// Basic endpoints to check server status
-	r.GET("/", func(c *gin.Context) {
-		c.JSON(200, gin.H{"Server": "Running"})
-	})
-
-	r.GET("/Bruh", func(c *gin.Context) {
-		c.JSON(200, gin.H{"message": "pong"})
-	})
+	// Initialize default ISO zone tables
+	err = h.InitializeISOZoneTables()
+	if err != nil {
+		log.Fatal("Failed to initialize ISO zone tables:", err)
+	}
 
-	// Server Configuration
-	// Set port from environment variable or default to 8080
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// Give users and sensors from before organizations an organization
+	err = h.AssignLegacyOrganizations()
+	if err != nil {
+		log.Fatal("Failed to assign organizations:", err)
+	}
+
+	// Store sensor tokens from before hashing as hashes
+	err = h.HashLegacySensorTokens()
+	if err != nil {
+		log.Fatal("Failed to hash sensor tokens:", err)
+	}
+
+	// Create the first admin user if none exists
+	err = h.BootstrapAdmin()
+	if err != nil {
+		log.Fatal("Failed to bootstrap admin user:", err)
 	}
-	r.Run("0.0.0.0:" + port)
+
+	// Raise predictive alerts for sensors trending towards Critical
+	forecast.NewJob(h, forecast.OptionsFromConfig(config.GetConfig())).Start()
+
+	// Take silent sensors offline and alert on them
+	watchdog.NewJob(h, watchdog.OptionsFromConfig(config.GetConfig())).Start()
+
+	// Start the MQTT ingestion listener alongside the HTTP server
+	if cfg := config.GetConfig(); cfg.MQTTBrokerURL != "" {
+		listener, err := mqtt.NewListener(mqtt.OptionsFromConfig(cfg), h)
+		if err != nil {
+			log.Fatal("Failed to configure MQTT listener:", err)
+		}
+		listener.Start()
+		defer listener.Stop()
+	}
+
+	// Server Configuration
+	r := router.New(h)
+	r.Run("0.0.0.0:" + config.GetConfig().Port)
 }
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UserIDKey is the gin context key holding the authenticated user's ObjectID.
+const UserIDKey = "user_id"
+
+// UserKey is the gin context key holding the authenticated models.User.
+const UserKey = "user"
+
+// AuthRequired validates the HS256 access token sent as "Authorization: Bearer <token>",
+// signed with secret, and stores the user ID from its claims, and the user it refers to,
+// in the request context.
+func AuthRequired(users store.UserStore, secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		tokenString, found := strings.CutPrefix(header, "Bearer ")
+		if !found || tokenString == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization token required"})
+			return
+		}
+
+		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+			return []byte(secret), nil
+		}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+		if err != nil || !token.Valid {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			return
+		}
+
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok || claims["type"] != "access" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token type"})
+			return
+		}
+
+		rawID, _ := claims["user_id"].(string)
+		userID, err := primitive.ObjectIDFromHex(rawID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID in token"})
+			return
+		}
+
+		// Load the user so deleted accounts are rejected and roles are always current
+		user, err := users.Get(context.Background(), store.Scope{Unrestricted: true}, userID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+
+		c.Set(UserIDKey, userID)
+		c.Set(UserKey, user)
+		c.Next()
+	}
+}
+
+// GetUserID returns the authenticated user's ID set by AuthRequired.
+func GetUserID(c *gin.Context) (primitive.ObjectID, bool) {
+	value, exists := c.Get(UserIDKey)
+	if !exists {
+		return primitive.NilObjectID, false
+	}
+	userID, ok := value.(primitive.ObjectID)
+	return userID, ok
+}
+
+// GetUser returns the authenticated user set by AuthRequired.
+func GetUser(c *gin.Context) (models.User, bool) {
+	value, exists := c.Get(UserKey)
+	if !exists {
+		return models.User{}, false
+	}
+	user, ok := value.(models.User)
+	return user, ok
+}
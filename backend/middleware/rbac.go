@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/gin-gonic/gin"
+)
+
+// Permission names an action a role may perform.
+type Permission string
+
+const (
+	PermSensorsRead     Permission = "sensors:read"
+	PermSensorsWrite    Permission = "sensors:write"
+	PermVibrationsRead  Permission = "vibrations:read"
+	PermVibrationsWrite Permission = "vibrations:write"
+	PermWarningsRead    Permission = "warnings:read"
+	PermAlertsRead      Permission = "alerts:read"
+	PermAlertsWrite     Permission = "alerts:write"
+	PermUsersRead       Permission = "users:read"
+	PermUsersWrite      Permission = "users:write"
+	PermWebhooksManage  Permission = "webhooks:manage"
+	PermOrgsManage      Permission = "organizations:manage"
+	PermStandardsManage Permission = "standards:manage"
+	PermFirmwareManage  Permission = "firmware:manage"
+)
+
+var viewerPermissions = []Permission{
+	PermSensorsRead,
+	PermVibrationsRead,
+	PermWarningsRead,
+	PermAlertsRead,
+}
+
+var operatorPermissions = append([]Permission{
+	PermSensorsWrite,
+	PermVibrationsWrite,
+	PermAlertsWrite,
+}, viewerPermissions...)
+
+var adminPermissions = append([]Permission{
+	PermUsersRead,
+	PermUsersWrite,
+	PermWebhooksManage,
+}, operatorPermissions...)
+
+var superAdminPermissions = append([]Permission{
+	PermOrgsManage,
+	PermStandardsManage,
+	PermFirmwareManage,
+}, adminPermissions...)
+
+var rolePermissions = map[string][]Permission{
+	models.RoleViewer:     viewerPermissions,
+	models.RoleOperator:   operatorPermissions,
+	models.RoleAdmin:      adminPermissions,
+	models.RoleSuperAdmin: superAdminPermissions,
+}
+
+// HasPermission reports whether role grants perm. An empty role is a viewer.
+func HasPermission(role string, perm Permission) bool {
+	if role == "" {
+		role = models.RoleViewer
+	}
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// RequirePermission rejects requests whose authenticated user lacks perm.
+// It must run after AuthRequired.
+func RequirePermission(perm Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := GetUser(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+
+		if !HasPermission(user.Role, perm) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Permission denied: " + string(perm)})
+			return
+		}
+
+		c.Next()
+	}
+}
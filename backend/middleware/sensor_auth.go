@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"github.com/gin-gonic/gin"
+)
+
+// SensorKey is the gin context key holding the authenticated models.Sensor.
+const SensorKey = "sensor"
+
+// HashSensorToken returns the hex SHA-256 hash under which a sensor token is stored.
+// Tokens are long random values, so a fast unsalted hash keeps them safe at rest while
+// still allowing lookup by hash.
+func HashSensorToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// SensorTokenMatches reports whether token is the current token of sensor, or its
+// previous token still within the grace period at at.
+func SensorTokenMatches(sensor models.Sensor, token string, at time.Time) bool {
+	if token == "" {
+		return false
+	}
+	hash := []byte(HashSensorToken(token))
+	if sensor.TokenHash != "" && subtle.ConstantTimeCompare([]byte(sensor.TokenHash), hash) == 1 {
+		return true
+	}
+	return sensor.PreviousTokenHash != "" && sensor.PreviousTokenExpiresAt != nil && at.Before(*sensor.PreviousTokenExpiresAt) &&
+		subtle.ConstantTimeCompare([]byte(sensor.PreviousTokenHash), hash) == 1
+}
+
+// SensorAuthRequired authenticates a device by its sensor token, sent as
+// "Authorization: Bearer <token>". The current token is accepted, and after a rotation
+// the previous one until its grace period ends; revoked tokens are not.
+func SensorAuthRequired(sensors store.SensorStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		token, found := strings.CutPrefix(header, "Bearer ")
+		if !found || token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Sensor token required"})
+			return
+		}
+
+		sensor, err := sensors.GetByTokenHash(context.Background(), HashSensorToken(token), time.Now())
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid sensor token"})
+			return
+		}
+
+		c.Set(SensorKey, sensor)
+		c.Next()
+	}
+}
+
+// GetSensor returns the authenticated sensor set by SensorAuthRequired.
+func GetSensor(c *gin.Context) (models.Sensor, bool) {
+	value, exists := c.Get(SensorKey)
+	if !exists {
+		return models.Sensor{}, false
+	}
+	sensor, ok := value.(models.Sensor)
+	return sensor, ok
+}
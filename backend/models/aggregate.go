@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Aggregation metrics that can be requested per channel.
+const (
+	MetricRMS = "rms"
+	MetricMax = "max"
+	MetricMin = "min"
+	MetricAvg = "avg"
+)
+
+// VibrationChannel is one axis of one unit family of a reading.
+type VibrationChannel struct {
+	Field string // Field name of the value in a stored reading, e.g. x_axisg
+	Unit  string // g, mm_s2 or mm_s
+	Axis  string // x, y or z
+	Value func(VibrationData) float32
+}
+
+// VibrationChannels lists every channel of a reading, grouped by unit family.
+var VibrationChannels = []VibrationChannel{
+	{Field: "x_axisg", Unit: "g", Axis: "x", Value: func(v VibrationData) float32 { return v.X_Axisg }},
+	{Field: "y_axisg", Unit: "g", Axis: "y", Value: func(v VibrationData) float32 { return v.Y_Axisg }},
+	{Field: "z_axisg", Unit: "g", Axis: "z", Value: func(v VibrationData) float32 { return v.Z_Axisg }},
+	{Field: "x_axismm_s2", Unit: "mm_s2", Axis: "x", Value: func(v VibrationData) float32 { return v.X_Axismm_s2 }},
+	{Field: "y_axismm_s2", Unit: "mm_s2", Axis: "y", Value: func(v VibrationData) float32 { return v.Y_Axismm_s2 }},
+	{Field: "z_axismm_s2", Unit: "mm_s2", Axis: "z", Value: func(v VibrationData) float32 { return v.Z_Axismm_s2 }},
+	{Field: "x_axismm_s", Unit: "mm_s", Axis: "x", Value: func(v VibrationData) float32 { return v.X_Axismm_s }},
+	{Field: "y_axismm_s", Unit: "mm_s", Axis: "y", Value: func(v VibrationData) float32 { return v.Y_Axismm_s }},
+	{Field: "z_axismm_s", Unit: "mm_s", Axis: "z", Value: func(v VibrationData) float32 { return v.Z_Axismm_s }},
+}
+
+// ChannelSummary holds the running totals of one channel over a time bucket.
+// Averages and RMS are derived from them, so summaries of adjacent buckets can be merged.
+type ChannelSummary struct {
+	Min   float64 `bson:"min" json:"min"`
+	Max   float64 `bson:"max" json:"max"`
+	Sum   float64 `bson:"sum" json:"sum"`
+	SumSq float64 `bson:"sum_sq" json:"sum_sq"`
+}
+
+// VibrationSummary summarizes the readings of one sensor in a time bucket.
+type VibrationSummary struct {
+	SensorID     primitive.ObjectID        `bson:"sensor_id" json:"sensor_id"`
+	Start        time.Time                 `bson:"start" json:"start"`
+	Count        int64                     `bson:"count" json:"count"`
+	MaxWarnLevel int                       `bson:"max_warn_level" json:"max_warn_level"`
+	Channels     map[string]ChannelSummary `bson:"channels" json:"channels"` // Keyed by VibrationChannel.Field
+}
+
+// ChannelStats are the requested statistics of one channel; unrequested ones are omitted.
+type ChannelStats struct {
+	RMS *float64 `json:"rms,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+	Min *float64 `json:"min,omitempty"`
+	Avg *float64 `json:"avg,omitempty"`
+}
+
+// VibrationBucket is one time bucket of the aggregation API, with statistics
+// keyed by unit family and then axis, e.g. Stats["mm_s"]["x"].
+type VibrationBucket struct {
+	Start        time.Time                          `json:"start"`
+	Count        int64                              `json:"count"`
+	MaxWarnLevel int                                `json:"max_warn_level"`
+	Stats        map[string]map[string]ChannelStats `json:"stats"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Alert statuses. An alert moves from open to acknowledged to resolved;
+// it may also be resolved straight from open.
+const (
+	AlertStatusOpen         = "open"
+	AlertStatusAcknowledged = "acknowledged"
+	AlertStatusResolved     = "resolved"
+)
+
+// Alert types.
+const (
+	AlertTypeThreshold    = "threshold"    // Classified warning level above Normal
+	AlertTypeAnomaly      = "anomaly"      // Anomaly score above the sensor baseline's limit for several readings
+	AlertTypePredictive   = "predictive"   // Velocity trend projected to reach Critical within the forecast horizon
+	AlertTypeConnectivity = "connectivity" // Sensor silent for longer than its reporting interval allows
+)
+
+// AlertEvent records a status change of an alert, who made it and why.
+type AlertEvent struct {
+	Status  string             `json:"status" bson:"status"`
+	UserID  primitive.ObjectID `json:"user_id,omitempty" bson:"user_id,omitempty"`
+	Comment string             `json:"comment,omitempty" bson:"comment,omitempty"`
+	At      time.Time          `json:"at" bson:"at"`
+}
+
+// Alert tracks one episode of a sensor being in an abnormal state. Readings that
+// arrive while an alert is open or acknowledged are folded into it.
+type Alert struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	OrganizationID primitive.ObjectID `json:"organization_id" bson:"organization_id,omitempty"`
+	SensorID       primitive.ObjectID `json:"sensor_id" bson:"sensor_id"`
+	Type           string             `json:"type" bson:"type"`
+	Status         string             `json:"status" bson:"status"`
+	Message        string             `json:"message,omitempty" bson:"message,omitempty"`
+
+	Level       int       `json:"level" bson:"level"`           // Level of the latest occurrence
+	PeakLevel   int       `json:"peak_level" bson:"peak_level"` // Highest level seen
+	Occurrences int       `json:"occurrences" bson:"occurrences"`
+	FirstSeenAt time.Time `json:"first_seen_at" bson:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at" bson:"last_seen_at"`
+
+	AcknowledgedBy primitive.ObjectID `json:"acknowledged_by,omitempty" bson:"acknowledged_by,omitempty"`
+	AcknowledgedAt *time.Time         `json:"acknowledged_at,omitempty" bson:"acknowledged_at,omitempty"`
+	ResolvedBy     primitive.ObjectID `json:"resolved_by,omitempty" bson:"resolved_by,omitempty"`
+	ResolvedAt     *time.Time         `json:"resolved_at,omitempty" bson:"resolved_at,omitempty"`
+
+	History []AlertEvent `json:"history" bson:"history"`
+}
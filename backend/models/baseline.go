@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Baseline methods.
+const (
+	BaselineMethodMeanStd = "mean_std" // Mean and standard deviation, frozen once trained
+	BaselineMethodEWMA    = "ewma"     // Exponentially weighted, keeps adapting to normal readings
+)
+
+// Baseline statuses.
+const (
+	BaselineStatusLearning = "learning" // Training on incoming readings until LearnUntil
+	BaselineStatusReady    = "ready"    // Scoring incoming readings
+)
+
+// BaselineStats is the running mean and population variance of one axis's velocity in mm/s.
+type BaselineStats struct {
+	Count    int64   `json:"count" bson:"count"`
+	Mean     float64 `json:"mean" bson:"mean"`
+	Variance float64 `json:"variance" bson:"variance"`
+}
+
+// BaselineBucket holds the per-axis statistics of all readings, or of one hour of the day.
+type BaselineBucket struct {
+	X BaselineStats `json:"x" bson:"x"`
+	Y BaselineStats `json:"y" bson:"y"`
+	Z BaselineStats `json:"z" bson:"z"`
+}
+
+// BaselineSettings tune how a baseline learns and when it alerts.
+type BaselineSettings struct {
+	Method     string  `json:"method" bson:"method"`
+	ByHour     bool    `json:"by_hour" bson:"by_hour"`         // One bucket per UTC hour of the day
+	Alpha      float64 `json:"alpha" bson:"alpha"`             // EWMA smoothing factor
+	MinSamples int64   `json:"min_samples" bson:"min_samples"` // Readings a bucket needs before it scores
+	ScoreLimit float64 `json:"score_limit" bson:"score_limit"` // Anomaly score above which a reading counts as abnormal
+	AlertAfter int     `json:"alert_after" bson:"alert_after"` // Consecutive abnormal readings that raise an alert
+}
+
+// Baseline is the learned normal behaviour of one sensor.
+type Baseline struct {
+	ID               primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	SensorID         primitive.ObjectID `json:"sensor_id" bson:"sensor_id"`
+	BaselineSettings `bson:",inline"`
+
+	Status     string           `json:"status" bson:"status"`
+	LearnUntil time.Time        `json:"learn_until" bson:"learn_until"`
+	Buckets    []BaselineBucket `json:"buckets" bson:"buckets"` // One, or 24 when ByHour
+	Streak     int              `json:"streak" bson:"streak"`   // Current run of abnormal readings
+	TrainedAt  time.Time        `json:"trained_at" bson:"trained_at"`
+	UpdatedAt  time.Time        `json:"updated_at" bson:"updated_at"`
+}
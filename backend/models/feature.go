@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FeatureSet sources.
+const (
+	FeatureSourceWaveform = "waveform" // One waveform block, in g
+	FeatureSourceWindow   = "window"   // A time window of scalar velocity readings, in mm/s
+)
+
+// FrequencyBand is the [Low, High) Hz range of a band energy.
+type FrequencyBand struct {
+	Low  float64 `json:"low" bson:"low"`
+	High float64 `json:"high" bson:"high"`
+}
+
+// AxisFeatures are the condition-monitoring features of one axis.
+// Kurtosis is not excess kurtosis: a Gaussian signal scores 3.
+type AxisFeatures struct {
+	Peak        float64 `json:"peak" bson:"peak"`
+	PeakToPeak  float64 `json:"peak_to_peak" bson:"peak_to_peak"`
+	RMS         float64 `json:"rms" bson:"rms"`
+	CrestFactor float64 `json:"crest_factor" bson:"crest_factor"`
+	Kurtosis    float64 `json:"kurtosis" bson:"kurtosis"`
+	Skewness    float64 `json:"skewness" bson:"skewness"`
+
+	// Mean-square energy (unit²) within each of FeatureSet.Bands; waveforms only
+	BandEnergies []float64 `json:"band_energies,omitempty" bson:"band_energies,omitempty"`
+}
+
+// FeatureSet holds the features of every captured axis of a waveform or reading window.
+type FeatureSet struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	SensorID   primitive.ObjectID `json:"sensor_id" bson:"sensor_id"`
+	Source     string             `json:"source" bson:"source"`
+	WaveformID primitive.ObjectID `json:"waveform_id,omitempty" bson:"waveform_id,omitempty"`
+	Timestamp  time.Time          `json:"timestamp" bson:"timestamp"`           // Waveform time, or window end
+	From       *time.Time         `json:"from,omitempty" bson:"from,omitempty"` // Window start
+	Unit       string             `json:"unit" bson:"unit"`                     // Unit of the axis features
+	Samples    int                `json:"samples" bson:"samples"`
+
+	Bands []FrequencyBand         `json:"bands,omitempty" bson:"bands,omitempty"`
+	Axes  map[string]AxisFeatures `json:"axes" bson:"axes"` // Keyed by x, y, z
+
+	// Classification inputs: vector magnitudes of the per-axis velocity RMS (mm/s)
+	// and peak acceleration (g), and the resulting warning level
+	Velocity     float64 `json:"velocity" bson:"velocity"`
+	Acceleration float64 `json:"acceleration" bson:"acceleration"`
+	WarnLevel    int     `json:"warn_level" bson:"warn_level"`
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FirmwareImage describes an uploaded firmware build. The binary is stored apart from
+// it so listings stay small.
+type FirmwareImage struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Version   string             `json:"version" bson:"version"`
+	Filename  string             `json:"filename" bson:"filename"`
+	Size      int64              `json:"size" bson:"size"`           // Bytes
+	Checksum  string             `json:"checksum" bson:"checksum"`   // SHA-256 of the binary, hex encoded
+	Signature string             `json:"signature" bson:"signature"` // Ed25519 signature of the binary, base64 encoded
+	Notes     string             `json:"notes,omitempty" bson:"notes,omitempty"`
+	CreatedBy primitive.ObjectID `json:"created_by" bson:"created_by"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// Statuses of a FirmwareCampaign.
+const (
+	CampaignStatusRunning   = "running"
+	CampaignStatusPaused    = "paused" // By an operator, or automatically on too many failures
+	CampaignStatusCompleted = "completed"
+	CampaignStatusCancelled = "cancelled"
+)
+
+// Stages of a running FirmwareCampaign.
+const (
+	CampaignStageCanary = "canary" // Only the canary sensors are offered the update
+	CampaignStageFull   = "full"   // Every targeted sensor is offered the update
+)
+
+// CampaignTarget selects the sensors a campaign updates. Empty fields match every sensor.
+type CampaignTarget struct {
+	OrganizationID primitive.ObjectID `json:"organization_id,omitempty" bson:"organization_id,omitempty"`
+	Location       string             `json:"location,omitempty" bson:"location,omitempty"`
+	Tags           []string           `json:"tags,omitempty" bson:"tags,omitempty"` // Sensors with any of these tags
+}
+
+// FirmwareCampaign rolls a firmware image out to the targeted sensors, first to a
+// canary share of them and then to everyone.
+type FirmwareCampaign struct {
+	ID              primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name            string             `json:"name" bson:"name"`
+	FirmwareID      primitive.ObjectID `json:"firmware_id" bson:"firmware_id"`
+	FirmwareVersion string             `json:"firmware_version" bson:"firmware_version"`
+	Target          CampaignTarget     `json:"target" bson:"target"`
+
+	// Percentage of the targeted sensors updated in the canary stage
+	CanaryPercent float64 `json:"canary_percent" bson:"canary_percent"`
+	// Share of finished deployments that may fail before the campaign pauses, 0 to 1
+	FailureThreshold float64 `json:"failure_threshold" bson:"failure_threshold"`
+
+	Status      string `json:"status" bson:"status"`
+	Stage       string `json:"stage" bson:"stage"`
+	PauseReason string `json:"pause_reason,omitempty" bson:"pause_reason,omitempty"`
+
+	Sensors       int `json:"sensors" bson:"sensors"`               // Targeted sensors
+	CanarySensors int `json:"canary_sensors" bson:"canary_sensors"` // Of which in the canary stage
+
+	CreatedBy primitive.ObjectID `json:"created_by" bson:"created_by"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// Statuses of a FirmwareDeployment. Devices report every status after pending.
+const (
+	DeploymentPending     = "pending"
+	DeploymentDownloading = "downloading"
+	DeploymentDownloaded  = "downloaded"
+	DeploymentInstalling  = "installing"
+	DeploymentSucceeded   = "succeeded"
+	DeploymentFailed      = "failed"
+)
+
+// FirmwareDeployment tracks one sensor's progress through a campaign.
+type FirmwareDeployment struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	CampaignID primitive.ObjectID `json:"campaign_id" bson:"campaign_id"`
+	SensorID   primitive.ObjectID `json:"sensor_id" bson:"sensor_id"`
+	Canary     bool               `json:"canary" bson:"canary"`
+	Status     string             `json:"status" bson:"status"`
+	Error      string             `json:"error,omitempty" bson:"error,omitempty"` // Reported with a failure
+	CreatedAt  time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt  time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// Finished reports whether the device has succeeded or failed.
+func (d FirmwareDeployment) Finished() bool {
+	return d.Status == DeploymentSucceeded || d.Status == DeploymentFailed
+}
+
+// CampaignProgress counts the deployments of a campaign.
+type CampaignProgress struct {
+	Total       int            `json:"total"`
+	ByStatus    map[string]int `json:"by_status"`
+	Finished    int            `json:"finished"`
+	FailureRate float64        `json:"failure_rate"` // Failed share of the finished deployments
+}
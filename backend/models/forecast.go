@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Forecast methods.
+const (
+	ForecastMethodLinear      = "linear"      // Least-squares straight line
+	ForecastMethodExponential = "exponential" // Least-squares straight line through the logarithm of velocity
+	ForecastMethodRobust      = "robust"      // Theil-Sen line, insensitive to outlying buckets
+)
+
+// ThresholdForecast projects when the trend reaches the velocity of one warning level.
+// Times are nil when the trend is not projected to reach it. Earliest and Latest use the
+// steepest and shallowest slopes within the 95% confidence bounds.
+type ThresholdForecast struct {
+	Level    int        `json:"level"`
+	Velocity float64    `json:"velocity"`        // mm/s
+	Crossed  bool       `json:"crossed"`         // The fitted velocity is already at or above it
+	Hours    *float64   `json:"hours,omitempty"` // Hours from the end of the window
+	At       *time.Time `json:"at,omitempty"`
+	Earliest *time.Time `json:"earliest,omitempty"`
+	Latest   *time.Time `json:"latest,omitempty"`
+}
+
+// Forecast is a trend fitted to a sensor's velocity RMS in aggregated buckets, with the
+// projected time until each of its warning thresholds is crossed.
+type Forecast struct {
+	SensorID primitive.ObjectID `json:"sensor_id"`
+	Method   string             `json:"method"`
+	Interval string             `json:"interval"` // Bucket length of the fitted series
+	From     time.Time          `json:"from"`
+	To       time.Time          `json:"to"`
+	Source   string             `json:"source"` // Rollup tier or raw readings the buckets came from
+	Points   int                `json:"points"`
+
+	// Fitted velocity RMS in mm/s at To
+	Current float64 `json:"current"`
+	// Slope in mm/s per hour, or for the exponential method the hourly growth rate of
+	// the logarithm of velocity, with its 95% confidence bounds
+	Slope     float64 `json:"slope"`
+	SlopeLow  float64 `json:"slope_low"`
+	SlopeHigh float64 `json:"slope_high"`
+
+	Thresholds []ThresholdForecast `json:"thresholds"`
+
+	// Horizon of predictive alerts, and whether Critical is projected to be crossed within it
+	Horizon       string `json:"horizon"`
+	WithinHorizon bool   `json:"within_horizon"`
+}
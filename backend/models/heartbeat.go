@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SensorTelemetry is the health of a device as reported in a heartbeat. Values the
+// device does not report are left out.
+type SensorTelemetry struct {
+	Battery     *float64 `json:"battery,omitempty" bson:"battery,omitempty"`         // Percent
+	RSSI        *float64 `json:"rssi,omitempty" bson:"rssi,omitempty"`               // dBm
+	Temperature *float64 `json:"temperature,omitempty" bson:"temperature,omitempty"` // °C
+	Firmware    string   `json:"firmware,omitempty" bson:"firmware,omitempty"`
+}
+
+// Heartbeat is an explicit sign of life from a sensor, carrying its telemetry.
+type Heartbeat struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	SensorID  primitive.ObjectID `json:"sensor_id" bson:"sensor_id"`
+	Timestamp time.Time          `json:"timestamp" bson:"timestamp"`
+
+	SensorTelemetry `bson:",inline"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ISO 10816 / ISO 20816 evaluation zones, from newly commissioned (A)
+// to severe enough to cause damage (D).
+const (
+	ISOZoneA = "A"
+	ISOZoneB = "B"
+	ISOZoneC = "C"
+	ISOZoneD = "D"
+)
+
+// ISOZoneTable holds the RMS velocity boundaries (mm/s) between evaluation zones
+// for one machine class. Sensors reference a table through SensorConfig.MachineClass.
+type ISOZoneTable struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Class       string             `json:"class" bson:"class" binding:"required"`
+	Standard    string             `json:"standard" bson:"standard"`
+	Description string             `json:"description" bson:"description"`
+	AB          float64            `json:"ab" bson:"ab"` // Upper bound of zone A
+	BC          float64            `json:"bc" bson:"bc"` // Upper bound of zone B
+	CD          float64            `json:"cd" bson:"cd"` // Upper bound of zone C
+}
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Organization is a tenant owning its own users and sensors.
+type Organization struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name      string             `json:"name" bson:"name" binding:"required"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
@@ -0,0 +1,20 @@
+package models
+
+// User roles, from least to most privileged.
+// Users stored without a role are treated as viewers. Every role except
+// super-admin is confined to the user's own organization.
+const (
+	RoleViewer     = "viewer"
+	RoleOperator   = "operator"
+	RoleAdmin      = "admin"
+	RoleSuperAdmin = "superadmin"
+)
+
+// IsValidRole reports whether role is one of the known user roles.
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleViewer, RoleOperator, RoleAdmin, RoleSuperAdmin:
+		return true
+	}
+	return false
+}
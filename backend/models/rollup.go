@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// RollupTier is a resolution at which vibration summaries are materialized.
+type RollupTier struct {
+	Name     string
+	Interval time.Duration
+}
+
+// Rollup tiers, finest first. Each tier is built from the one before it,
+// and the finest from raw readings.
+var RollupTiers = []RollupTier{
+	{Name: "1m", Interval: time.Minute},
+	{Name: "1h", Interval: time.Hour},
+	{Name: "1d", Interval: 24 * time.Hour},
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -9,14 +11,101 @@ type SensorConfig struct {
 	LOR      int `json:"lor" bson:"lor"`
 	GMax     int `json:"g_max" bson:"g_max"`
 	AlarmThs int `json:"alarm_ths" bson:"alarm_ths"`
+
+	// Sample rate in Hz; bounds the velocity band when FMax is not set, and
+	// applies to waveforms posted without one
+	SampleRate float64 `json:"sample_rate,omitempty" bson:"sample_rate,omitempty"`
+
+	// UnitMode selects which fields the sensor sends: UnitModeAll (the default) or UnitModeG
+	UnitMode string `json:"unit_mode,omitempty" bson:"unit_mode,omitempty"`
+	// UnitMismatch selects what happens to readings whose g and mm/s² fields disagree:
+	// UnitMismatchFlag (the default) or UnitMismatchReject
+	UnitMismatch string `json:"unit_mismatch,omitempty" bson:"unit_mismatch,omitempty"`
+
+	// ISOZoneTable class used to evaluate readings, e.g. "iso10816-1:class-ii"
+	MachineClass string `json:"machine_class,omitempty" bson:"machine_class,omitempty"`
+
+	// ClassifyBy selects what raises threshold alerts: ClassifyByReading (the default)
+	// or ClassifyByFeatures
+	ClassifyBy string `json:"classify_by,omitempty" bson:"classify_by,omitempty"`
+
+	// Frequency bands whose energy is computed from waveform spectra;
+	// four equal bands up to FMax when empty
+	Bands []FrequencyBand `json:"bands,omitempty" bson:"bands,omitempty"`
+
+	// Seconds the sensor is expected to go between reports; the configured default
+	// applies when zero
+	ReportInterval int `json:"report_interval,omitempty" bson:"report_interval,omitempty"`
 }
 
+// Unit modes of SensorConfig.UnitMode.
+const (
+	UnitModeAll = "all" // The sensor sends g, mm/s² and mm/s, which are checked against each other
+	UnitModeG   = "g"   // The sensor sends g only; mm/s² and mm/s are derived by the server
+)
+
+// Policies of SensorConfig.UnitMismatch.
+const (
+	UnitMismatchFlag   = "flag"   // Store the reading with UnitMismatch set
+	UnitMismatchReject = "reject" // Refuse the reading
+)
+
+// Alert classification inputs of SensorConfig.ClassifyBy.
+const (
+	ClassifyByReading  = "reading"  // Every scalar reading's own velocity and acceleration
+	ClassifyByFeatures = "features" // Velocity RMS and peak acceleration of each FeatureSet
+)
+
+// Provisioning statuses of a sensor, derived from its organization and credentials.
+const (
+	SensorUnclaimed   = "unclaimed"   // Pre-registered with a claim code, not yet claimed by an installer
+	SensorClaimed     = "claimed"     // In an organization, but its device holds no token yet
+	SensorProvisioned = "provisioned" // Its device has been issued a token
+)
+
+// Connectivity statuses of Sensor.Status. A sensor that has never reported has none.
+const (
+	SensorStatusOnline  = "online"
+	SensorStatusOffline = "offline"
+)
+
 type Sensor struct {
-	ID           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	UserID       primitive.ObjectID `json:"user_id" bson:"user_id"`
-	SerialNumber string             `json:"serial_number" bson:"serial_number"`
-	Location     string             `json:"location" bson:"location"`
-	Picture      string             `json:"picture" bson:"picture"`
-	Config       SensorConfig       `json:"config" bson:"config"`
-	Token        string             `json:"token,omitempty" bson:"token,omitempty"`
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	OrganizationID primitive.ObjectID `json:"organization_id" bson:"organization_id,omitempty"`
+	UserID         primitive.ObjectID `json:"user_id" bson:"user_id"`
+	SerialNumber   string             `json:"serial_number" bson:"serial_number"`
+	Location       string             `json:"location" bson:"location"`
+	Picture        string             `json:"picture" bson:"picture"`
+	Config         SensorConfig       `json:"config" bson:"config"`
+	Tags           []string           `json:"tags,omitempty" bson:"tags,omitempty"` // Free-form labels, e.g. for targeting firmware campaigns
+
+	// Credentials. Only SHA-256 hashes of device tokens are stored; after a rotation the
+	// previous token keeps working until PreviousTokenExpiresAt
+	TokenHash              string     `json:"-" bson:"token_hash,omitempty"`
+	PreviousTokenHash      string     `json:"-" bson:"previous_token_hash,omitempty"`
+	PreviousTokenExpiresAt *time.Time `json:"previous_token_expires_at,omitempty" bson:"previous_token_expires_at,omitempty"`
+	TokenIssuedAt          *time.Time `json:"token_issued_at,omitempty" bson:"token_issued_at,omitempty"`
+	TokenRevokedAt         *time.Time `json:"token_revoked_at,omitempty" bson:"token_revoked_at,omitempty"`
+	// Plaintext token stored before tokens were hashed; replaced by TokenHash at startup
+	LegacyToken string `json:"-" bson:"token,omitempty"`
+
+	// Provisioning. A pre-registered sensor has no organization until an installer claims
+	// it with its one-time claim code, which its device then redeems for its first token.
+	// Too many wrong codes in a row lock the code until ClaimLockedUntil
+	ClaimCodeHash      string             `json:"-" bson:"claim_code_hash,omitempty"`
+	ClaimCodeExpiresAt *time.Time         `json:"claim_code_expires_at,omitempty" bson:"claim_code_expires_at,omitempty"`
+	ClaimedAt          *time.Time         `json:"claimed_at,omitempty" bson:"claimed_at,omitempty"`
+	ClaimedBy          primitive.ObjectID `json:"claimed_by,omitempty" bson:"claimed_by,omitempty"`
+	ClaimFailures      int64              `json:"-" bson:"claim_failures,omitempty"`
+	ClaimLockedUntil   *time.Time         `json:"claim_locked_until,omitempty" bson:"claim_locked_until,omitempty"`
+
+	// Version of Config, the desired config, and the version the device last acknowledged
+	ConfigVersion  int             `json:"config_version" bson:"config_version"`
+	ReportedConfig *ReportedConfig `json:"reported_config,omitempty" bson:"reported_config,omitempty"`
+
+	// Connectivity, maintained by ingestion and the offline watchdog
+	Status       string           `json:"status,omitempty" bson:"status,omitempty"`
+	LastSeenAt   *time.Time       `json:"last_seen_at,omitempty" bson:"last_seen_at,omitempty"`
+	OfflineSince *time.Time       `json:"offline_since,omitempty" bson:"offline_since,omitempty"`
+	Telemetry    *SensorTelemetry `json:"telemetry,omitempty" bson:"telemetry,omitempty"` // From the latest heartbeat
 }
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Outcomes of ReportedConfig.Status, as acknowledged by the device.
+const (
+	ConfigApplied = "applied"
+	ConfigFailed  = "failed"
+)
+
+// Config sync statuses comparing a sensor's desired config version with the one it reported.
+const (
+	ConfigSyncInSync  = "in_sync" // The device applied the desired version
+	ConfigSyncPending = "pending" // The device has not acknowledged the desired version yet
+	ConfigSyncFailed  = "failed"  // The device failed to apply the desired version
+)
+
+// ReportedConfig is the config version a device last acknowledged.
+type ReportedConfig struct {
+	Version    int       `json:"version" bson:"version"`
+	Status     string    `json:"status" bson:"status"` // ConfigApplied or ConfigFailed
+	Error      string    `json:"error,omitempty" bson:"error,omitempty"`
+	ReportedAt time.Time `json:"reported_at" bson:"reported_at"`
+}
+
+// SensorConfigVersion is one entry in the history of a sensor's desired config.
+type SensorConfigVersion struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	SensorID  primitive.ObjectID `json:"sensor_id" bson:"sensor_id"`
+	Version   int                `json:"version" bson:"version"`
+	Config    SensorConfig       `json:"config" bson:"config"`
+	CreatedBy primitive.ObjectID `json:"created_by,omitempty" bson:"created_by,omitempty"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+
+	// Earlier version whose config this one restores, when created by a rollback
+	RolledBackFrom int `json:"rolled_back_from,omitempty" bson:"rolled_back_from,omitempty"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Actions recorded in the audit trail of sensor tokens.
+const (
+	TokenActionClaimed = "claimed" // Claimed into an organization by an installer with its claim code
+	TokenActionIssued  = "issued"  // First token of a sensor
+	TokenActionRotated = "rotated" // Replaced, with the previous token valid for a grace period
+	TokenActionRevoked = "revoked" // Every token of the sensor invalidated
+)
+
+// TokenAuditEvent records a change to the credentials of a sensor and who made it.
+type TokenAuditEvent struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	SensorID       primitive.ObjectID `json:"sensor_id" bson:"sensor_id"`
+	OrganizationID primitive.ObjectID `json:"organization_id" bson:"organization_id"`
+	Action         string             `json:"action" bson:"action"`
+	UserID         primitive.ObjectID `json:"user_id,omitempty" bson:"user_id,omitempty"` // Zero when the device registered itself
+	RemoteAddr     string             `json:"remote_addr" bson:"remote_addr"`
+	GraceUntil     *time.Time         `json:"grace_until,omitempty" bson:"grace_until,omitempty"` // When the previous token stops working
+	At             time.Time          `json:"at" bson:"at"`
+}
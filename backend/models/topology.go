@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PipeSegment is a stretch of pipe between two neighbouring sensors. Leak noise
+// travels along it at WaveSpeed, so the difference in its arrival time at the two
+// sensors places the leak between them.
+type PipeSegment struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	OrganizationID primitive.ObjectID `json:"organization_id" bson:"organization_id,omitempty"`
+	Name           string             `json:"name" bson:"name"`
+	SensorA        primitive.ObjectID `json:"sensor_a_id" bson:"sensor_a_id" binding:"required"`
+	SensorB        primitive.ObjectID `json:"sensor_b_id" bson:"sensor_b_id" binding:"required"`
+	Distance       float64            `json:"distance" bson:"distance"`     // Metres of pipe between the sensors
+	WaveSpeed      float64            `json:"wave_speed" bson:"wave_speed"` // Propagation speed of leak noise in m/s
+
+	// Timestamp of the newest waveform pair analyzed by the leak job
+	LastAnalyzedAt time.Time `json:"last_analyzed_at,omitempty" bson:"last_analyzed_at,omitempty"`
+	CreatedAt      time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// LeakCandidate is a possible leak found by cross-correlating a pair of time-synchronized
+// waveforms from the two sensors of a segment.
+type LeakCandidate struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	OrganizationID primitive.ObjectID `json:"organization_id" bson:"organization_id,omitempty"`
+	SegmentID      primitive.ObjectID `json:"segment_id" bson:"segment_id"`
+	SensorA        primitive.ObjectID `json:"sensor_a_id" bson:"sensor_a_id"`
+	SensorB        primitive.ObjectID `json:"sensor_b_id" bson:"sensor_b_id"`
+	WaveformA      primitive.ObjectID `json:"waveform_a_id" bson:"waveform_a_id"`
+	WaveformB      primitive.ObjectID `json:"waveform_b_id" bson:"waveform_b_id"`
+	Timestamp      time.Time          `json:"timestamp" bson:"timestamp"` // Start of the waveform from sensor A
+
+	Delay         float64 `json:"delay" bson:"delay"`                     // Seconds the noise reached B after A; negative when B was first
+	DistanceFromA float64 `json:"distance_from_a" bson:"distance_from_a"` // Estimated leak position in metres from sensor A
+	Correlation   float64 `json:"correlation" bson:"correlation"`         // Normalized correlation at the peak, -1 to 1
+	Confidence    float64 `json:"confidence" bson:"confidence"`           // 0 to 1
+
+	DetectedAt time.Time `json:"detected_at" bson:"detected_at"`
+}
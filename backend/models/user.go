@@ -7,12 +7,15 @@ import (
 )
 
 type User struct {
-	ID           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Username     string             `json:"username" bson:"username"`
-	Email        string             `json:"email" bson:"email"`
-	Organization string             `json:"organization" bson:"organization"`
-	Password     string             `json:"password" bson:"password"`
-	Token        string             `json:"token,omitempty" bson:"token,omitempty"`
-	TokenExpiry  time.Time          `json:"token_expiry,omitempty" bson:"token_expiry,omitempty"`
-	RefreshToken string             `json:"refresh_token,omitempty" bson:"refresh_token,omitempty"`
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Username       string             `json:"username" bson:"username"`
+	Email          string             `json:"email" bson:"email"`
+	OrganizationID primitive.ObjectID `json:"organization_id" bson:"organization_id,omitempty"`
+	Role           string             `json:"role" bson:"role"`
+	Password       string             `json:"password" bson:"password"`
+	Token          string             `json:"token,omitempty" bson:"token,omitempty"`
+	TokenExpiry    time.Time          `json:"token_expiry,omitempty" bson:"token_expiry,omitempty"`
+	RefreshToken   string             `json:"refresh_token,omitempty" bson:"refresh_token,omitempty"`
+	// Free-text organization stored before organizations existed; assigned at startup
+	LegacyOrganization *string `json:"-" bson:"organization,omitempty"`
 }
@@ -12,6 +12,21 @@ type VibrationData struct {
 	WarnID    primitive.ObjectID `bson:"warn_id" json:"warn_id"`
 	Timestamp time.Time          `bson:"timestamp" json:"timestamp"`
 
+	// Severity classified by the server, unless WarnOverride is set and the
+	// client-supplied WarnID is kept instead
+	WarnLevel    int  `bson:"warn_level" json:"warn_level"`
+	WarnOverride bool `bson:"warn_override,omitempty" json:"warn_override,omitempty"`
+
+	// ISO 10816 zone (A-D) of the reading under the sensor's machine class
+	ISOZone string `bson:"iso_zone,omitempty" json:"iso_zone,omitempty"`
+
+	// Set when the g and mm/s² fields disagree and the sensor flags rather than rejects such readings
+	UnitMismatch bool `bson:"unit_mismatch,omitempty" json:"unit_mismatch,omitempty"`
+
+	// Deviation from the sensor's learned baseline in standard deviations;
+	// nil while the sensor has no trained baseline
+	AnomalyScore *float64 `bson:"anomaly_score,omitempty" json:"anomaly_score,omitempty"`
+
 	// Acceleration in g units
 	X_Axisg float32 `bson:"x_axisg" json:"x_axisg"` // X-axis acceleration in g
 	Y_Axisg float32 `bson:"y_axisg" json:"y_axisg"` // Y-axis acceleration in g
@@ -4,10 +4,13 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// Level 1: Normal
-// Level 2: Warning
-// Level 3: Critical
-// Level 4: Emergency
+// Warning levels, from least to most severe.
+const (
+	WarningLevelNormal    = 1
+	WarningLevelWarning   = 2
+	WarningLevelCritical  = 3
+	WarningLevelEmergency = 4
+)
 
 type Warning struct {
 	ID    primitive.ObjectID `json:"id" bson:"_id,omitempty"`
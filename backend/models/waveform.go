@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Waveform is a block of time-domain acceleration samples in g, taken at SampleRate.
+// An axis the sensor did not capture is left empty; captured axes have equal length.
+type Waveform struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SensorID   primitive.ObjectID `bson:"sensor_id" json:"sensor_id"`
+	Timestamp  time.Time          `bson:"timestamp" json:"timestamp"`
+	SampleRate float64            `bson:"sample_rate" json:"sample_rate"` // Samples per second
+	Samples    int                `bson:"samples" json:"samples"`         // Samples per axis
+
+	X []float32 `bson:"x,omitempty" json:"x,omitempty"`
+	Y []float32 `bson:"y,omitempty" json:"y,omitempty"`
+	Z []float32 `bson:"z,omitempty" json:"z,omitempty"`
+}
+
+// Spectrum is the single-sided amplitude spectrum of a Waveform in g peak,
+// computed up to the sensor's FMax with its LOR lines. Line i is centred on
+// (i+1)·Resolution Hz, so the last line sits on FMax.
+type Spectrum struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SensorID   primitive.ObjectID `bson:"sensor_id" json:"sensor_id"`
+	WaveformID primitive.ObjectID `bson:"waveform_id" json:"waveform_id"`
+	Timestamp  time.Time          `bson:"timestamp" json:"timestamp"`
+	FMax       float64            `bson:"fmax" json:"fmax"`             // Hz
+	Lines      int                `bson:"lines" json:"lines"`           // Lines of resolution
+	Resolution float64            `bson:"resolution" json:"resolution"` // Hz per line
+
+	X []float32 `bson:"x,omitempty" json:"x,omitempty"`
+	Y []float32 `bson:"y,omitempty" json:"y,omitempty"`
+	Z []float32 `bson:"z,omitempty" json:"z,omitempty"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Webhook events sent for alerts.
+const (
+	WebhookEventAlertOpened    = "alert.opened"
+	WebhookEventAlertEscalated = "alert.escalated"
+)
+
+// Webhook delivery statuses.
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusSucceeded = "succeeded"
+	DeliveryStatusFailed    = "failed"
+)
+
+// Webhook is an organization's subscription to alert notifications.
+// Alerts below MinLevel, or for sensors outside a non-empty SensorIDs list, are skipped.
+type Webhook struct {
+	ID             primitive.ObjectID   `json:"id" bson:"_id,omitempty"`
+	OrganizationID primitive.ObjectID   `json:"organization_id" bson:"organization_id,omitempty"`
+	URL            string               `json:"url" bson:"url" binding:"required,url"`
+	Secret         string               `json:"secret,omitempty" bson:"secret"`
+	MinLevel       int                  `json:"min_level" bson:"min_level"`
+	SensorIDs      []primitive.ObjectID `json:"sensor_ids" bson:"sensor_ids"`
+	Active         bool                 `json:"active" bson:"active"`
+	CreatedAt      time.Time            `json:"created_at" bson:"created_at"`
+}
+
+// DeliveryAttempt records the outcome of one HTTP request of a delivery.
+type DeliveryAttempt struct {
+	At           time.Time `json:"at" bson:"at"`
+	ResponseCode int       `json:"response_code,omitempty" bson:"response_code,omitempty"`
+	Error        string    `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+// WebhookDelivery is one event sent to one webhook. The payload is stored
+// so a replay sends exactly the same body.
+type WebhookDelivery struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	WebhookID      primitive.ObjectID `json:"webhook_id" bson:"webhook_id"`
+	OrganizationID primitive.ObjectID `json:"organization_id" bson:"organization_id,omitempty"`
+	AlertID        primitive.ObjectID `json:"alert_id" bson:"alert_id"`
+	Event          string             `json:"event" bson:"event"`
+	Payload        string             `json:"payload" bson:"payload"`
+	Status         string             `json:"status" bson:"status"`
+	Attempts       int                `json:"attempts" bson:"attempts"`
+	ResponseCode   int                `json:"response_code,omitempty" bson:"response_code,omitempty"`
+	LastError      string             `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	AttemptLog     []DeliveryAttempt  `json:"attempt_log" bson:"attempt_log"`
+	CreatedAt      time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at" bson:"updated_at"`
+}
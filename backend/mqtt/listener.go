@@ -0,0 +1,158 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/config"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/controllers"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/middleware"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// serialPlaceholder marks the topic level that carries the sensor serial number.
+const serialPlaceholder = "{serial_number}"
+
+// Options configures a Listener.
+type Options struct {
+	BrokerURL string // e.g. tcp://localhost:1883
+	Topic     string // e.g. sensors/{serial_number}/vibration
+	ClientID  string
+	Username  string
+	Password  string
+}
+
+// OptionsFromConfig builds listener options from the application config.
+func OptionsFromConfig(cfg *config.Config) Options {
+	return Options{
+		BrokerURL: cfg.MQTTBrokerURL,
+		Topic:     cfg.MQTTTopic,
+		ClientID:  cfg.MQTTClientID,
+		Username:  cfg.MQTTUsername,
+		Password:  cfg.MQTTPassword,
+	}
+}
+
+// Listener subscribes to sensor vibration topics and stores every valid reading
+// through the same path as the device HTTP endpoints.
+type Listener struct {
+	handler     *controllers.Handler
+	client      paho.Client
+	filter      string
+	serialLevel int
+}
+
+// message is the payload a gateway publishes for one reading.
+// The device token authenticates the serial number in the topic.
+type message struct {
+	Token string `json:"token"`
+	models.VibrationData
+}
+
+// NewListener validates the topic pattern and prepares an MQTT client that stores
+// readings through handler. The broker is not contacted until Start is called.
+func NewListener(opts Options, handler *controllers.Handler) (*Listener, error) {
+	levels := strings.Split(opts.Topic, "/")
+	serialLevel := -1
+	for i, level := range levels {
+		if level == serialPlaceholder {
+			if serialLevel != -1 {
+				return nil, fmt.Errorf("topic %q contains %s more than once", opts.Topic, serialPlaceholder)
+			}
+			serialLevel = i
+			levels[i] = "+"
+		}
+	}
+	if serialLevel == -1 {
+		return nil, fmt.Errorf("topic %q must contain %s", opts.Topic, serialPlaceholder)
+	}
+
+	l := &Listener{
+		handler:     handler,
+		filter:      strings.Join(levels, "/"),
+		serialLevel: serialLevel,
+	}
+
+	clientOptions := paho.NewClientOptions().
+		AddBroker(opts.BrokerURL).
+		SetClientID(opts.ClientID).
+		SetUsername(opts.Username).
+		SetPassword(opts.Password).
+		SetCleanSession(true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(5 * time.Second).
+		SetMaxReconnectInterval(2 * time.Minute).
+		SetOnConnectHandler(l.onConnect).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			log.Println("MQTT connection lost, reconnecting:", err)
+		})
+
+	l.client = paho.NewClient(clientOptions)
+	return l, nil
+}
+
+// Start connects to the broker in the background. Failed connections are retried
+// and dropped connections are re-established with backoff by the client.
+func (l *Listener) Start() {
+	token := l.client.Connect()
+	go func() {
+		token.Wait()
+		if err := token.Error(); err != nil {
+			log.Println("MQTT connect failed:", err)
+		}
+	}()
+}
+
+// Stop disconnects from the broker, waiting up to a second for in-flight work.
+func (l *Listener) Stop() {
+	l.client.Disconnect(1000)
+}
+
+// onConnect (re)subscribes after every connect, since the session is not persisted.
+func (l *Listener) onConnect(client paho.Client) {
+	log.Println("Connected to MQTT broker, subscribing to", l.filter)
+	token := client.Subscribe(l.filter, 1, func(_ paho.Client, msg paho.Message) {
+		if err := l.Handle(msg.Topic(), msg.Payload()); err != nil {
+			log.Printf("MQTT message on %s rejected: %v", msg.Topic(), err)
+		}
+	})
+	go func() {
+		token.Wait()
+		if err := token.Error(); err != nil {
+			log.Println("MQTT subscribe failed:", err)
+		}
+	}()
+}
+
+// Handle authenticates and stores a single published reading.
+func (l *Listener) Handle(topic string, payload []byte) error {
+	levels := strings.Split(topic, "/")
+	if l.serialLevel >= len(levels) || levels[l.serialLevel] == "" {
+		return errors.New("topic has no serial number")
+	}
+	serialNumber := levels[l.serialLevel]
+
+	var msg message
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return fmt.Errorf("invalid payload: %v", err)
+	}
+
+	sensor, err := l.handler.Stores.Sensors.GetBySerial(context.Background(), serialNumber)
+	if err != nil {
+		return errors.New("unknown sensor " + serialNumber)
+	}
+
+	if !middleware.SensorTokenMatches(sensor, msg.Token, time.Now()) {
+		return errors.New("invalid sensor token for " + serialNumber)
+	}
+
+	vibration := msg.VibrationData
+	return l.handler.StoreSensorVibration(sensor, &vibration)
+}
@@ -0,0 +1,185 @@
+package mqtt
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/config"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/controllers"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/middleware"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store/memstore"
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// brokerEnv names the broker the integration test publishes to, e.g. tcp://localhost:1883.
+const brokerEnv = "MQTT_TEST_BROKER_URL"
+
+const (
+	testSerial = "SN-1"
+	testToken  = "device-token"
+)
+
+// newTestHandler returns a handler on an in-memory store holding one sensor, testSerial,
+// authenticated by testToken.
+func newTestHandler(t *testing.T) (*controllers.Handler, models.Sensor) {
+	t.Helper()
+	h := controllers.NewHandler(memstore.New(), config.Defaults(), nil)
+	if err := h.InitializeWarnings(); err != nil {
+		t.Fatal(err)
+	}
+
+	sensor := models.Sensor{
+		OrganizationID: primitive.NewObjectID(),
+		SerialNumber:   testSerial,
+		TokenHash:      middleware.HashSensorToken(testToken),
+	}
+	if err := h.Stores.Sensors.Create(context.Background(), &sensor); err != nil {
+		t.Fatal(err)
+	}
+	return h, sensor
+}
+
+// storedReadings returns the readings stored for sensorID.
+func storedReadings(t *testing.T, h *controllers.Handler, sensorID primitive.ObjectID) []models.VibrationData {
+	t.Helper()
+	query := store.VibrationQuery{SensorIDs: []primitive.ObjectID{sensorID}, Limit: 100}
+	readings, _, err := h.Stores.Vibrations.List(context.Background(), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return readings
+}
+
+func TestNewListener(t *testing.T) {
+	tests := []struct {
+		topic       string
+		filter      string
+		serialLevel int
+		err         string
+	}{
+		{topic: "sensors/{serial_number}/vibration", filter: "sensors/+/vibration", serialLevel: 1},
+		{topic: "{serial_number}", filter: "+", serialLevel: 0},
+		{topic: "site/a/{serial_number}", filter: "site/a/+", serialLevel: 2},
+		{topic: "sensors/+/vibration", err: "must contain"},
+		{topic: "sensors/{serial_number}/{serial_number}", err: "more than once"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.topic, func(t *testing.T) {
+			l, err := NewListener(Options{BrokerURL: "tcp://localhost:1883", Topic: tt.topic}, nil)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if l.filter != tt.filter || l.serialLevel != tt.serialLevel {
+				t.Errorf("filter %q at level %d, want %q at level %d", l.filter, l.serialLevel, tt.filter, tt.serialLevel)
+			}
+		})
+	}
+}
+
+func TestHandle(t *testing.T) {
+	tests := []struct {
+		name    string
+		topic   string
+		payload string
+		err     string // Empty when the reading is stored
+	}{
+		{name: "stored", topic: "sensors/SN-1/vibration", payload: `{"token":"device-token","x_axismm_s":1.5}`},
+		{name: "sensor ID from the topic", topic: "sensors/SN-1/vibration", payload: `{"token":"device-token","sensor_id":"000000000000000000000001"}`},
+		{name: "bad token", topic: "sensors/SN-1/vibration", payload: `{"token":"wrong","x_axismm_s":1.5}`, err: "invalid sensor token"},
+		{name: "missing token", topic: "sensors/SN-1/vibration", payload: `{"x_axismm_s":1.5}`, err: "invalid sensor token"},
+		{name: "unknown serial", topic: "sensors/SN-2/vibration", payload: `{"token":"device-token"}`, err: "unknown sensor"},
+		{name: "empty serial", topic: "sensors//vibration", payload: `{"token":"device-token"}`, err: "no serial number"},
+		{name: "short topic", topic: "sensors", payload: `{"token":"device-token"}`, err: "no serial number"},
+		{name: "invalid payload", topic: "sensors/SN-1/vibration", payload: `not json`, err: "invalid payload"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, sensor := newTestHandler(t)
+			l, err := NewListener(Options{BrokerURL: "tcp://localhost:1883", Topic: "sensors/{serial_number}/vibration"}, h)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = l.Handle(tt.topic, []byte(tt.payload))
+			readings := storedReadings(t, h, sensor.ID)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want one containing %q", err, tt.err)
+				}
+				if len(readings) != 0 {
+					t.Fatalf("%d readings stored for a rejected message", len(readings))
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(readings) != 1 {
+				t.Fatalf("%d readings stored, want 1", len(readings))
+			}
+			if readings[0].SensorID != sensor.ID {
+				t.Errorf("reading stored for sensor %s, want %s", readings[0].SensorID.Hex(), sensor.ID.Hex())
+			}
+		})
+	}
+}
+
+// TestBroker publishes a reading through a real broker and waits for the listener to
+// store it. It is skipped unless MQTT_TEST_BROKER_URL names a broker.
+func TestBroker(t *testing.T) {
+	brokerURL := os.Getenv(brokerEnv)
+	if brokerURL == "" {
+		t.Skip("set " + brokerEnv + " to run against an MQTT broker")
+	}
+
+	h, sensor := newTestHandler(t)
+	prefix := "test-" + primitive.NewObjectID().Hex()
+	l, err := NewListener(Options{
+		BrokerURL: brokerURL,
+		Topic:     prefix + "/{serial_number}/vibration",
+		ClientID:  prefix + "-listener",
+	}, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Start()
+	defer l.Stop()
+
+	publisher := paho.NewClient(paho.NewClientOptions().AddBroker(brokerURL).SetClientID(prefix + "-publisher"))
+	if token := publisher.Connect(); !token.WaitTimeout(10*time.Second) || token.Error() != nil {
+		t.Fatalf("connecting the publisher: %v", token.Error())
+	}
+	defer publisher.Disconnect(250)
+
+	// The listener subscribes in the background, so publish until a reading arrives
+	topic := prefix + "/" + testSerial + "/vibration"
+	deadline := time.Now().Add(15 * time.Second)
+	for time.Now().Before(deadline) {
+		publisher.Publish(topic, 1, false, `{"token":"`+testToken+`","x_axismm_s":2}`).WaitTimeout(time.Second)
+		publisher.Publish(topic, 1, false, `{"token":"wrong","x_axismm_s":2}`).WaitTimeout(time.Second)
+
+		time.Sleep(250 * time.Millisecond)
+		if readings := storedReadings(t, h, sensor.ID); len(readings) > 0 {
+			for _, reading := range readings {
+				if reading.X_Axismm_s != 2 {
+					t.Fatalf("stored reading %+v, want x_axismm_s 2", reading)
+				}
+			}
+			return
+		}
+	}
+	t.Fatal("no reading was stored from the broker")
+}
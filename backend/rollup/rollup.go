@@ -0,0 +1,271 @@
+package rollup
+
+import (
+	"context"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/analysis"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/config"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// SourceRaw names the raw readings as the source of an aggregation.
+	SourceRaw = "raw"
+
+	// chunk bounds how much data one rollup step reads at a time.
+	// It is a whole number of every tier interval, so chunks stay bucket-aligned.
+	chunk = 24 * time.Hour
+)
+
+// Options configures a Job.
+type Options struct {
+	Interval time.Duration // How often the job runs
+	Lateness time.Duration // How far behind the watermark readings may still arrive
+
+	// Retention per tier name plus SourceRaw; zero keeps data forever
+	Retention map[string]time.Duration
+}
+
+// OptionsFromConfig builds rollup options from the application config.
+func OptionsFromConfig(cfg *config.Config) Options {
+	return Options{
+		Interval: cfg.RollupInterval,
+		Lateness: cfg.RollupLateness,
+		Retention: map[string]time.Duration{
+			SourceRaw: cfg.RetentionRaw,
+			"1m":      cfg.RetentionMinute,
+			"1h":      cfg.RetentionHour,
+			"1d":      cfg.RetentionDay,
+		},
+	}
+}
+
+// covers reports whether data of the named tier is still kept at t.
+func (o Options) covers(name string, t, now time.Time) bool {
+	retention := o.Retention[name]
+	return retention == 0 || !t.Before(now.Add(-retention))
+}
+
+// SelectTier returns the coarsest tier that can answer query: its interval divides
+// the requested interval and its retention still covers query.From.
+// ok is false when no tier fits and the raw readings must be used.
+func (o Options) SelectTier(query store.AggregateQuery, now time.Time) (tier models.RollupTier, ok bool) {
+	for _, candidate := range models.RollupTiers {
+		if query.Interval%candidate.Interval != 0 {
+			continue
+		}
+		if !o.covers(candidate.Name, query.From, now) {
+			continue
+		}
+		tier, ok = candidate, true
+	}
+	return tier, ok
+}
+
+// Aggregate answers query from the coarsest usable rollup tier, falling back to the
+// raw readings when no tier fits or the tier has not been built yet. The buckets past
+// a tier's watermark, which it has not summarized yet, come from the finer tiers and
+// finally from the raw readings, so the newest buckets are always complete.
+// It returns the summaries and the name of the coarsest source they came from.
+func Aggregate(ctx context.Context, vibrations store.VibrationStore, rollups store.RollupStore, opts Options, query store.AggregateQuery) ([]models.VibrationSummary, string, error) {
+	tier, ok := opts.SelectTier(query, time.Now())
+	if !ok {
+		summaries, err := vibrations.Aggregate(ctx, query)
+		return summaries, SourceRaw, err
+	}
+
+	// Each tier answers from where the coarser one stopped up to its own watermark
+	var summaries []models.VibrationSummary
+	source := SourceRaw
+	from := analysis.BucketStart(query.From, tier.Interval)
+	for i := slices.Index(models.RollupTiers, tier); i >= 0; i-- {
+		name := models.RollupTiers[i].Name
+		watermark, err := rollups.Watermark(ctx, name)
+		if err != nil {
+			return nil, "", err
+		}
+		to := watermark
+		if query.To.Before(to) {
+			to = query.To
+		}
+		if !from.Before(to) {
+			continue
+		}
+
+		tierSummaries, err := rollups.List(ctx, name, query.SensorID, from, to)
+		if err != nil {
+			return nil, "", err
+		}
+		summaries = append(summaries, tierSummaries...)
+		if source == SourceRaw {
+			source = name
+		}
+		from = to
+	}
+
+	if source == SourceRaw {
+		// No tier has been built over the range yet
+		summaries, err := vibrations.Aggregate(ctx, query)
+		return summaries, SourceRaw, err
+	}
+
+	if from.Before(query.To) {
+		raw := store.AggregateQuery{SensorID: query.SensorID, Interval: models.RollupTiers[0].Interval, From: from, To: query.To}
+		rawSummaries, err := vibrations.Aggregate(ctx, raw)
+		if err != nil {
+			return nil, "", err
+		}
+		summaries = append(summaries, rawSummaries...)
+	}
+	return analysis.MergeSummaries(summaries, query.Interval), source, nil
+}
+
+// Job keeps the rollup tiers up to date and removes data past its retention.
+type Job struct {
+	vibrations store.VibrationStore
+	rollups    store.RollupStore
+	opts       Options
+}
+
+// NewJob returns a job that summarizes readings from vibrations into rollups.
+func NewJob(vibrations store.VibrationStore, rollups store.RollupStore, opts Options) *Job {
+	return &Job{vibrations: vibrations, rollups: rollups, opts: opts}
+}
+
+// Start runs the job once right away and then on every interval in the background.
+func (j *Job) Start() {
+	go func() {
+		ticker := time.NewTicker(j.opts.Interval)
+		defer ticker.Stop()
+
+		for {
+			if err := j.RunOnce(context.Background(), time.Now()); err != nil {
+				log.Println("Vibration rollup failed:", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// RunOnce brings every tier up to now, finest first so coarser tiers read fresh
+// summaries, then sweeps expired data.
+func (j *Job) RunOnce(ctx context.Context, now time.Time) error {
+	for i := range models.RollupTiers {
+		if err := j.build(ctx, i, now); err != nil {
+			return err
+		}
+	}
+	return j.sweep(ctx, now)
+}
+
+// build recomputes the buckets of tier i from its watermark (less the allowed
+// lateness) up to now, and moves the watermark to the current bucket. A tier that
+// was never built starts from the oldest stored reading, so that sweep cannot remove
+// readings no tier has summarized.
+func (j *Job) build(ctx context.Context, i int, now time.Time) error {
+	tier := models.RollupTiers[i]
+
+	watermark, err := j.rollups.Watermark(ctx, tier.Name)
+	if err != nil {
+		return err
+	}
+	from := watermark.Add(-j.opts.Lateness)
+	if watermark.IsZero() {
+		// Tiers are built finest first in the same run, so by the time a coarser
+		// tier starts the finer one covers everything from the oldest reading
+		oldest, err := j.vibrations.Oldest(ctx)
+		if err != nil {
+			return err
+		}
+		from = now
+		if !oldest.IsZero() && oldest.Before(now) {
+			from = oldest
+		}
+	}
+	from = analysis.BucketStart(from, tier.Interval)
+
+	for from.Before(now) {
+		to := from.Add(chunk)
+		if to.After(now) {
+			to = now
+		}
+
+		summaries, err := j.summarize(ctx, i, from, to)
+		if err != nil {
+			return err
+		}
+		if err := j.rollups.Upsert(ctx, tier.Name, summaries); err != nil {
+			return err
+		}
+		from = to
+	}
+
+	return j.rollups.SetWatermark(ctx, tier.Name, analysis.BucketStart(now, tier.Interval))
+}
+
+// summarize computes the tier i buckets of [from, to): the finest tier from the raw
+// readings, every other tier from the tier below it.
+func (j *Job) summarize(ctx context.Context, i int, from, to time.Time) ([]models.VibrationSummary, error) {
+	tier := models.RollupTiers[i]
+	if i == 0 {
+		return j.vibrations.Aggregate(ctx, store.AggregateQuery{Interval: tier.Interval, From: from, To: to})
+	}
+
+	finer, err := j.rollups.List(ctx, models.RollupTiers[i-1].Name, primitive.NilObjectID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return analysis.MergeSummaries(finer, tier.Interval), nil
+}
+
+// sweep deletes raw readings and summaries past their retention. Data is never
+// deleted before the next tier has summarized it, so a stalled job loses nothing.
+func (j *Job) sweep(ctx context.Context, now time.Time) error {
+	sources := append([]string{SourceRaw}, tierNames()...)
+	for i, name := range sources {
+		retention := j.opts.Retention[name]
+		if retention == 0 {
+			continue
+		}
+
+		cutoff := now.Add(-retention)
+		if i+1 < len(sources) {
+			watermark, err := j.rollups.Watermark(ctx, sources[i+1])
+			if err != nil {
+				return err
+			}
+			if limit := watermark.Add(-j.opts.Lateness); limit.Before(cutoff) {
+				cutoff = limit
+			}
+		}
+
+		var removed int64
+		var err error
+		if name == SourceRaw {
+			removed, err = j.vibrations.DeleteBefore(ctx, cutoff)
+		} else {
+			removed, err = j.rollups.DeleteBefore(ctx, name, cutoff)
+		}
+		if err != nil {
+			return err
+		}
+		if removed > 0 {
+			log.Printf("Removed %d expired vibration records from %s", removed, name)
+		}
+	}
+	return nil
+}
+
+// tierNames lists the rollup tier names, finest first.
+func tierNames() []string {
+	names := make([]string, len(models.RollupTiers))
+	for i, tier := range models.RollupTiers {
+		names[i] = tier.Name
+	}
+	return names
+}
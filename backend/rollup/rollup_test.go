@@ -0,0 +1,233 @@
+package rollup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store/memstore"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var testOptions = Options{
+	Interval: time.Minute,
+	Lateness: 10 * time.Minute,
+	Retention: map[string]time.Duration{
+		SourceRaw: 30 * 24 * time.Hour,
+		"1m":      365 * 24 * time.Hour,
+	},
+}
+
+// testNow is a fixed time that is not aligned to the hour, so every tier has its own
+// watermark. It follows the clock because Aggregate checks retention against it.
+func testNow() time.Time {
+	return time.Now().UTC().Truncate(24 * time.Hour).Add(12*time.Hour + 34*time.Minute + 56*time.Second)
+}
+
+// seed stores one reading of sensorID at each age before now.
+func seed(t *testing.T, stores *store.Stores, sensorID primitive.ObjectID, now time.Time, ages ...time.Duration) {
+	t.Helper()
+	for _, age := range ages {
+		reading := models.VibrationData{SensorID: sensorID, Timestamp: now.Add(-age), X_Axismm_s: 1}
+		if err := stores.Vibrations.Create(context.Background(), &reading); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// count sums the readings counted by summaries.
+func count(summaries []models.VibrationSummary) int64 {
+	var total int64
+	for _, summary := range summaries {
+		total += summary.Count
+	}
+	return total
+}
+
+// rawCount returns how many raw readings are stored.
+func rawCount(t *testing.T, stores *store.Stores) int64 {
+	t.Helper()
+	_, total, err := stores.Vibrations.List(context.Background(), store.VibrationQuery{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return total
+}
+
+func TestSelectTier(t *testing.T) {
+	now := testNow()
+	tests := []struct {
+		name     string
+		interval time.Duration
+		from     time.Time
+		want     string // Empty for the raw readings
+	}{
+		{name: "sub-minute interval", interval: 30 * time.Second, from: now.Add(-time.Hour)},
+		{name: "minutes", interval: 5 * time.Minute, from: now.Add(-time.Hour), want: "1m"},
+		{name: "hours", interval: 2 * time.Hour, from: now.Add(-time.Hour), want: "1h"},
+		{name: "days", interval: 24 * time.Hour, from: now.Add(-time.Hour), want: "1d"},
+		{name: "interval not a whole number of minutes", interval: 90 * time.Second, from: now.Add(-time.Hour)},
+		{name: "past the 1m retention", interval: 5 * time.Minute, from: now.Add(-400 * 24 * time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := store.AggregateQuery{Interval: tt.interval, From: tt.from, To: now}
+			tier, ok := testOptions.SelectTier(query, now)
+			if !ok {
+				tier.Name = ""
+			}
+			if tier.Name != tt.want {
+				t.Errorf("tier %q, want %q", tier.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunOnceAdvancesWatermarks(t *testing.T) {
+	stores := memstore.New()
+	now := testNow()
+	seed(t, stores, primitive.NewObjectID(), now, time.Hour)
+
+	if err := NewJob(stores.Vibrations, stores.Rollups, testOptions).RunOnce(context.Background(), now); err != nil {
+		t.Fatal(err)
+	}
+	for _, tier := range models.RollupTiers {
+		watermark, err := stores.Rollups.Watermark(context.Background(), tier.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := now.Truncate(tier.Interval); !watermark.Equal(want) {
+			t.Errorf("%s watermark %s, want %s", tier.Name, watermark, want)
+		}
+	}
+}
+
+func TestFirstRunSummarizesEveryReadingBeforeSweeping(t *testing.T) {
+	stores := memstore.New()
+	now := testNow()
+	sensorID := primitive.NewObjectID()
+	// Two readings are past the raw retention and two are within it
+	seed(t, stores, sensorID, now, 60*24*time.Hour, 31*24*time.Hour, 24*time.Hour, 5*time.Minute)
+
+	if err := NewJob(stores.Vibrations, stores.Rollups, testOptions).RunOnce(context.Background(), now); err != nil {
+		t.Fatal(err)
+	}
+
+	if remaining := rawCount(t, stores); remaining != 2 {
+		t.Errorf("%d raw readings kept, want the 2 within retention", remaining)
+	}
+	for _, tier := range models.RollupTiers {
+		summaries, err := stores.Rollups.List(context.Background(), tier.Name, sensorID, time.Time{}, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := count(summaries); got != 4 {
+			t.Errorf("%s tier summarizes %d readings, want 4", tier.Name, got)
+		}
+	}
+
+	query := store.AggregateQuery{SensorID: sensorID, Interval: 24 * time.Hour, From: now.Add(-90 * 24 * time.Hour), To: now}
+	summaries, source, err := Aggregate(context.Background(), stores.Vibrations, stores.Rollups, testOptions, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := count(summaries); got != 4 || source != "1d" {
+		t.Errorf("aggregate counted %d readings from %s, want 4 from 1d", got, source)
+	}
+}
+
+func TestAggregateAcrossWatermark(t *testing.T) {
+	stores := memstore.New()
+	now := testNow()
+	sensorID := primitive.NewObjectID()
+	// Summarized by the 1m and 1h tiers, by 1m only, and not yet at all
+	seed(t, stores, sensorID, now, 3*time.Hour, 5*time.Minute)
+
+	job := NewJob(stores.Vibrations, stores.Rollups, testOptions)
+	if err := job.RunOnce(context.Background(), now); err != nil {
+		t.Fatal(err)
+	}
+	seed(t, stores, sensorID, now, -30*time.Second)
+
+	tests := []struct {
+		interval time.Duration
+		source   string
+	}{
+		{interval: time.Minute, source: "1m"},
+		{interval: time.Hour, source: "1h"},
+		{interval: 24 * time.Hour, source: "1d"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			query := store.AggregateQuery{SensorID: sensorID, Interval: tt.interval, From: now.Add(-24 * time.Hour), To: now.Add(time.Hour)}
+			summaries, source, err := Aggregate(context.Background(), stores.Vibrations, stores.Rollups, testOptions, query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := count(summaries); got != 3 || source != tt.source {
+				t.Errorf("aggregate counted %d readings from %s, want 3 from %s", got, source, tt.source)
+			}
+			for _, summary := range summaries {
+				if !summary.Start.Equal(summary.Start.Truncate(tt.interval)) {
+					t.Errorf("bucket starts at %s, not on a %s boundary", summary.Start, tt.interval)
+				}
+			}
+		})
+	}
+}
+
+func TestLateReadings(t *testing.T) {
+	stores := memstore.New()
+	now := testNow()
+	sensorID := primitive.NewObjectID()
+	job := NewJob(stores.Vibrations, stores.Rollups, testOptions)
+	if err := job.RunOnce(context.Background(), now); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both arrive after the run: one within the lateness, one beyond it
+	seed(t, stores, sensorID, now, 5*time.Minute, 20*time.Minute)
+	if err := job.RunOnce(context.Background(), now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	summaries, err := stores.Rollups.List(context.Background(), "1m", sensorID, time.Time{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 1 || !summaries[0].Start.Equal(now.Add(-5*time.Minute).Truncate(time.Minute)) {
+		t.Errorf("1m tier has %+v, want only the reading within the lateness", summaries)
+	}
+}
+
+func TestSweepKeepsReadingsNotYetSummarized(t *testing.T) {
+	stores := memstore.New()
+	now := testNow()
+	opts := testOptions
+	opts.Retention = map[string]time.Duration{SourceRaw: time.Minute, "1m": time.Minute}
+	seed(t, stores, primitive.NewObjectID(), now, 5*time.Minute, time.Hour)
+
+	if err := NewJob(stores.Vibrations, stores.Rollups, opts).RunOnce(context.Background(), now); err != nil {
+		t.Fatal(err)
+	}
+
+	// Retention would remove both, but each source keeps what the next tier may
+	// still re-read: Lateness before the 1m watermark for the raw readings, and
+	// Lateness before the 1h watermark for the 1m tier
+	if remaining := rawCount(t, stores); remaining != 1 {
+		t.Errorf("%d raw readings kept, want the one within the lateness", remaining)
+	}
+	minutes, err := stores.Rollups.List(context.Background(), "1m", primitive.NilObjectID, time.Time{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hours, err := stores.Rollups.List(context.Background(), "1h", primitive.NilObjectID, time.Time{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count(minutes) != 1 || count(hours) != 2 {
+		t.Errorf("1m tier kept %d readings and 1h %d, want 1 and 2", count(minutes), count(hours))
+	}
+}
@@ -0,0 +1,187 @@
+// Package router wires the HTTP routes to their handlers.
+package router
+
+import (
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/controllers"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// New returns the Gin engine serving the API through h.
+func New(h *controllers.Handler) *gin.Engine {
+	// Initialize Gin router
+	r := gin.Default()
+
+	// Public Routes
+	// Authentication, device registration and health checks need no access token
+	r.POST("/login", h.Login)                     // User login
+	r.POST("/refresh-token", h.RefreshToken)      // Refresh access token
+	r.POST("/sensors/register", h.RegisterSensor) // Redeem a claimed sensor's claim code for its first token
+
+	// Device Ingestion Routes
+	// Authenticated with the sensor token issued by /sensors/register or a rotation
+	device := r.Group("/ingest")
+	device.Use(middleware.SensorAuthRequired(h.Stores.Sensors))
+	device.POST("", h.IngestVibration)            // Post a single reading
+	device.POST("/batch", h.IngestVibrationBatch) // Post a batch of readings
+	device.POST("/waveform", h.IngestWaveform)    // Post a waveform sample block
+	device.POST("/heartbeat", h.IngestHeartbeat)  // Post a heartbeat with device telemetry
+
+	// Device Management Routes
+	// Devices fetch their desired config and firmware updates with the sensor token and
+	// report what they applied
+	management := r.Group("/device")
+	management.Use(middleware.SensorAuthRequired(h.Stores.Sensors))
+	management.GET("/config", h.GetDeviceConfig)                       // Get the desired config and its version
+	management.POST("/config/ack", h.AckDeviceConfig)                  // Acknowledge a config version as applied or failed
+	management.GET("/firmware", h.GetDeviceFirmware)                   // Poll for a firmware update
+	management.GET("/firmware/:id/download", h.DownloadDeviceFirmware) // Download the firmware of a campaign
+	management.POST("/firmware/:id/status", h.ReportDeviceFirmware)    // Report download and install progress
+
+	// Health Check Routes
+	// Basic endpoints to check server status
+	r.GET("/", func(c *gin.Context) {
+		c.JSON(200, gin.H{"Server": "Running"})
+	})
+
+	r.GET("/Bruh", func(c *gin.Context) {
+		c.JSON(200, gin.H{"message": "pong"})
+	})
+
+	// Protected Routes
+	// Everything below requires a valid access token
+	api := r.Group("/")
+	api.Use(middleware.AuthRequired(h.Stores.Users, h.Config.JWTSecret))
+
+	// Sensor Management Routes
+	// Handles CRUD operations for vibration sensors
+	api.POST("/sensors", middleware.RequirePermission(middleware.PermSensorsWrite), h.CreateSensor)                        // Create new sensor
+	api.POST("/sensors/batch-register", middleware.RequirePermission(middleware.PermSensorsWrite), h.BatchRegisterSensors) // Batch register sensors
+	api.POST("/sensors/claim", middleware.RequirePermission(middleware.PermSensorsWrite), h.ClaimSensor)                   // Claim a pre-registered sensor
+	api.GET("/sensors", middleware.RequirePermission(middleware.PermSensorsRead), h.GetSensors)                            // Get all sensors
+	api.GET("/sensors/:id", middleware.RequirePermission(middleware.PermSensorsRead), h.GetSensor)                         // Get specific sensor
+	api.PUT("/sensors/:id", middleware.RequirePermission(middleware.PermSensorsWrite), h.UpdateSensor)                     // Update sensor
+	api.DELETE("/sensors/:id", middleware.RequirePermission(middleware.PermSensorsWrite), h.DeleteSensor)                  // Delete sensor
+
+	// User Management Routes
+	// Handles user registration and management, restricted to admins
+	api.POST("/users", middleware.RequirePermission(middleware.PermUsersWrite), h.CreateUser)                        // Register new user
+	api.POST("/users/batch-register", middleware.RequirePermission(middleware.PermUsersWrite), h.BatchRegisterUsers) // Batch register users
+	api.GET("/users", middleware.RequirePermission(middleware.PermUsersRead), h.GetUsers)                            // Get all users
+	api.GET("/users/:id", middleware.RequirePermission(middleware.PermUsersRead), h.GetUser)                         // Get specific user
+	api.PUT("/users/:id", middleware.RequirePermission(middleware.PermUsersWrite), h.UpdateUser)                     // Update user
+	api.PUT("/users/:id/role", middleware.RequirePermission(middleware.PermUsersWrite), h.UpdateUserRole)            // Change user role
+	api.DELETE("/users/:id", middleware.RequirePermission(middleware.PermUsersWrite), h.DeleteUser)                  // Delete user
+
+	// Organization Management Routes
+	// Tenants are managed by super-admins only
+	api.POST("/organizations", middleware.RequirePermission(middleware.PermOrgsManage), h.CreateOrganization)       // Create organization
+	api.GET("/organizations", middleware.RequirePermission(middleware.PermOrgsManage), h.GetOrganizations)          // Get all organizations
+	api.GET("/organizations/:id", middleware.RequirePermission(middleware.PermOrgsManage), h.GetOrganization)       // Get specific organization
+	api.PUT("/organizations/:id", middleware.RequirePermission(middleware.PermOrgsManage), h.UpdateOrganization)    // Update organization
+	api.DELETE("/organizations/:id", middleware.RequirePermission(middleware.PermOrgsManage), h.DeleteOrganization) // Delete organization
+
+	// Warning Management Routes
+	// Handles retrieval of warning information
+	api.GET("/warnings", middleware.RequirePermission(middleware.PermWarningsRead), h.GetWarnings)    // Get all warnings
+	api.GET("/warnings/:id", middleware.RequirePermission(middleware.PermWarningsRead), h.GetWarning) // Get specific warning
+
+	// Alert Routes
+	// Alerts open automatically on abnormal readings and are handled by operators
+	api.GET("/alerts", middleware.RequirePermission(middleware.PermAlertsRead), h.GetAlerts)                          // List and filter alerts
+	api.GET("/alerts/:id", middleware.RequirePermission(middleware.PermAlertsRead), h.GetAlert)                       // Get specific alert
+	api.POST("/alerts/:id/acknowledge", middleware.RequirePermission(middleware.PermAlertsWrite), h.AcknowledgeAlert) // Acknowledge open alert
+	api.POST("/alerts/:id/resolve", middleware.RequirePermission(middleware.PermAlertsWrite), h.ResolveAlert)         // Resolve alert
+
+	// Webhook Routes
+	// Per-organization alert notifications and their delivery log
+	api.POST("/webhooks", middleware.RequirePermission(middleware.PermWebhooksManage), h.CreateWebhook)
+	api.GET("/webhooks", middleware.RequirePermission(middleware.PermWebhooksManage), h.GetWebhooks)
+	api.GET("/webhooks/:id", middleware.RequirePermission(middleware.PermWebhooksManage), h.GetWebhook)
+	api.PUT("/webhooks/:id", middleware.RequirePermission(middleware.PermWebhooksManage), h.UpdateWebhook)
+	api.DELETE("/webhooks/:id", middleware.RequirePermission(middleware.PermWebhooksManage), h.DeleteWebhook)
+	api.GET("/webhooks/:id/deliveries", middleware.RequirePermission(middleware.PermWebhooksManage), h.GetWebhookDeliveries)
+	api.POST("/webhook-deliveries/:id/replay", middleware.RequirePermission(middleware.PermWebhooksManage), h.ReplayWebhookDelivery)
+
+	// ISO Zone Table Routes
+	// Machine class boundaries used to evaluate readings; shared by all organizations
+	api.GET("/iso-zone-tables", middleware.RequirePermission(middleware.PermWarningsRead), h.GetISOZoneTables)
+	api.POST("/iso-zone-tables", middleware.RequirePermission(middleware.PermStandardsManage), h.CreateISOZoneTable)
+	api.PUT("/iso-zone-tables/:id", middleware.RequirePermission(middleware.PermStandardsManage), h.UpdateISOZoneTable)
+
+	// Vibration Data Routes
+	api.POST("/vibrations", middleware.RequirePermission(middleware.PermVibrationsWrite), h.CreateVibration)
+	api.POST("/vibrations/batch-register", middleware.RequirePermission(middleware.PermVibrationsWrite), h.BatchRegisterVibrations)
+	api.GET("/vibrations", middleware.RequirePermission(middleware.PermVibrationsRead), h.GetVibrations)
+	api.GET("/vibrations/:id", middleware.RequirePermission(middleware.PermVibrationsRead), h.GetVibration)
+	api.PUT("/vibrations/:id", middleware.RequirePermission(middleware.PermVibrationsWrite), h.UpdateVibration)
+	api.DELETE("/vibrations/:id", middleware.RequirePermission(middleware.PermVibrationsWrite), h.DeleteVibration)
+	api.GET("/sensors/:id/vibrations/aggregate", middleware.RequirePermission(middleware.PermVibrationsRead), h.AggregateVibrations)
+	api.GET("/sensors/:id/forecast", middleware.RequirePermission(middleware.PermVibrationsRead), h.GetSensorForecast)
+
+	// Waveform Routes
+	// Time-domain sample blocks posted by devices and the spectra computed from them
+	api.GET("/sensors/:id/waveforms", middleware.RequirePermission(middleware.PermVibrationsRead), h.GetSensorWaveforms)
+	api.GET("/sensors/:id/spectra", middleware.RequirePermission(middleware.PermVibrationsRead), h.GetSensorSpectra)
+	api.GET("/waveforms/:id", middleware.RequirePermission(middleware.PermVibrationsRead), h.GetWaveform)
+	api.GET("/waveforms/:id/spectrum", middleware.RequirePermission(middleware.PermVibrationsRead), h.GetWaveformSpectrum)
+
+	// Connectivity Routes
+	// Online status from ingestion and heartbeats, and the telemetry history of heartbeats
+	api.GET("/sensors/:id/status", middleware.RequirePermission(middleware.PermSensorsRead), h.GetSensorStatus)
+	api.GET("/sensors/:id/telemetry", middleware.RequirePermission(middleware.PermSensorsRead), h.GetSensorTelemetry)
+
+	// Sensor Token Routes
+	// Rotation and revocation of device tokens, and the audit trail of both
+	api.POST("/sensors/:id/token/rotate", middleware.RequirePermission(middleware.PermSensorsWrite), h.RotateSensorToken)
+	api.POST("/sensors/:id/token/revoke", middleware.RequirePermission(middleware.PermSensorsWrite), h.RevokeSensorToken)
+	api.GET("/sensors/:id/token/audit", middleware.RequirePermission(middleware.PermSensorsRead), h.GetSensorTokenAudit)
+
+	// Sensor Config Routes
+	// Desired config versions pushed to devices, their acknowledgement and rollback
+	api.GET("/sensors/:id/config", middleware.RequirePermission(middleware.PermSensorsRead), h.GetSensorConfig)
+	api.GET("/sensors/:id/config/history", middleware.RequirePermission(middleware.PermSensorsRead), h.GetSensorConfigHistory)
+	api.POST("/sensors/:id/config/rollback", middleware.RequirePermission(middleware.PermSensorsWrite), h.RollbackSensorConfig)
+
+	// Baseline Routes
+	// Learned normal behaviour of each sensor, used to score readings for anomalies
+	api.GET("/sensors/:id/baseline", middleware.RequirePermission(middleware.PermSensorsRead), h.GetBaseline)
+	api.POST("/sensors/:id/baseline/retrain", middleware.RequirePermission(middleware.PermSensorsWrite), h.RetrainBaseline)
+	api.POST("/sensors/:id/baseline/reset", middleware.RequirePermission(middleware.PermSensorsWrite), h.ResetBaseline)
+
+	// Feature Routes
+	// Condition-monitoring features of waveforms and of windows of readings
+	api.GET("/sensors/:id/features", middleware.RequirePermission(middleware.PermVibrationsRead), h.GetSensorFeatures)
+	api.POST("/sensors/:id/features/window", middleware.RequirePermission(middleware.PermVibrationsWrite), h.ComputeWindowFeatures)
+	api.GET("/features/:id", middleware.RequirePermission(middleware.PermVibrationsRead), h.GetFeatureSet)
+
+	// Firmware Routes
+	// Firmware images and the campaigns rolling them out to sensors; shared by all organizations
+	api.POST("/firmware", middleware.RequirePermission(middleware.PermFirmwareManage), h.UploadFirmware)
+	api.GET("/firmware", middleware.RequirePermission(middleware.PermFirmwareManage), h.GetFirmwareImages)
+	api.GET("/firmware/:id", middleware.RequirePermission(middleware.PermFirmwareManage), h.GetFirmwareImage)
+	api.DELETE("/firmware/:id", middleware.RequirePermission(middleware.PermFirmwareManage), h.DeleteFirmwareImage)
+	api.POST("/firmware-campaigns", middleware.RequirePermission(middleware.PermFirmwareManage), h.CreateFirmwareCampaign)
+	api.GET("/firmware-campaigns", middleware.RequirePermission(middleware.PermFirmwareManage), h.GetFirmwareCampaigns)
+	api.GET("/firmware-campaigns/:id", middleware.RequirePermission(middleware.PermFirmwareManage), h.GetFirmwareCampaign)
+	api.GET("/firmware-campaigns/:id/deployments", middleware.RequirePermission(middleware.PermFirmwareManage), h.GetFirmwareCampaignDeployments)
+	api.POST("/firmware-campaigns/:id/pause", middleware.RequirePermission(middleware.PermFirmwareManage), h.PauseFirmwareCampaign)
+	api.POST("/firmware-campaigns/:id/resume", middleware.RequirePermission(middleware.PermFirmwareManage), h.ResumeFirmwareCampaign)
+	api.POST("/firmware-campaigns/:id/promote", middleware.RequirePermission(middleware.PermFirmwareManage), h.PromoteFirmwareCampaign)
+	api.POST("/firmware-campaigns/:id/cancel", middleware.RequirePermission(middleware.PermFirmwareManage), h.CancelFirmwareCampaign)
+
+	// Pipe Topology Routes
+	// Neighbouring sensors along pipe segments and the leak candidates found between them
+	api.POST("/pipe-segments", middleware.RequirePermission(middleware.PermSensorsWrite), h.CreatePipeSegment)
+	api.GET("/pipe-segments", middleware.RequirePermission(middleware.PermSensorsRead), h.GetPipeSegments)
+	api.GET("/pipe-segments/:id", middleware.RequirePermission(middleware.PermSensorsRead), h.GetPipeSegment)
+	api.PUT("/pipe-segments/:id", middleware.RequirePermission(middleware.PermSensorsWrite), h.UpdatePipeSegment)
+	api.DELETE("/pipe-segments/:id", middleware.RequirePermission(middleware.PermSensorsWrite), h.DeletePipeSegment)
+	api.POST("/pipe-segments/:id/analyze", middleware.RequirePermission(middleware.PermVibrationsWrite), h.AnalyzePipeSegment)
+	api.GET("/sensors/:id/neighbours", middleware.RequirePermission(middleware.PermSensorsRead), h.GetSensorNeighbours)
+	api.GET("/leak-candidates", middleware.RequirePermission(middleware.PermAlertsRead), h.GetLeakCandidates)
+	api.GET("/leak-candidates/:id", middleware.RequirePermission(middleware.PermAlertsRead), h.GetLeakCandidate)
+
+	return r
+}
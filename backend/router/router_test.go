@@ -0,0 +1,668 @@
+package router_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/config"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/controllers"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/router"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store/memstore"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	superAdminUsername = "root"
+	superAdminPassword = "root-password"
+)
+
+// api drives the router in memory, as the HTTP clients of the API would.
+type api struct {
+	t      *testing.T
+	h      *controllers.Handler
+	router *gin.Engine
+}
+
+func newAPI(t *testing.T) *api {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	cfg := config.Defaults()
+	cfg.JWTSecret = "router-test-secret-at-least-32-characters"
+	cfg.BootstrapAdminUsername = superAdminUsername
+	cfg.BootstrapAdminPassword = superAdminPassword
+
+	h := controllers.NewHandler(memstore.New(), cfg, nil)
+	if err := h.InitializeWarnings(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.BootstrapAdmin(); err != nil {
+		t.Fatal(err)
+	}
+	return &api{t: t, h: h, router: router.New(h)}
+}
+
+// do sends body as JSON with token as the bearer token, and decodes the response into
+// out when it is not nil.
+func (a *api) do(method, path, token string, body any, out any) int {
+	a.t.Helper()
+
+	var reader io.Reader = http.NoBody
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			a.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, req)
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			a.t.Fatalf("%s %s: decoding %q: %v", method, path, w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+// expect is do that fails the test unless the response has status want.
+func (a *api) expect(want int, method, path, token string, body any, out any) {
+	a.t.Helper()
+	if status := a.do(method, path, token, body, out); status != want {
+		a.t.Fatalf("%s %s: status %d, want %d", method, path, status, want)
+	}
+}
+
+// login returns the access and refresh token of a user.
+func (a *api) login(username, password string) (string, string) {
+	a.t.Helper()
+	var response struct {
+		User models.User `json:"user"`
+	}
+	a.expect(http.StatusOK, http.MethodPost, "/login", "", gin.H{"username": username, "password": password}, &response)
+	return response.User.Token, response.User.RefreshToken
+}
+
+// organizationAdmin creates an organization with a user of role and returns the
+// organization ID and the user's access token.
+func (a *api) organizationAdmin(root, name, role string) (string, string) {
+	a.t.Helper()
+	var organization models.Organization
+	a.expect(http.StatusCreated, http.MethodPost, "/organizations", root, gin.H{"name": name}, &organization)
+
+	user := gin.H{
+		"username":        name + "-" + role,
+		"password":        "password-" + name,
+		"role":            role,
+		"organization_id": organization.ID.Hex(),
+	}
+	a.expect(http.StatusCreated, http.MethodPost, "/users", root, user, nil)
+
+	token, _ := a.login(name+"-"+role, "password-"+name)
+	return organization.ID.Hex(), token
+}
+
+// sensorWithToken creates a sensor through token and issues its device token.
+func (a *api) sensorWithToken(token, serial string, cfg models.SensorConfig) (string, string) {
+	a.t.Helper()
+	var sensor models.Sensor
+	a.expect(http.StatusCreated, http.MethodPost, "/sensors", token, gin.H{"serial_number": serial, "config": cfg}, &sensor)
+
+	var rotated struct {
+		Token string `json:"token"`
+	}
+	a.expect(http.StatusOK, http.MethodPost, "/sensors/"+sensor.ID.Hex()+"/token/rotate", token, nil, &rotated)
+	return sensor.ID.Hex(), rotated.Token
+}
+
+func TestLoginAndRefresh(t *testing.T) {
+	a := newAPI(t)
+
+	if status := a.do(http.MethodPost, "/login", "", gin.H{"username": superAdminUsername, "password": "wrong"}, nil); status != http.StatusUnauthorized {
+		t.Fatalf("login with a wrong password: status %d, want 401", status)
+	}
+	if status := a.do(http.MethodGet, "/warnings", "", nil, nil); status != http.StatusUnauthorized {
+		t.Fatalf("request without a token: status %d, want 401", status)
+	}
+
+	access, refresh := a.login(superAdminUsername, superAdminPassword)
+	a.expect(http.StatusOK, http.MethodGet, "/warnings", access, nil, nil)
+
+	// An access token cannot be used to refresh, nor a refresh token to call the API
+	if status := a.do(http.MethodPost, "/refresh-token", "", gin.H{"refresh_token": access}, nil); status != http.StatusUnauthorized {
+		t.Fatalf("refresh with an access token: status %d, want 401", status)
+	}
+	if status := a.do(http.MethodGet, "/warnings", refresh, nil, nil); status != http.StatusUnauthorized {
+		t.Fatalf("request with a refresh token: status %d, want 401", status)
+	}
+
+	var refreshed struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	a.expect(http.StatusOK, http.MethodPost, "/refresh-token", "", gin.H{"refresh_token": refresh}, &refreshed)
+	if refreshed.Token == "" || refreshed.RefreshToken == "" {
+		t.Fatalf("refresh returned %+v, want both tokens", refreshed)
+	}
+	a.expect(http.StatusOK, http.MethodGet, "/warnings", refreshed.Token, nil, nil)
+}
+
+func TestPermissionDenied(t *testing.T) {
+	a := newAPI(t)
+	root, _ := a.login(superAdminUsername, superAdminPassword)
+	_, viewer := a.organizationAdmin(root, "viewers", models.RoleViewer)
+	_, operator := a.organizationAdmin(root, "operators", models.RoleOperator)
+
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		path   string
+		body   any
+		want   int
+	}{
+		{"viewer reads sensors", viewer, http.MethodGet, "/sensors", nil, http.StatusOK},
+		{"viewer creates sensor", viewer, http.MethodPost, "/sensors", gin.H{"serial_number": "V1"}, http.StatusForbidden},
+		{"viewer lists users", viewer, http.MethodGet, "/users", nil, http.StatusForbidden},
+		{"operator creates sensor", operator, http.MethodPost, "/sensors", gin.H{"serial_number": "O1"}, http.StatusCreated},
+		{"operator creates user", operator, http.MethodPost, "/users", gin.H{"username": "u", "password": "p"}, http.StatusForbidden},
+		{"operator creates organization", operator, http.MethodPost, "/organizations", gin.H{"name": "x"}, http.StatusForbidden},
+		{"operator lists firmware", operator, http.MethodGet, "/firmware", nil, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := a.do(tt.method, tt.path, tt.token, tt.body, nil); status != tt.want {
+				t.Errorf("status %d, want %d", status, tt.want)
+			}
+		})
+	}
+}
+
+func TestTenantScoping(t *testing.T) {
+	a := newAPI(t)
+	root, _ := a.login(superAdminUsername, superAdminPassword)
+	_, adminA := a.organizationAdmin(root, "org-a", models.RoleAdmin)
+	_, adminB := a.organizationAdmin(root, "org-b", models.RoleAdmin)
+
+	sensorA, _ := a.sensorWithToken(adminA, "A1", models.SensorConfig{})
+	a.sensorWithToken(adminB, "B1", models.SensorConfig{})
+
+	var sensors []models.Sensor
+	a.expect(http.StatusOK, http.MethodGet, "/sensors", adminB, nil, &sensors)
+	if len(sensors) != 1 || sensors[0].SerialNumber != "B1" {
+		t.Fatalf("organization B sees %+v, want only B1", sensors)
+	}
+	a.expect(http.StatusOK, http.MethodGet, "/sensors", root, nil, &sensors)
+	if len(sensors) != 2 {
+		t.Fatalf("super-admin sees %d sensors, want 2", len(sensors))
+	}
+
+	for _, path := range []string{"/sensors/" + sensorA, "/sensors/" + sensorA + "/vibrations/aggregate"} {
+		if status := a.do(http.MethodGet, path, adminB, nil, nil); status != http.StatusNotFound {
+			t.Errorf("GET %s from another organization: status %d, want 404", path, status)
+		}
+	}
+	if status := a.do(http.MethodDelete, "/sensors/"+sensorA, adminB, nil, nil); status != http.StatusNotFound {
+		t.Errorf("DELETE from another organization: status %d, want 404", status)
+	}
+	a.expect(http.StatusOK, http.MethodGet, "/sensors/"+sensorA, adminA, nil, nil)
+}
+
+func TestIngestAggregatesAndAlerts(t *testing.T) {
+	a := newAPI(t)
+	root, _ := a.login(superAdminUsername, superAdminPassword)
+	_, admin := a.organizationAdmin(root, "pipeline", models.RoleAdmin)
+	_, other := a.organizationAdmin(root, "elsewhere", models.RoleAdmin)
+
+	sensorID, deviceToken := a.sensorWithToken(admin, "S1", models.SensorConfig{AlarmThs: 5})
+
+	if status := a.do(http.MethodPost, "/ingest", "not-a-token", gin.H{"x_axismm_s": 1}, nil); status != http.StatusUnauthorized {
+		t.Fatalf("ingest with a bad token: status %d, want 401", status)
+	}
+
+	var reading models.VibrationData
+	a.expect(http.StatusCreated, http.MethodPost, "/ingest", deviceToken, gin.H{"x_axismm_s": 1}, &reading)
+	if reading.SensorID.Hex() != sensorID || reading.WarnLevel != models.WarningLevelNormal {
+		t.Fatalf("stored reading %+v, want a Normal reading of sensor %s", reading, sensorID)
+	}
+
+	// Two readings above the alarm threshold open one alert and then update it
+	batch := []gin.H{{"x_axismm_s": 6}, {"x_axismm_s": 11}}
+	a.expect(http.StatusCreated, http.MethodPost, "/ingest/batch", deviceToken, batch, nil)
+
+	var aggregate struct {
+		Buckets []models.VibrationBucket `json:"buckets"`
+	}
+	a.expect(http.StatusOK, http.MethodGet, "/sensors/"+sensorID+"/vibrations/aggregate?interval=1h", admin, nil, &aggregate)
+	var count int64
+	maxLevel := 0
+	for _, bucket := range aggregate.Buckets {
+		count += bucket.Count
+		maxLevel = max(maxLevel, bucket.MaxWarnLevel)
+	}
+	if count != 3 || maxLevel != models.WarningLevelCritical {
+		t.Fatalf("aggregate counted %d readings up to level %d, want 3 up to %d", count, maxLevel, models.WarningLevelCritical)
+	}
+
+	var alerts struct {
+		Data []models.Alert `json:"data"`
+	}
+	a.expect(http.StatusOK, http.MethodGet, "/alerts?sensor_id="+sensorID, admin, nil, &alerts)
+	if len(alerts.Data) != 1 {
+		t.Fatalf("%d alerts, want 1", len(alerts.Data))
+	}
+	alert := alerts.Data[0]
+	if alert.Status != models.AlertStatusOpen || alert.Occurrences != 2 || alert.PeakLevel != models.WarningLevelCritical {
+		t.Fatalf("alert %+v, want open with 2 occurrences peaking at Critical", alert)
+	}
+
+	a.expect(http.StatusOK, http.MethodGet, "/alerts", other, nil, &alerts)
+	if len(alerts.Data) != 0 {
+		t.Fatalf("another organization sees %d alerts, want 0", len(alerts.Data))
+	}
+	if status := a.do(http.MethodPost, "/alerts/"+alert.ID.Hex()+"/acknowledge", other, nil, nil); status != http.StatusNotFound {
+		t.Fatalf("acknowledge from another organization: status %d, want 404", status)
+	}
+
+	a.expect(http.StatusOK, http.MethodPost, "/alerts/"+alert.ID.Hex()+"/acknowledge", admin, gin.H{"comment": "on it"}, nil)
+	if status := a.do(http.MethodPost, "/alerts/"+alert.ID.Hex()+"/acknowledge", admin, nil, nil); status != http.StatusConflict {
+		t.Fatalf("acknowledging twice: status %d, want 409", status)
+	}
+	a.expect(http.StatusOK, http.MethodPost, "/alerts/"+alert.ID.Hex()+"/resolve", admin, nil, &alert)
+	if alert.Status != models.AlertStatusResolved {
+		t.Fatalf("alert status %s after resolving, want resolved", alert.Status)
+	}
+}
+
+func TestOnlyDevicesMarkSensorsSeen(t *testing.T) {
+	a := newAPI(t)
+	root, _ := a.login(superAdminUsername, superAdminPassword)
+	_, admin := a.organizationAdmin(root, "pipeline", models.RoleAdmin)
+
+	uploaded, _ := a.sensorWithToken(admin, "S1", models.SensorConfig{})
+	ingested, deviceToken := a.sensorWithToken(admin, "S2", models.SensorConfig{})
+
+	// Readings a user uploads, here a day old, say nothing about whether the device is online
+	yesterday := time.Now().Add(-24 * time.Hour)
+	a.expect(http.StatusCreated, http.MethodPost, "/vibrations", admin, gin.H{"sensor_id": uploaded, "timestamp": yesterday, "x_axismm_s": 1}, nil)
+	batch := []gin.H{{"sensor_id": uploaded, "timestamp": yesterday, "x_axismm_s": 1}}
+	a.expect(http.StatusCreated, http.MethodPost, "/vibrations/batch-register", admin, batch, nil)
+	a.expect(http.StatusCreated, http.MethodPost, "/ingest", deviceToken, gin.H{"x_axismm_s": 1}, nil)
+
+	var sensor models.Sensor
+	a.expect(http.StatusOK, http.MethodGet, "/sensors/"+uploaded, admin, nil, &sensor)
+	if sensor.LastSeenAt != nil || sensor.Status == models.SensorStatusOnline {
+		t.Errorf("sensor with uploaded readings is %q, last seen %v; want never seen", sensor.Status, sensor.LastSeenAt)
+	}
+	a.expect(http.StatusOK, http.MethodGet, "/sensors/"+ingested, admin, nil, &sensor)
+	if sensor.LastSeenAt == nil || sensor.Status != models.SensorStatusOnline {
+		t.Errorf("sensor that sent a reading is %q, last seen %v; want online", sensor.Status, sensor.LastSeenAt)
+	}
+}
+
+// errWriteFailed is returned by the failing stores below.
+var errWriteFailed = errors.New("write failed")
+
+// failingWaveforms, failingSpectra and failingFeatures are stores whose writes fail.
+type failingWaveforms struct{ store.WaveformStore }
+type failingSpectra struct{ store.SpectrumStore }
+type failingFeatures struct{ store.FeatureStore }
+
+func (failingWaveforms) Create(context.Context, *models.Waveform) error  { return errWriteFailed }
+func (failingSpectra) Create(context.Context, *models.Spectrum) error    { return errWriteFailed }
+func (failingFeatures) Create(context.Context, *models.FeatureSet) error { return errWriteFailed }
+
+func TestFailedWaveformStoresNothing(t *testing.T) {
+	samples := make([]float32, 512)
+	for i := range samples {
+		samples[i] = float32(i % 8)
+	}
+	waveform := gin.H{"sample_rate": 512, "x": samples}
+
+	tests := []struct {
+		name string
+		fail func(stores *store.Stores)
+	}{
+		{name: "spectrum", fail: func(stores *store.Stores) { stores.Spectra = failingSpectra{stores.Spectra} }},
+		{name: "features", fail: func(stores *store.Stores) { stores.Features = failingFeatures{stores.Features} }},
+		{name: "waveform", fail: func(stores *store.Stores) { stores.Waveforms = failingWaveforms{stores.Waveforms} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAPI(t)
+			root, _ := a.login(superAdminUsername, superAdminPassword)
+			_, admin := a.organizationAdmin(root, "pipeline", models.RoleAdmin)
+			id, deviceToken := a.sensorWithToken(admin, "S1", models.SensorConfig{FMax: 100, LOR: 100})
+
+			stored := func(path string) int64 {
+				t.Helper()
+				var listing struct {
+					Pagination struct {
+						Total int64 `json:"total"`
+					} `json:"pagination"`
+				}
+				a.expect(http.StatusOK, http.MethodGet, "/sensors/"+id+path, admin, nil, &listing)
+				return listing.Pagination.Total
+			}
+
+			working := *a.h.Stores
+			tt.fail(a.h.Stores)
+			a.expect(http.StatusInternalServerError, http.MethodPost, "/ingest/waveform", deviceToken, waveform, nil)
+			*a.h.Stores = working
+
+			waveforms, spectra, features := stored("/waveforms"), stored("/spectra"), stored("/features")
+			if waveforms != 0 || spectra != 0 || features != 0 {
+				t.Fatalf("%d waveforms, %d spectra and %d feature sets left, want none", waveforms, spectra, features)
+			}
+
+			var created struct {
+				WaveformID string `json:"waveform_id"`
+			}
+			a.expect(http.StatusCreated, http.MethodPost, "/ingest/waveform", deviceToken, waveform, &created)
+			waveforms, spectra, features = stored("/waveforms"), stored("/spectra"), stored("/features")
+			if waveforms != 1 || spectra != 1 || features != 1 {
+				t.Errorf("%d waveforms, %d spectra and %d feature sets after a retry, want one of each", waveforms, spectra, features)
+			}
+			a.expect(http.StatusOK, http.MethodGet, "/waveforms/"+created.WaveformID+"/spectrum", admin, nil, nil)
+		})
+	}
+}
+
+func TestUpdateSensor(t *testing.T) {
+	a := newAPI(t)
+	root, _ := a.login(superAdminUsername, superAdminPassword)
+	_, admin := a.organizationAdmin(root, "pipeline", models.RoleAdmin)
+	id, _ := a.sensorWithToken(admin, "S1", models.SensorConfig{AlarmThs: 5})
+
+	var updated struct {
+		ConfigVersion int `json:"config_version"`
+	}
+	body := gin.H{"serial_number": "S1", "location": "valve 7", "config": models.SensorConfig{AlarmThs: 8}}
+	a.expect(http.StatusOK, http.MethodPut, "/sensors/"+id, admin, body, &updated)
+	if updated.ConfigVersion != 2 {
+		t.Fatalf("config version %d after changing the config, want 2", updated.ConfigVersion)
+	}
+
+	var sensor models.Sensor
+	a.expect(http.StatusOK, http.MethodGet, "/sensors/"+id, admin, nil, &sensor)
+	if sensor.Location != "valve 7" || sensor.Config.AlarmThs != 8 || sensor.ConfigVersion != 2 {
+		t.Fatalf("sensor %+v, want location, config and version 2 updated together", sensor)
+	}
+
+	// Unchanged config keeps its version
+	body["location"] = "valve 8"
+	a.expect(http.StatusOK, http.MethodPut, "/sensors/"+id, admin, body, &updated)
+	if updated.ConfigVersion != 2 {
+		t.Fatalf("config version %d after changing only the location, want 2", updated.ConfigVersion)
+	}
+
+	var history struct {
+		Data []models.SensorConfigVersion `json:"data"`
+	}
+	a.expect(http.StatusOK, http.MethodGet, "/sensors/"+id+"/config/history", admin, nil, &history)
+	if len(history.Data) != 2 {
+		t.Fatalf("%d config versions recorded, want 2", len(history.Data))
+	}
+}
+
+func TestDuplicateSerialNumbers(t *testing.T) {
+	a := newAPI(t)
+	root, _ := a.login(superAdminUsername, superAdminPassword)
+	organization, admin := a.organizationAdmin(root, "pipeline", models.RoleAdmin)
+	_, other := a.organizationAdmin(root, "elsewhere", models.RoleAdmin)
+
+	a.sensorWithToken(admin, "S1", models.SensorConfig{})
+	second, _ := a.sensorWithToken(admin, "S2", models.SensorConfig{})
+
+	// Serial numbers are unique across organizations
+	a.expect(http.StatusConflict, http.MethodPost, "/sensors", other, gin.H{"serial_number": "S1"}, nil)
+	a.expect(http.StatusConflict, http.MethodPut, "/sensors/"+second, admin, gin.H{"serial_number": "S1"}, nil)
+	a.expect(http.StatusOK, http.MethodPut, "/sensors/"+second, admin, gin.H{"serial_number": "S2", "location": "valve 7"}, nil)
+
+	var batch struct {
+		Successful int      `json:"successful_registrations"`
+		Errors     []string `json:"errors"`
+	}
+	rows := []gin.H{
+		{"serial_number": "S3", "organization_id": organization},
+		{"serial_number": "S3", "organization_id": organization},
+		{"serial_number": "S1", "claim_code": "claim-code-1"},
+	}
+	a.expect(http.StatusCreated, http.MethodPost, "/sensors/batch-register", root, rows, &batch)
+	if batch.Successful != 1 || len(batch.Errors) != 2 {
+		t.Fatalf("batch registered %d sensors with errors %q, want 1 and 2 duplicates", batch.Successful, batch.Errors)
+	}
+	for _, message := range batch.Errors {
+		if !strings.Contains(message, "already registered") {
+			t.Errorf("batch error %q, want a duplicate serial number", message)
+		}
+	}
+}
+
+func TestLegacyOrganizations(t *testing.T) {
+	a := newAPI(t)
+	ctx := context.Background()
+	password, err := bcrypt.GenerateFromPassword([]byte("legacy-password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Users and sensors as stored before organizations existed
+	acme, blank := "Acme", ""
+	owner := models.User{Username: "acme-user", Password: string(password), Role: models.RoleAdmin, LegacyOrganization: &acme}
+	other := models.User{Username: "blank-user", Password: string(password), Role: models.RoleViewer, LegacyOrganization: &blank}
+	for _, user := range []*models.User{&owner, &other} {
+		if err := a.h.Stores.Users.Create(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	owned := models.Sensor{UserID: owner.ID, SerialNumber: "OWNED"}
+	unowned := models.Sensor{SerialNumber: "UNOWNED"}
+	unclaimed := models.Sensor{SerialNumber: "UNCLAIMED", ClaimCodeHash: "hash"}
+	for _, sensor := range []*models.Sensor{&owned, &unowned, &unclaimed} {
+		if err := a.h.Stores.Sensors.Create(ctx, sensor); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := a.h.AssignLegacyOrganizations(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		username string
+		serials  []string
+	}{
+		{username: owner.Username, serials: []string{"OWNED"}},
+		{username: other.Username, serials: []string{"UNOWNED"}},
+	}
+	for _, tt := range tests {
+		token, _ := a.login(tt.username, "legacy-password")
+		var sensors []models.Sensor
+		a.expect(http.StatusOK, http.MethodGet, "/sensors", token, nil, &sensors)
+		var serials []string
+		for _, sensor := range sensors {
+			serials = append(serials, sensor.SerialNumber)
+		}
+		if !slices.Equal(serials, tt.serials) {
+			t.Errorf("%s sees sensors %v, want %v", tt.username, serials, tt.serials)
+		}
+	}
+
+	sensor, err := a.h.Stores.Sensors.Get(ctx, store.Scope{Unrestricted: true}, unclaimed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sensor.OrganizationID.IsZero() {
+		t.Errorf("pre-registered sensor was assigned to organization %s", sensor.OrganizationID.Hex())
+	}
+
+	// Running again changes nothing
+	organizations, err := a.h.Stores.Organizations.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.h.AssignLegacyOrganizations(); err != nil {
+		t.Fatal(err)
+	}
+	again, err := a.h.Stores.Organizations.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(organizations) != 2 || len(again) != 2 {
+		t.Errorf("%d organizations, then %d after running again; want Acme and Default", len(organizations), len(again))
+	}
+}
+
+func TestUserWithoutOrganizationSeesNothing(t *testing.T) {
+	a := newAPI(t)
+	ctx := context.Background()
+	root, _ := a.login(superAdminUsername, superAdminPassword)
+	_, admin := a.organizationAdmin(root, "pipeline", models.RoleAdmin)
+	a.sensorWithToken(admin, "S1", models.SensorConfig{})
+
+	unclaimed := models.Sensor{SerialNumber: "UNCLAIMED", ClaimCodeHash: "hash"}
+	if err := a.h.Stores.Sensors.Create(ctx, &unclaimed); err != nil {
+		t.Fatal(err)
+	}
+	password, err := bcrypt.GenerateFromPassword([]byte("orphan-password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orphan := models.User{Username: "orphan", Password: string(password), Role: models.RoleViewer}
+	if err := a.h.Stores.Users.Create(ctx, &orphan); err != nil {
+		t.Fatal(err)
+	}
+
+	token, _ := a.login("orphan", "orphan-password")
+	var sensors []models.Sensor
+	a.expect(http.StatusOK, http.MethodGet, "/sensors", token, nil, &sensors)
+	if len(sensors) != 0 {
+		t.Errorf("user without an organization sees %d sensors, want 0", len(sensors))
+	}
+	a.expect(http.StatusNotFound, http.MethodGet, "/sensors/"+unclaimed.ID.Hex(), token, nil, nil)
+}
+
+// uploadFirmware posts a firmware image signed with key as multipart form data and
+// returns the response status and the stored image.
+func (a *api) uploadFirmware(token, version string, data []byte, key ed25519.PrivateKey) (int, models.FirmwareImage) {
+	a.t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("version", version)
+	form.WriteField("signature", base64.StdEncoding.EncodeToString(ed25519.Sign(key, data)))
+	file, err := form.CreateFormFile("file", "firmware.bin")
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	file.Write(data)
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/firmware", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, req)
+
+	var image models.FirmwareImage
+	if w.Code == http.StatusCreated {
+		if err := json.Unmarshal(w.Body.Bytes(), &image); err != nil {
+			a.t.Fatal(err)
+		}
+	}
+	return w.Code, image
+}
+
+func TestFirmwareUploadLimitAndSigningKey(t *testing.T) {
+	a := newAPI(t)
+	root, _ := a.login(superAdminUsername, superAdminPassword)
+	_, admin := a.organizationAdmin(root, "pipeline", models.RoleAdmin)
+	a.sensorWithToken(admin, "S1", models.SensorConfig{})
+
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.h.Config.FirmwareMaxSize = 1024
+
+	// Cut off by the body limit, far beyond the image limit
+	if status, _ := a.uploadFirmware(root, "1.0.0", make([]byte, 1<<20), private); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized upload answered %d, want %d", status, http.StatusRequestEntityTooLarge)
+	}
+
+	// Without a signing key images are stored, but no rollout may start
+	status, unverified := a.uploadFirmware(root, "1.0.0", make([]byte, 512), private)
+	if status != http.StatusCreated {
+		t.Fatalf("upload answered %d, want %d", status, http.StatusCreated)
+	}
+	campaign := gin.H{"name": "rollout", "firmware_id": unverified.ID}
+	a.expect(http.StatusConflict, http.MethodPost, "/firmware-campaigns", root, campaign, nil)
+
+	a.h.Config.FirmwarePublicKey = hex.EncodeToString(public)
+	status, verified := a.uploadFirmware(root, "1.0.1", make([]byte, 512), private)
+	if status != http.StatusCreated {
+		t.Fatalf("signed upload answered %d, want %d", status, http.StatusCreated)
+	}
+	campaign["firmware_id"] = verified.ID
+	a.expect(http.StatusCreated, http.MethodPost, "/firmware-campaigns", root, campaign, nil)
+}
+
+func TestWrongClaimCodesLockTheSensor(t *testing.T) {
+	a := newAPI(t)
+	ctx := context.Background()
+	root, _ := a.login(superAdminUsername, superAdminPassword)
+	_, admin := a.organizationAdmin(root, "pipeline", models.RoleAdmin)
+	a.h.Config.SensorClaimMaxFailures = 3
+
+	var batch struct {
+		Successful int `json:"successful_registrations"`
+	}
+	rows := []gin.H{{"serial_number": "S1", "claim_code": "claim-code-1"}}
+	a.expect(http.StatusCreated, http.MethodPost, "/sensors/batch-register", root, rows, &batch)
+	if batch.Successful != 1 {
+		t.Fatalf("batch registered %d sensors, want 1", batch.Successful)
+	}
+
+	wrong := gin.H{"serial_number": "S1", "claim_code": "wrong-code"}
+	right := gin.H{"serial_number": "S1", "claim_code": "claim-code-1"}
+	for range 3 {
+		a.expect(http.StatusNotFound, http.MethodPost, "/sensors/claim", admin, wrong, nil)
+	}
+
+	// Locked, the right code is refused as well, from the device too
+	a.expect(http.StatusTooManyRequests, http.MethodPost, "/sensors/claim", admin, right, nil)
+	a.expect(http.StatusTooManyRequests, http.MethodPost, "/sensors/register", "", right, nil)
+
+	// Once the lock expires the right code works again
+	sensor, err := a.h.Stores.Sensors.GetBySerial(ctx, "S1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.h.Stores.Sensors.FailClaimAttempt(ctx, sensor.ID, 1, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	a.expect(http.StatusOK, http.MethodPost, "/sensors/claim", admin, right, nil)
+	a.expect(http.StatusOK, http.MethodPost, "/sensors/register", "", right, nil)
+}
@@ -0,0 +1,140 @@
+package memstore
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AlertStore struct {
+	records *records[models.Alert]
+	raiseMu sync.Mutex // Serializes Raise so concurrent occurrences share one alert
+}
+
+func isActiveAlert(alert models.Alert) bool {
+	return alert.Status == models.AlertStatusOpen || alert.Status == models.AlertStatusAcknowledged
+}
+
+func (s *AlertStore) Raise(ctx context.Context, occurrence store.AlertOccurrence) (store.AlertChange, error) {
+	s.raiseMu.Lock()
+	defer s.raiseMu.Unlock()
+
+	active, err := s.records.first(func(alert models.Alert) bool {
+		return alert.SensorID == occurrence.SensorID && alert.Type == occurrence.Type && isActiveAlert(alert)
+	})
+	if err == store.ErrNotFound {
+		alert := models.Alert{
+			ID:             primitive.NewObjectID(),
+			OrganizationID: occurrence.OrganizationID,
+			SensorID:       occurrence.SensorID,
+			Type:           occurrence.Type,
+			Status:         models.AlertStatusOpen,
+			Message:        occurrence.Message,
+			Level:          occurrence.Level,
+			PeakLevel:      occurrence.Level,
+			Occurrences:    1,
+			FirstSeenAt:    occurrence.At,
+			LastSeenAt:     occurrence.At,
+			History: []models.AlertEvent{
+				{Status: models.AlertStatusOpen, At: occurrence.At, Comment: occurrence.Message},
+			},
+		}
+		s.records.put(alert.ID, alert)
+		return store.AlertChange{Alert: alert, Opened: true}, nil
+	}
+
+	change := store.AlertChange{Escalated: occurrence.Level > active.PeakLevel}
+	err = s.records.modify(active.ID, nil, func(alert *models.Alert) {
+		alert.Level = occurrence.Level
+		alert.Message = occurrence.Message
+		alert.Occurrences++
+		if occurrence.Level > alert.PeakLevel {
+			alert.PeakLevel = occurrence.Level
+		}
+		if occurrence.At.After(alert.LastSeenAt) {
+			alert.LastSeenAt = occurrence.At
+		}
+		if occurrence.At.Before(alert.FirstSeenAt) {
+			alert.FirstSeenAt = occurrence.At
+		}
+		change.Alert = *alert
+	})
+	return change, err
+}
+
+func (s *AlertStore) Get(ctx context.Context, scope store.Scope, id primitive.ObjectID) (models.Alert, error) {
+	alert, ok := s.records.get(id)
+	if !ok || !scope.Matches(alert.OrganizationID) {
+		return models.Alert{}, store.ErrNotFound
+	}
+	return alert, nil
+}
+
+func (s *AlertStore) List(ctx context.Context, query store.AlertQuery) ([]models.Alert, int64, error) {
+	matches := s.records.filter(func(alert models.Alert) bool {
+		switch {
+		case !query.Scope.Matches(alert.OrganizationID):
+			return false
+		case query.Status != "" && alert.Status != query.Status:
+			return false
+		case query.Type != "" && alert.Type != query.Type:
+			return false
+		case !query.SensorID.IsZero() && alert.SensorID != query.SensorID:
+			return false
+		case query.MinLevel != 0 && alert.PeakLevel < query.MinLevel:
+			return false
+		case !query.From.IsZero() && alert.LastSeenAt.Before(query.From):
+			return false
+		case !query.To.IsZero() && alert.FirstSeenAt.After(query.To):
+			return false
+		}
+		return true
+	})
+
+	results, total := page(matches, func(a, b models.Alert) bool {
+		return a.LastSeenAt.After(b.LastSeenAt)
+	}, query.Skip, query.Limit)
+	return results, total, nil
+}
+
+func (s *AlertStore) Transition(ctx context.Context, scope store.Scope, id primitive.ObjectID, from []string, event models.AlertEvent) (models.Alert, error) {
+	existing, err := s.Get(ctx, scope, id)
+	if err != nil {
+		return models.Alert{}, err
+	}
+
+	allowed := func(alert models.Alert) bool {
+		for _, status := range from {
+			if alert.Status == status {
+				return true
+			}
+		}
+		return false
+	}
+	if !allowed(existing) {
+		return models.Alert{}, store.ErrConflict
+	}
+
+	var updated models.Alert
+	err = s.records.modify(id, allowed, func(alert *models.Alert) {
+		alert.Status = event.Status
+		switch event.Status {
+		case models.AlertStatusAcknowledged:
+			alert.AcknowledgedBy = event.UserID
+			alert.AcknowledgedAt = &event.At
+		case models.AlertStatusResolved:
+			alert.ResolvedBy = event.UserID
+			alert.ResolvedAt = &event.At
+		}
+		alert.History = append(append([]models.AlertEvent{}, alert.History...), event)
+		updated = *alert
+	})
+	if err == store.ErrNotFound {
+		// The status changed between the check and the update
+		return models.Alert{}, store.ErrConflict
+	}
+	return updated, err
+}
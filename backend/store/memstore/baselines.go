@@ -0,0 +1,25 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type BaselineStore struct {
+	records *records[models.Baseline]
+}
+
+func (s *BaselineStore) GetBySensor(ctx context.Context, sensorID primitive.ObjectID) (models.Baseline, error) {
+	return s.records.first(func(b models.Baseline) bool { return b.SensorID == sensorID })
+}
+
+func (s *BaselineStore) Save(ctx context.Context, baseline *models.Baseline) error {
+	if existing, err := s.GetBySensor(ctx, baseline.SensorID); err == nil {
+		baseline.ID = existing.ID
+	}
+	baseline.ID = newID(baseline.ID)
+	s.records.put(baseline.ID, *baseline)
+	return nil
+}
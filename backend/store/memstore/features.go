@@ -0,0 +1,52 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type FeatureStore struct {
+	records *records[models.FeatureSet]
+}
+
+func (s *FeatureStore) Create(ctx context.Context, features *models.FeatureSet) error {
+	features.ID = newID(features.ID)
+	s.records.put(features.ID, *features)
+	return nil
+}
+
+func (s *FeatureStore) Get(ctx context.Context, id primitive.ObjectID) (models.FeatureSet, error) {
+	features, ok := s.records.get(id)
+	if !ok {
+		return models.FeatureSet{}, store.ErrNotFound
+	}
+	return features, nil
+}
+
+func (s *FeatureStore) List(ctx context.Context, query store.FeatureQuery) ([]models.FeatureSet, int64, error) {
+	matches := s.records.filter(func(f models.FeatureSet) bool {
+		switch {
+		case !query.SensorID.IsZero() && f.SensorID != query.SensorID:
+			return false
+		case query.Source != "" && f.Source != query.Source:
+			return false
+		case !query.From.IsZero() && f.Timestamp.Before(query.From):
+			return false
+		case !query.To.IsZero() && f.Timestamp.After(query.To):
+			return false
+		}
+		return true
+	})
+
+	results, total := page(matches, func(a, b models.FeatureSet) bool {
+		return a.Timestamp.After(b.Timestamp)
+	}, query.Skip, query.Limit)
+	return results, total, nil
+}
+
+func (s *FeatureStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return s.records.remove(id, nil)
+}
@@ -0,0 +1,158 @@
+package memstore
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type FirmwareStore struct {
+	records *records[models.FirmwareImage]
+	data    *records[[]byte]
+}
+
+func (s *FirmwareStore) Create(ctx context.Context, image *models.FirmwareImage, data []byte) error {
+	image.ID = newID(image.ID)
+	s.data.put(image.ID, data)
+	s.records.put(image.ID, *image)
+	return nil
+}
+
+func (s *FirmwareStore) Get(ctx context.Context, id primitive.ObjectID) (models.FirmwareImage, error) {
+	image, ok := s.records.get(id)
+	if !ok {
+		return models.FirmwareImage{}, store.ErrNotFound
+	}
+	return image, nil
+}
+
+func (s *FirmwareStore) GetByVersion(ctx context.Context, version string) (models.FirmwareImage, error) {
+	return s.records.first(func(image models.FirmwareImage) bool { return image.Version == version })
+}
+
+func (s *FirmwareStore) List(ctx context.Context) ([]models.FirmwareImage, error) {
+	images := s.records.filter(nil)
+	results, _ := page(images, func(a, b models.FirmwareImage) bool {
+		return a.CreatedAt.After(b.CreatedAt)
+	}, 0, 0)
+	return results, nil
+}
+
+func (s *FirmwareStore) Data(ctx context.Context, id primitive.ObjectID) ([]byte, error) {
+	data, ok := s.data.get(id)
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return data, nil
+}
+
+func (s *FirmwareStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	if err := s.records.remove(id, nil); err != nil {
+		return err
+	}
+	return s.data.remove(id, nil)
+}
+
+type FirmwareCampaignStore struct {
+	records *records[models.FirmwareCampaign]
+}
+
+func (s *FirmwareCampaignStore) Create(ctx context.Context, campaign *models.FirmwareCampaign) error {
+	campaign.ID = newID(campaign.ID)
+	s.records.put(campaign.ID, *campaign)
+	return nil
+}
+
+func (s *FirmwareCampaignStore) Get(ctx context.Context, id primitive.ObjectID) (models.FirmwareCampaign, error) {
+	campaign, ok := s.records.get(id)
+	if !ok {
+		return models.FirmwareCampaign{}, store.ErrNotFound
+	}
+	return campaign, nil
+}
+
+func (s *FirmwareCampaignStore) List(ctx context.Context, status string) ([]models.FirmwareCampaign, error) {
+	campaigns := s.records.filter(func(campaign models.FirmwareCampaign) bool {
+		return status == "" || campaign.Status == status
+	})
+	results, _ := page(campaigns, func(a, b models.FirmwareCampaign) bool {
+		return a.CreatedAt.After(b.CreatedAt)
+	}, 0, 0)
+	return results, nil
+}
+
+func (s *FirmwareCampaignStore) Transition(ctx context.Context, id primitive.ObjectID, from []string, status, stage, reason string, at time.Time) (models.FirmwareCampaign, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return models.FirmwareCampaign{}, err
+	}
+
+	allowed := func(campaign models.FirmwareCampaign) bool { return slices.Contains(from, campaign.Status) }
+	var updated models.FirmwareCampaign
+	err := s.records.modify(id, allowed, func(campaign *models.FirmwareCampaign) {
+		campaign.Status = status
+		if stage != "" {
+			campaign.Stage = stage
+		}
+		campaign.PauseReason = reason
+		campaign.UpdatedAt = at
+		updated = *campaign
+	})
+	if err == store.ErrNotFound {
+		return models.FirmwareCampaign{}, store.ErrConflict
+	}
+	return updated, err
+}
+
+type FirmwareDeploymentStore struct {
+	records *records[models.FirmwareDeployment]
+}
+
+func (s *FirmwareDeploymentStore) CreateMany(ctx context.Context, deployments []models.FirmwareDeployment) error {
+	for i := range deployments {
+		deployments[i].ID = newID(deployments[i].ID)
+		s.records.put(deployments[i].ID, deployments[i])
+	}
+	return nil
+}
+
+func (s *FirmwareDeploymentStore) ListByCampaign(ctx context.Context, campaignID primitive.ObjectID) ([]models.FirmwareDeployment, error) {
+	return s.records.filter(func(deployment models.FirmwareDeployment) bool {
+		return deployment.CampaignID == campaignID
+	}), nil
+}
+
+func (s *FirmwareDeploymentStore) ListBySensor(ctx context.Context, sensorID primitive.ObjectID) ([]models.FirmwareDeployment, error) {
+	deployments := s.records.filter(func(deployment models.FirmwareDeployment) bool {
+		return deployment.SensorID == sensorID
+	})
+	results, _ := page(deployments, func(a, b models.FirmwareDeployment) bool {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}, 0, 0)
+	return results, nil
+}
+
+func (s *FirmwareDeploymentStore) SetStatus(ctx context.Context, campaignID, sensorID primitive.ObjectID, status, message string, at time.Time) (models.FirmwareDeployment, error) {
+	existing, err := s.records.first(func(deployment models.FirmwareDeployment) bool {
+		return deployment.CampaignID == campaignID && deployment.SensorID == sensorID
+	})
+	if err != nil {
+		return models.FirmwareDeployment{}, err
+	}
+
+	unfinished := func(deployment models.FirmwareDeployment) bool { return !deployment.Finished() }
+	var updated models.FirmwareDeployment
+	err = s.records.modify(existing.ID, unfinished, func(deployment *models.FirmwareDeployment) {
+		deployment.Status = status
+		deployment.Error = message
+		deployment.UpdatedAt = at
+		updated = *deployment
+	})
+	if err == store.ErrNotFound {
+		return models.FirmwareDeployment{}, store.ErrConflict
+	}
+	return updated, err
+}
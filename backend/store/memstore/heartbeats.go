@@ -0,0 +1,37 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+)
+
+type HeartbeatStore struct {
+	records *records[models.Heartbeat]
+}
+
+func (s *HeartbeatStore) Create(ctx context.Context, heartbeat *models.Heartbeat) error {
+	heartbeat.ID = newID(heartbeat.ID)
+	s.records.put(heartbeat.ID, *heartbeat)
+	return nil
+}
+
+func (s *HeartbeatStore) List(ctx context.Context, query store.HeartbeatQuery) ([]models.Heartbeat, int64, error) {
+	matches := s.records.filter(func(heartbeat models.Heartbeat) bool {
+		switch {
+		case heartbeat.SensorID != query.SensorID:
+			return false
+		case !query.From.IsZero() && heartbeat.Timestamp.Before(query.From):
+			return false
+		case !query.To.IsZero() && heartbeat.Timestamp.After(query.To):
+			return false
+		}
+		return true
+	})
+
+	results, total := page(matches, func(a, b models.Heartbeat) bool {
+		return a.Timestamp.After(b.Timestamp)
+	}, query.Skip, query.Limit)
+	return results, total, nil
+}
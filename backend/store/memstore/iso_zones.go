@@ -0,0 +1,35 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+)
+
+type ISOZoneStore struct {
+	records *records[models.ISOZoneTable]
+}
+
+func (s *ISOZoneStore) Create(ctx context.Context, table *models.ISOZoneTable) error {
+	table.ID = newID(table.ID)
+	s.records.put(table.ID, *table)
+	return nil
+}
+
+func (s *ISOZoneStore) GetByClass(ctx context.Context, class string) (models.ISOZoneTable, error) {
+	return s.records.first(func(table models.ISOZoneTable) bool { return table.Class == class })
+}
+
+func (s *ISOZoneStore) List(ctx context.Context) ([]models.ISOZoneTable, error) {
+	return s.records.filter(nil), nil
+}
+
+func (s *ISOZoneStore) Update(ctx context.Context, table models.ISOZoneTable) error {
+	return s.records.modify(table.ID, nil, func(existing *models.ISOZoneTable) {
+		existing.Standard = table.Standard
+		existing.Description = table.Description
+		existing.AB = table.AB
+		existing.BC = table.BC
+		existing.CD = table.CD
+	})
+}
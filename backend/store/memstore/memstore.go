@@ -0,0 +1,150 @@
+// Package memstore implements the store interfaces in process memory.
+// It needs no outside services, which makes it suitable for tests and local runs.
+package memstore
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// New returns empty in-memory stores.
+func New() *store.Stores {
+	return &store.Stores{
+		Sensors:       &SensorStore{records: newRecords[models.Sensor]()},
+		Users:         &UserStore{records: newRecords[models.User]()},
+		Vibrations:    &VibrationStore{records: newRecords[models.VibrationData]()},
+		Rollups:       newRollupStore(),
+		Waveforms:     &WaveformStore{records: newRecords[models.Waveform]()},
+		Spectra:       &SpectrumStore{records: newRecords[models.Spectrum]()},
+		Features:      &FeatureStore{records: newRecords[models.FeatureSet]()},
+		Baselines:     &BaselineStore{records: newRecords[models.Baseline]()},
+		Heartbeats:    &HeartbeatStore{records: newRecords[models.Heartbeat]()},
+		SensorConfigs: &SensorConfigStore{records: newRecords[models.SensorConfigVersion]()},
+		TokenAudit:    &TokenAuditStore{records: newRecords[models.TokenAuditEvent]()},
+		Firmware:      &FirmwareStore{records: newRecords[models.FirmwareImage](), data: newRecords[[]byte]()},
+		Campaigns:     &FirmwareCampaignStore{records: newRecords[models.FirmwareCampaign]()},
+		Deployments:   &FirmwareDeploymentStore{records: newRecords[models.FirmwareDeployment]()},
+		PipeSegments:  &PipeSegmentStore{records: newRecords[models.PipeSegment]()},
+		Leaks:         &LeakCandidateStore{records: newRecords[models.LeakCandidate]()},
+		Warnings:      &WarningStore{records: newRecords[models.Warning]()},
+		Organizations: &OrganizationStore{records: newRecords[models.Organization]()},
+		ISOZones:      &ISOZoneStore{records: newRecords[models.ISOZoneTable]()},
+		Alerts:        &AlertStore{records: newRecords[models.Alert]()},
+		Webhooks:      &WebhookStore{records: newRecords[models.Webhook]()},
+		Deliveries:    &DeliveryStore{records: newRecords[models.WebhookDelivery]()},
+	}
+}
+
+// records is a mutex-guarded set of values kept in insertion order, like a
+// MongoDB collection without an explicit sort.
+type records[T any] struct {
+	mu    sync.RWMutex
+	items map[primitive.ObjectID]T
+	order []primitive.ObjectID
+}
+
+func newRecords[T any]() *records[T] {
+	return &records[T]{items: map[primitive.ObjectID]T{}}
+}
+
+// get returns the value stored under id.
+func (r *records[T]) get(id primitive.ObjectID) (T, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	value, ok := r.items[id]
+	return value, ok
+}
+
+// put inserts or replaces the value stored under id.
+func (r *records[T]) put(id primitive.ObjectID, value T) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.items[id]; !exists {
+		r.order = append(r.order, id)
+	}
+	r.items[id] = value
+}
+
+// modify applies fn to the value stored under id if match accepts it.
+// It returns store.ErrNotFound when there is no such value.
+func (r *records[T]) modify(id primitive.ObjectID, match func(T) bool, fn func(*T)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	value, ok := r.items[id]
+	if !ok || (match != nil && !match(value)) {
+		return store.ErrNotFound
+	}
+	fn(&value)
+	r.items[id] = value
+	return nil
+}
+
+// remove deletes the value stored under id if match accepts it.
+func (r *records[T]) remove(id primitive.ObjectID, match func(T) bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	value, ok := r.items[id]
+	if !ok || (match != nil && !match(value)) {
+		return store.ErrNotFound
+	}
+	delete(r.items, id)
+	for i, existing := range r.order {
+		if existing == id {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// filter returns every value accepted by match, in insertion order.
+func (r *records[T]) filter(match func(T) bool) []T {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var results []T
+	for _, id := range r.order {
+		if value := r.items[id]; match == nil || match(value) {
+			results = append(results, value)
+		}
+	}
+	return results
+}
+
+// first returns the first value accepted by match.
+func (r *records[T]) first(match func(T) bool) (T, error) {
+	for _, value := range r.filter(match) {
+		return value, nil
+	}
+	var zero T
+	return zero, store.ErrNotFound
+}
+
+// page sorts items with less, then returns the requested page and the total count.
+func page[T any](items []T, less func(a, b T) bool, skip, limit int64) ([]T, int64) {
+	sort.SliceStable(items, func(i, j int) bool { return less(items[i], items[j]) })
+
+	total := int64(len(items))
+	if skip < 0 {
+		skip = 0
+	}
+	if skip >= total {
+		return nil, total
+	}
+	end := total
+	if limit > 0 && skip+limit < total {
+		end = skip + limit
+	}
+	return items[skip:end], total
+}
+
+// newID returns id, or a fresh ObjectID when id is zero.
+func newID(id primitive.ObjectID) primitive.ObjectID {
+	if id.IsZero() {
+		return primitive.NewObjectID()
+	}
+	return id
+}
@@ -0,0 +1,45 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type OrganizationStore struct {
+	records *records[models.Organization]
+}
+
+func (s *OrganizationStore) Create(ctx context.Context, organization *models.Organization) error {
+	organization.ID = newID(organization.ID)
+	s.records.put(organization.ID, *organization)
+	return nil
+}
+
+func (s *OrganizationStore) Get(ctx context.Context, id primitive.ObjectID) (models.Organization, error) {
+	organization, ok := s.records.get(id)
+	if !ok {
+		return models.Organization{}, store.ErrNotFound
+	}
+	return organization, nil
+}
+
+func (s *OrganizationStore) GetByName(ctx context.Context, name string) (models.Organization, error) {
+	return s.records.first(func(organization models.Organization) bool { return organization.Name == name })
+}
+
+func (s *OrganizationStore) List(ctx context.Context) ([]models.Organization, error) {
+	return s.records.filter(nil), nil
+}
+
+func (s *OrganizationStore) Update(ctx context.Context, organization models.Organization) error {
+	return s.records.modify(organization.ID, nil, func(existing *models.Organization) {
+		existing.Name = organization.Name
+	})
+}
+
+func (s *OrganizationStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return s.records.remove(id, nil)
+}
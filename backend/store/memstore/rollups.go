@@ -0,0 +1,91 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type rollupKey struct {
+	tier     string
+	sensorID primitive.ObjectID
+	start    time.Time
+}
+
+type RollupStore struct {
+	mu         sync.RWMutex
+	summaries  map[rollupKey]models.VibrationSummary
+	watermarks map[string]time.Time
+}
+
+func newRollupStore() *RollupStore {
+	return &RollupStore{
+		summaries:  map[rollupKey]models.VibrationSummary{},
+		watermarks: map[string]time.Time{},
+	}
+}
+
+func (s *RollupStore) Upsert(ctx context.Context, tier string, summaries []models.VibrationSummary) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, summary := range summaries {
+		s.summaries[rollupKey{tier, summary.SensorID, summary.Start}] = summary
+	}
+	return nil
+}
+
+func (s *RollupStore) List(ctx context.Context, tier string, sensorID primitive.ObjectID, from, to time.Time) ([]models.VibrationSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var results []models.VibrationSummary
+	for key, summary := range s.summaries {
+		if key.tier == tier && (sensorID.IsZero() || key.sensorID == sensorID) &&
+			!key.start.Before(from) && key.start.Before(to) {
+			results = append(results, summary)
+		}
+	}
+	sortSummaries(results)
+	return results, nil
+}
+
+func (s *RollupStore) DeleteBefore(ctx context.Context, tier string, cutoff time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removed int64
+	for key := range s.summaries {
+		if key.tier == tier && key.start.Before(cutoff) {
+			delete(s.summaries, key)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (s *RollupStore) Watermark(ctx context.Context, tier string) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.watermarks[tier], nil
+}
+
+func (s *RollupStore) SetWatermark(ctx context.Context, tier string, watermark time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.watermarks[tier] = watermark
+	return nil
+}
+
+// sortSummaries orders summaries by bucket start, then sensor.
+func sortSummaries(summaries []models.VibrationSummary) {
+	sort.Slice(summaries, func(i, j int) bool {
+		if !summaries[i].Start.Equal(summaries[j].Start) {
+			return summaries[i].Start.Before(summaries[j].Start)
+		}
+		return summaries[i].SensorID.Hex() < summaries[j].SensorID.Hex()
+	})
+}
@@ -0,0 +1,32 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type SensorConfigStore struct {
+	records *records[models.SensorConfigVersion]
+}
+
+func (s *SensorConfigStore) Create(ctx context.Context, version *models.SensorConfigVersion) error {
+	version.ID = newID(version.ID)
+	s.records.put(version.ID, *version)
+	return nil
+}
+
+func (s *SensorConfigStore) Get(ctx context.Context, sensorID primitive.ObjectID, version int) (models.SensorConfigVersion, error) {
+	return s.records.first(func(entry models.SensorConfigVersion) bool {
+		return entry.SensorID == sensorID && entry.Version == version
+	})
+}
+
+func (s *SensorConfigStore) List(ctx context.Context, sensorID primitive.ObjectID, skip, limit int64) ([]models.SensorConfigVersion, int64, error) {
+	matches := s.records.filter(func(entry models.SensorConfigVersion) bool { return entry.SensorID == sensorID })
+	results, total := page(matches, func(a, b models.SensorConfigVersion) bool {
+		return a.Version > b.Version
+	}, skip, limit)
+	return results, total, nil
+}
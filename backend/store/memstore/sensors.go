@@ -0,0 +1,202 @@
+package memstore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type SensorStore struct {
+	records *records[models.Sensor]
+	// serials serializes the writes that set serial numbers, so that two cannot both
+	// pass the uniqueness check
+	serials sync.Mutex
+}
+
+func sensorInScope(scope store.Scope) func(models.Sensor) bool {
+	return func(sensor models.Sensor) bool { return scope.Matches(sensor.OrganizationID) }
+}
+
+func (s *SensorStore) Create(ctx context.Context, sensor *models.Sensor) error {
+	s.serials.Lock()
+	defer s.serials.Unlock()
+	if s.serialTaken(sensor.SerialNumber, primitive.NilObjectID) {
+		return store.ErrConflict
+	}
+	sensor.ID = newID(sensor.ID)
+	s.records.put(sensor.ID, *sensor)
+	return nil
+}
+
+// serialTaken reports whether a sensor other than id has the serial number. Like the
+// MongoDB index, it leaves sensors without a serial number out.
+func (s *SensorStore) serialTaken(serialNumber string, id primitive.ObjectID) bool {
+	if serialNumber == "" {
+		return false
+	}
+	_, err := s.records.first(func(sensor models.Sensor) bool {
+		return sensor.SerialNumber == serialNumber && sensor.ID != id
+	})
+	return err == nil
+}
+
+func (s *SensorStore) Get(ctx context.Context, scope store.Scope, id primitive.ObjectID) (models.Sensor, error) {
+	sensor, ok := s.records.get(id)
+	if !ok || !scope.Matches(sensor.OrganizationID) {
+		return models.Sensor{}, store.ErrNotFound
+	}
+	return sensor, nil
+}
+
+func (s *SensorStore) GetBySerial(ctx context.Context, serialNumber string) (models.Sensor, error) {
+	return s.records.first(func(sensor models.Sensor) bool { return sensor.SerialNumber == serialNumber })
+}
+
+func (s *SensorStore) GetByTokenHash(ctx context.Context, hash string, at time.Time) (models.Sensor, error) {
+	return s.records.first(func(sensor models.Sensor) bool {
+		if hash == "" {
+			return false
+		}
+		if sensor.TokenHash == hash {
+			return true
+		}
+		return sensor.PreviousTokenHash == hash && sensor.PreviousTokenExpiresAt != nil && at.Before(*sensor.PreviousTokenExpiresAt)
+	})
+}
+
+func (s *SensorStore) List(ctx context.Context, scope store.Scope) ([]models.Sensor, error) {
+	return s.records.filter(sensorInScope(scope)), nil
+}
+
+func (s *SensorStore) Update(ctx context.Context, scope store.Scope, sensor models.Sensor, previous int) error {
+	s.serials.Lock()
+	defer s.serials.Unlock()
+	if s.serialTaken(sensor.SerialNumber, sensor.ID) {
+		return store.ErrConflict
+	}
+	current := func(existing models.Sensor) bool {
+		return scope.Matches(existing.OrganizationID) && existing.ConfigVersion == previous
+	}
+	return s.records.modify(sensor.ID, current, func(existing *models.Sensor) {
+		existing.UserID = sensor.UserID
+		existing.SerialNumber = sensor.SerialNumber
+		existing.Location = sensor.Location
+		existing.Picture = sensor.Picture
+		existing.Tags = sensor.Tags
+		existing.Config = sensor.Config
+		existing.ConfigVersion = sensor.ConfigVersion
+	})
+}
+
+func (s *SensorStore) SetConfig(ctx context.Context, scope store.Scope, id primitive.ObjectID, previous int, cfg models.SensorConfig) error {
+	current := func(sensor models.Sensor) bool {
+		return scope.Matches(sensor.OrganizationID) && sensor.ConfigVersion == previous
+	}
+	return s.records.modify(id, current, func(existing *models.Sensor) {
+		existing.Config = cfg
+		existing.ConfigVersion = previous + 1
+	})
+}
+
+func (s *SensorStore) AssignOrganization(ctx context.Context, id, organizationID primitive.ObjectID) error {
+	orphan := func(sensor models.Sensor) bool { return sensor.OrganizationID.IsZero() }
+	return s.records.modify(id, orphan, func(existing *models.Sensor) { existing.OrganizationID = organizationID })
+}
+
+func (s *SensorStore) ReportConfig(ctx context.Context, id primitive.ObjectID, reported models.ReportedConfig) error {
+	return s.records.modify(id, nil, func(existing *models.Sensor) { existing.ReportedConfig = &reported })
+}
+
+func (s *SensorStore) RotateToken(ctx context.Context, id primitive.ObjectID, current, next string, graceUntil, at time.Time) error {
+	unchanged := func(sensor models.Sensor) bool { return sensor.TokenHash == current }
+	return s.records.modify(id, unchanged, func(existing *models.Sensor) {
+		existing.PreviousTokenHash, existing.PreviousTokenExpiresAt = "", nil
+		if current != "" && !graceUntil.IsZero() {
+			existing.PreviousTokenHash = current
+			existing.PreviousTokenExpiresAt = &graceUntil
+		}
+		existing.TokenHash = next
+		existing.TokenIssuedAt = &at
+		existing.TokenRevokedAt = nil
+		existing.LegacyToken = ""
+	})
+}
+
+func (s *SensorStore) RevokeToken(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	return s.records.modify(id, nil, func(existing *models.Sensor) {
+		existing.TokenHash = ""
+		existing.PreviousTokenHash, existing.PreviousTokenExpiresAt = "", nil
+		existing.TokenRevokedAt = &at
+		existing.LegacyToken = ""
+	})
+}
+
+func (s *SensorStore) Claim(ctx context.Context, id primitive.ObjectID, codeHash string, organizationID, userID primitive.ObjectID, location string, at time.Time) error {
+	claimable := func(sensor models.Sensor) bool {
+		return sensor.OrganizationID.IsZero() && sensor.ClaimedAt == nil && claimCodeValid(sensor, codeHash, at)
+	}
+	return s.records.modify(id, claimable, func(existing *models.Sensor) {
+		existing.OrganizationID = organizationID
+		existing.UserID = userID
+		existing.Location = location
+		existing.ClaimedAt = &at
+		existing.ClaimedBy = userID
+		existing.ClaimFailures = 0
+	})
+}
+
+func (s *SensorStore) RedeemClaimCode(ctx context.Context, id primitive.ObjectID, codeHash, tokenHash string, at time.Time) error {
+	redeemable := func(sensor models.Sensor) bool {
+		return sensor.ClaimedAt != nil && sensor.TokenHash == "" && claimCodeValid(sensor, codeHash, at)
+	}
+	return s.records.modify(id, redeemable, func(existing *models.Sensor) {
+		existing.ClaimCodeHash, existing.ClaimCodeExpiresAt = "", nil
+		existing.ClaimFailures, existing.ClaimLockedUntil = 0, nil
+		existing.TokenHash = tokenHash
+		existing.TokenIssuedAt = &at
+		existing.TokenRevokedAt = nil
+	})
+}
+
+func (s *SensorStore) FailClaimAttempt(ctx context.Context, id primitive.ObjectID, maxFailures int64, lockedUntil time.Time) error {
+	return s.records.modify(id, nil, func(existing *models.Sensor) {
+		existing.ClaimFailures++
+		if existing.ClaimFailures >= maxFailures {
+			existing.ClaimFailures = 0
+			existing.ClaimLockedUntil = &lockedUntil
+		}
+	})
+}
+
+func claimCodeValid(sensor models.Sensor, codeHash string, at time.Time) bool {
+	return codeHash != "" && sensor.ClaimCodeHash == codeHash && sensor.ClaimCodeExpiresAt != nil && at.Before(*sensor.ClaimCodeExpiresAt)
+}
+
+func (s *SensorStore) MarkSeen(ctx context.Context, id primitive.ObjectID, at time.Time, telemetry *models.SensorTelemetry) error {
+	return s.records.modify(id, nil, func(existing *models.Sensor) {
+		existing.Status = models.SensorStatusOnline
+		existing.LastSeenAt = &at
+		existing.OfflineSince = nil
+		if telemetry != nil {
+			existing.Telemetry = telemetry
+		}
+	})
+}
+
+func (s *SensorStore) MarkOffline(ctx context.Context, id primitive.ObjectID, seenBefore, at time.Time) error {
+	silent := func(sensor models.Sensor) bool {
+		return sensor.Status == models.SensorStatusOnline && sensor.LastSeenAt != nil && sensor.LastSeenAt.Before(seenBefore)
+	}
+	return s.records.modify(id, silent, func(existing *models.Sensor) {
+		existing.Status = models.SensorStatusOffline
+		existing.OfflineSince = &at
+	})
+}
+
+func (s *SensorStore) Delete(ctx context.Context, scope store.Scope, id primitive.ObjectID) error {
+	return s.records.remove(id, sensorInScope(scope))
+}
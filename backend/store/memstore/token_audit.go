@@ -0,0 +1,26 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type TokenAuditStore struct {
+	records *records[models.TokenAuditEvent]
+}
+
+func (s *TokenAuditStore) Create(ctx context.Context, event *models.TokenAuditEvent) error {
+	event.ID = newID(event.ID)
+	s.records.put(event.ID, *event)
+	return nil
+}
+
+func (s *TokenAuditStore) List(ctx context.Context, sensorID primitive.ObjectID, skip, limit int64) ([]models.TokenAuditEvent, int64, error) {
+	matches := s.records.filter(func(event models.TokenAuditEvent) bool { return event.SensorID == sensorID })
+	results, total := page(matches, func(a, b models.TokenAuditEvent) bool {
+		return a.At.After(b.At)
+	}, skip, limit)
+	return results, total, nil
+}
@@ -0,0 +1,104 @@
+package memstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PipeSegmentStore struct {
+	records *records[models.PipeSegment]
+}
+
+func segmentInScope(scope store.Scope) func(models.PipeSegment) bool {
+	return func(segment models.PipeSegment) bool { return scope.Matches(segment.OrganizationID) }
+}
+
+func (s *PipeSegmentStore) Create(ctx context.Context, segment *models.PipeSegment) error {
+	segment.ID = newID(segment.ID)
+	s.records.put(segment.ID, *segment)
+	return nil
+}
+
+func (s *PipeSegmentStore) Get(ctx context.Context, scope store.Scope, id primitive.ObjectID) (models.PipeSegment, error) {
+	segment, ok := s.records.get(id)
+	if !ok || !scope.Matches(segment.OrganizationID) {
+		return models.PipeSegment{}, store.ErrNotFound
+	}
+	return segment, nil
+}
+
+func (s *PipeSegmentStore) List(ctx context.Context, scope store.Scope) ([]models.PipeSegment, error) {
+	return s.records.filter(segmentInScope(scope)), nil
+}
+
+func (s *PipeSegmentStore) ListBySensor(ctx context.Context, scope store.Scope, sensorID primitive.ObjectID) ([]models.PipeSegment, error) {
+	return s.records.filter(func(segment models.PipeSegment) bool {
+		return scope.Matches(segment.OrganizationID) && (segment.SensorA == sensorID || segment.SensorB == sensorID)
+	}), nil
+}
+
+func (s *PipeSegmentStore) Update(ctx context.Context, scope store.Scope, segment models.PipeSegment) error {
+	return s.records.modify(segment.ID, segmentInScope(scope), func(existing *models.PipeSegment) {
+		existing.Name = segment.Name
+		existing.SensorA = segment.SensorA
+		existing.SensorB = segment.SensorB
+		existing.Distance = segment.Distance
+		existing.WaveSpeed = segment.WaveSpeed
+		existing.UpdatedAt = segment.UpdatedAt
+	})
+}
+
+func (s *PipeSegmentStore) SetLastAnalyzed(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	return s.records.modify(id, nil, func(segment *models.PipeSegment) {
+		segment.LastAnalyzedAt = at
+	})
+}
+
+func (s *PipeSegmentStore) Delete(ctx context.Context, scope store.Scope, id primitive.ObjectID) error {
+	return s.records.remove(id, segmentInScope(scope))
+}
+
+type LeakCandidateStore struct {
+	records *records[models.LeakCandidate]
+}
+
+func (s *LeakCandidateStore) Create(ctx context.Context, candidate *models.LeakCandidate) error {
+	candidate.ID = newID(candidate.ID)
+	s.records.put(candidate.ID, *candidate)
+	return nil
+}
+
+func (s *LeakCandidateStore) Get(ctx context.Context, scope store.Scope, id primitive.ObjectID) (models.LeakCandidate, error) {
+	candidate, ok := s.records.get(id)
+	if !ok || !scope.Matches(candidate.OrganizationID) {
+		return models.LeakCandidate{}, store.ErrNotFound
+	}
+	return candidate, nil
+}
+
+func (s *LeakCandidateStore) List(ctx context.Context, query store.LeakQuery) ([]models.LeakCandidate, int64, error) {
+	matches := s.records.filter(func(candidate models.LeakCandidate) bool {
+		switch {
+		case !query.Scope.Matches(candidate.OrganizationID):
+			return false
+		case !query.SegmentID.IsZero() && candidate.SegmentID != query.SegmentID:
+			return false
+		case candidate.Confidence < query.MinConfidence:
+			return false
+		case !query.From.IsZero() && candidate.Timestamp.Before(query.From):
+			return false
+		case !query.To.IsZero() && candidate.Timestamp.After(query.To):
+			return false
+		}
+		return true
+	})
+
+	results, total := page(matches, func(a, b models.LeakCandidate) bool {
+		return a.Timestamp.After(b.Timestamp)
+	}, query.Skip, query.Limit)
+	return results, total, nil
+}
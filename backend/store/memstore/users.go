@@ -0,0 +1,83 @@
+package memstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type UserStore struct {
+	records *records[models.User]
+}
+
+func userInScope(scope store.Scope) func(models.User) bool {
+	return func(user models.User) bool { return scope.Matches(user.OrganizationID) }
+}
+
+func (s *UserStore) Create(ctx context.Context, user *models.User) error {
+	user.ID = newID(user.ID)
+	s.records.put(user.ID, *user)
+	return nil
+}
+
+func (s *UserStore) Get(ctx context.Context, scope store.Scope, id primitive.ObjectID) (models.User, error) {
+	user, ok := s.records.get(id)
+	if !ok || !scope.Matches(user.OrganizationID) {
+		return models.User{}, store.ErrNotFound
+	}
+	return user, nil
+}
+
+func (s *UserStore) GetByUsername(ctx context.Context, username string) (models.User, error) {
+	return s.records.first(func(user models.User) bool { return user.Username == username })
+}
+
+func (s *UserStore) List(ctx context.Context, scope store.Scope) ([]models.User, error) {
+	return s.records.filter(userInScope(scope)), nil
+}
+
+func (s *UserStore) Update(ctx context.Context, scope store.Scope, user models.User) error {
+	return s.records.modify(user.ID, userInScope(scope), func(existing *models.User) {
+		existing.Username = user.Username
+		existing.Password = user.Password
+	})
+}
+
+func (s *UserStore) SetRole(ctx context.Context, id primitive.ObjectID, role string) error {
+	return s.records.modify(id, nil, func(existing *models.User) {
+		existing.Role = role
+		if role == models.RoleSuperAdmin {
+			existing.OrganizationID = primitive.NilObjectID
+		}
+	})
+}
+
+func (s *UserStore) SetTokens(ctx context.Context, id primitive.ObjectID, accessToken, refreshToken string, expiry time.Time) error {
+	return s.records.modify(id, nil, func(existing *models.User) {
+		existing.Token = accessToken
+		existing.RefreshToken = refreshToken
+		existing.TokenExpiry = expiry
+	})
+}
+
+func (s *UserStore) AssignOrganization(ctx context.Context, id, organizationID primitive.ObjectID) error {
+	orphan := func(user models.User) bool { return user.OrganizationID.IsZero() }
+	return s.records.modify(id, orphan, func(existing *models.User) {
+		existing.OrganizationID = organizationID
+		existing.LegacyOrganization = nil
+	})
+}
+
+func (s *UserStore) Delete(ctx context.Context, scope store.Scope, id primitive.ObjectID) error {
+	return s.records.remove(id, userInScope(scope))
+}
+
+func (s *UserStore) CountByRole(ctx context.Context, scope store.Scope, role string) (int64, error) {
+	users := s.records.filter(func(user models.User) bool {
+		return user.Role == role && scope.Matches(user.OrganizationID)
+	})
+	return int64(len(users)), nil
+}
@@ -0,0 +1,121 @@
+package memstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/analysis"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type VibrationStore struct {
+	records *records[models.VibrationData]
+}
+
+func (s *VibrationStore) Create(ctx context.Context, vibration *models.VibrationData) error {
+	vibration.ID = newID(vibration.ID)
+	s.records.put(vibration.ID, *vibration)
+	return nil
+}
+
+func (s *VibrationStore) CreateMany(ctx context.Context, vibrations []models.VibrationData) error {
+	for i := range vibrations {
+		if err := s.Create(ctx, &vibrations[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *VibrationStore) Get(ctx context.Context, id primitive.ObjectID) (models.VibrationData, error) {
+	vibration, ok := s.records.get(id)
+	if !ok {
+		return models.VibrationData{}, store.ErrNotFound
+	}
+	return vibration, nil
+}
+
+func (s *VibrationStore) List(ctx context.Context, query store.VibrationQuery) ([]models.VibrationData, int64, error) {
+	var sensorIDs map[primitive.ObjectID]bool
+	if query.SensorIDs != nil {
+		sensorIDs = map[primitive.ObjectID]bool{}
+		for _, id := range query.SensorIDs {
+			sensorIDs[id] = true
+		}
+	}
+
+	matches := s.records.filter(func(v models.VibrationData) bool {
+		switch {
+		case sensorIDs != nil && !sensorIDs[v.SensorID]:
+			return false
+		case !query.WarnID.IsZero() && v.WarnID != query.WarnID:
+			return false
+		case query.WarnLevel != 0 && v.WarnLevel != query.WarnLevel:
+			return false
+		case query.ISOZone != "" && v.ISOZone != query.ISOZone:
+			return false
+		case query.UnitMismatch && !v.UnitMismatch:
+			return false
+		case !query.From.IsZero() && v.Timestamp.Before(query.From):
+			return false
+		case !query.To.IsZero() && v.Timestamp.After(query.To):
+			return false
+		}
+		return true
+	})
+
+	results, total := page(matches, func(a, b models.VibrationData) bool {
+		return a.Timestamp.After(b.Timestamp)
+	}, query.Skip, query.Limit)
+	return results, total, nil
+}
+
+func (s *VibrationStore) Update(ctx context.Context, vibration models.VibrationData) error {
+	return s.records.modify(vibration.ID, nil, func(existing *models.VibrationData) {
+		*existing = vibration
+	})
+}
+
+func (s *VibrationStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return s.records.remove(id, nil)
+}
+
+func (s *VibrationStore) Aggregate(ctx context.Context, query store.AggregateQuery) ([]models.VibrationSummary, error) {
+	var summaries []models.VibrationSummary
+	for _, v := range s.records.filter(func(v models.VibrationData) bool {
+		return (query.SensorID.IsZero() || v.SensorID == query.SensorID) &&
+			!v.Timestamp.Before(query.From) && v.Timestamp.Before(query.To)
+	}) {
+		summaries = append(summaries, summarize(v, query.Interval))
+	}
+	return analysis.MergeSummaries(summaries, query.Interval), nil
+}
+
+// summarize returns the summary of a single reading in its bucket.
+func summarize(v models.VibrationData, interval time.Duration) models.VibrationSummary {
+	summary := models.VibrationSummary{SensorID: v.SensorID, Start: analysis.BucketStart(v.Timestamp, interval)}
+	analysis.AddToSummary(&summary, v)
+	return summary
+}
+
+func (s *VibrationStore) Oldest(ctx context.Context) (time.Time, error) {
+	var oldest time.Time
+	for _, v := range s.records.filter(nil) {
+		if oldest.IsZero() || v.Timestamp.Before(oldest) {
+			oldest = v.Timestamp
+		}
+	}
+	return oldest, nil
+}
+
+func (s *VibrationStore) DeleteBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	var removed int64
+	for _, v := range s.records.filter(func(v models.VibrationData) bool { return v.Timestamp.Before(cutoff) }) {
+		if s.records.remove(v.ID, nil) == nil {
+			removed++
+		}
+	}
+	return removed, nil
+}
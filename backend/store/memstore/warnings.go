@@ -0,0 +1,41 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type WarningStore struct {
+	records *records[models.Warning]
+}
+
+func (s *WarningStore) Count(ctx context.Context) (int64, error) {
+	return int64(len(s.records.filter(nil))), nil
+}
+
+func (s *WarningStore) CreateMany(ctx context.Context, warnings []models.Warning) error {
+	for _, warning := range warnings {
+		warning.ID = newID(warning.ID)
+		s.records.put(warning.ID, warning)
+	}
+	return nil
+}
+
+func (s *WarningStore) Get(ctx context.Context, id primitive.ObjectID) (models.Warning, error) {
+	warning, ok := s.records.get(id)
+	if !ok {
+		return models.Warning{}, store.ErrNotFound
+	}
+	return warning, nil
+}
+
+func (s *WarningStore) GetByLevel(ctx context.Context, level int) (models.Warning, error) {
+	return s.records.first(func(warning models.Warning) bool { return warning.Level == level })
+}
+
+func (s *WarningStore) List(ctx context.Context) ([]models.Warning, error) {
+	return s.records.filter(nil), nil
+}
@@ -0,0 +1,86 @@
+package memstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type WaveformStore struct {
+	records *records[models.Waveform]
+}
+
+func (s *WaveformStore) Create(ctx context.Context, waveform *models.Waveform) error {
+	waveform.ID = newID(waveform.ID)
+	s.records.put(waveform.ID, *waveform)
+	return nil
+}
+
+func (s *WaveformStore) Get(ctx context.Context, id primitive.ObjectID) (models.Waveform, error) {
+	waveform, ok := s.records.get(id)
+	if !ok {
+		return models.Waveform{}, store.ErrNotFound
+	}
+	return waveform, nil
+}
+
+func (s *WaveformStore) List(ctx context.Context, query store.WaveformQuery) ([]models.Waveform, int64, error) {
+	matches := s.records.filter(func(w models.Waveform) bool {
+		return matchesWaveformQuery(query, w.SensorID, w.Timestamp)
+	})
+
+	results, total := page(matches, func(a, b models.Waveform) bool {
+		return a.Timestamp.After(b.Timestamp)
+	}, query.Skip, query.Limit)
+	for i := range results {
+		results[i].X, results[i].Y, results[i].Z = nil, nil, nil
+	}
+	return results, total, nil
+}
+
+// matchesWaveformQuery reports whether a waveform or spectrum of sensorID taken at timestamp matches query.
+func matchesWaveformQuery(query store.WaveformQuery, sensorID primitive.ObjectID, timestamp time.Time) bool {
+	switch {
+	case sensorID != query.SensorID:
+		return false
+	case !query.From.IsZero() && timestamp.Before(query.From):
+		return false
+	case !query.To.IsZero() && timestamp.After(query.To):
+		return false
+	}
+	return true
+}
+
+type SpectrumStore struct {
+	records *records[models.Spectrum]
+}
+
+func (s *SpectrumStore) Create(ctx context.Context, spectrum *models.Spectrum) error {
+	spectrum.ID = newID(spectrum.ID)
+	s.records.put(spectrum.ID, *spectrum)
+	return nil
+}
+
+func (s *SpectrumStore) GetByWaveform(ctx context.Context, waveformID primitive.ObjectID) (models.Spectrum, error) {
+	return s.records.first(func(spectrum models.Spectrum) bool {
+		return spectrum.WaveformID == waveformID
+	})
+}
+
+func (s *SpectrumStore) List(ctx context.Context, query store.WaveformQuery) ([]models.Spectrum, int64, error) {
+	matches := s.records.filter(func(spectrum models.Spectrum) bool {
+		return matchesWaveformQuery(query, spectrum.SensorID, spectrum.Timestamp)
+	})
+
+	results, total := page(matches, func(a, b models.Spectrum) bool {
+		return a.Timestamp.After(b.Timestamp)
+	}, query.Skip, query.Limit)
+	return results, total, nil
+}
+
+func (s *SpectrumStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return s.records.remove(id, nil)
+}
@@ -0,0 +1,112 @@
+package memstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type WebhookStore struct {
+	records *records[models.Webhook]
+}
+
+func webhookInScope(scope store.Scope) func(models.Webhook) bool {
+	return func(webhook models.Webhook) bool { return scope.Matches(webhook.OrganizationID) }
+}
+
+func (s *WebhookStore) Create(ctx context.Context, webhook *models.Webhook) error {
+	webhook.ID = newID(webhook.ID)
+	s.records.put(webhook.ID, *webhook)
+	return nil
+}
+
+func (s *WebhookStore) Get(ctx context.Context, scope store.Scope, id primitive.ObjectID) (models.Webhook, error) {
+	webhook, ok := s.records.get(id)
+	if !ok || !scope.Matches(webhook.OrganizationID) {
+		return models.Webhook{}, store.ErrNotFound
+	}
+	return webhook, nil
+}
+
+func (s *WebhookStore) List(ctx context.Context, scope store.Scope) ([]models.Webhook, error) {
+	return s.records.filter(webhookInScope(scope)), nil
+}
+
+func (s *WebhookStore) ListActive(ctx context.Context, organizationID primitive.ObjectID) ([]models.Webhook, error) {
+	return s.records.filter(func(webhook models.Webhook) bool {
+		return webhook.Active && webhook.OrganizationID == organizationID
+	}), nil
+}
+
+func (s *WebhookStore) Update(ctx context.Context, webhook models.Webhook) error {
+	return s.records.modify(webhook.ID, nil, func(existing *models.Webhook) {
+		existing.URL = webhook.URL
+		existing.Secret = webhook.Secret
+		existing.MinLevel = webhook.MinLevel
+		existing.SensorIDs = webhook.SensorIDs
+		existing.Active = webhook.Active
+	})
+}
+
+func (s *WebhookStore) Delete(ctx context.Context, scope store.Scope, id primitive.ObjectID) error {
+	return s.records.remove(id, webhookInScope(scope))
+}
+
+type DeliveryStore struct {
+	records *records[models.WebhookDelivery]
+}
+
+func (s *DeliveryStore) Create(ctx context.Context, delivery *models.WebhookDelivery) error {
+	delivery.ID = newID(delivery.ID)
+	s.records.put(delivery.ID, *delivery)
+	return nil
+}
+
+func (s *DeliveryStore) Get(ctx context.Context, id primitive.ObjectID) (models.WebhookDelivery, error) {
+	delivery, ok := s.records.get(id)
+	if !ok {
+		return models.WebhookDelivery{}, store.ErrNotFound
+	}
+	return delivery, nil
+}
+
+func (s *DeliveryStore) List(ctx context.Context, query store.DeliveryQuery) ([]models.WebhookDelivery, int64, error) {
+	matches := s.records.filter(func(delivery models.WebhookDelivery) bool {
+		return query.Scope.Matches(delivery.OrganizationID) &&
+			delivery.WebhookID == query.WebhookID &&
+			(query.Status == "" || delivery.Status == query.Status)
+	})
+
+	results, total := page(matches, func(a, b models.WebhookDelivery) bool {
+		return a.CreatedAt.After(b.CreatedAt)
+	}, query.Skip, query.Limit)
+	return results, total, nil
+}
+
+func (s *DeliveryStore) ListByStatus(ctx context.Context, status string) ([]models.WebhookDelivery, error) {
+	return s.records.filter(func(delivery models.WebhookDelivery) bool { return delivery.Status == status }), nil
+}
+
+func (s *DeliveryStore) RecordAttempt(ctx context.Context, id primitive.ObjectID, attempt models.DeliveryAttempt, status string) error {
+	return s.records.modify(id, nil, func(delivery *models.WebhookDelivery) {
+		delivery.Status = status
+		delivery.ResponseCode = attempt.ResponseCode
+		delivery.LastError = attempt.Error
+		delivery.UpdatedAt = attempt.At
+		delivery.Attempts++
+		delivery.AttemptLog = append(append([]models.DeliveryAttempt{}, delivery.AttemptLog...), attempt)
+	})
+}
+
+func (s *DeliveryStore) Requeue(ctx context.Context, scope store.Scope, id primitive.ObjectID) error {
+	failed := func(delivery models.WebhookDelivery) bool {
+		return scope.Matches(delivery.OrganizationID) && delivery.Status == models.DeliveryStatusFailed
+	}
+	return s.records.modify(id, failed, func(delivery *models.WebhookDelivery) {
+		delivery.Status = models.DeliveryStatusPending
+		delivery.UpdatedAt = time.Now()
+	})
+}
@@ -0,0 +1,117 @@
+package mongostore
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// activeAlertStatuses are the statuses under which new occurrences are de-duplicated.
+var activeAlertStatuses = []string{models.AlertStatusOpen, models.AlertStatusAcknowledged}
+
+type AlertStore struct {
+	collection *mongo.Collection
+}
+
+func (s *AlertStore) Raise(ctx context.Context, occurrence store.AlertOccurrence) (store.AlertChange, error) {
+	filter := bson.M{
+		"sensor_id": occurrence.SensorID,
+		"type":      occurrence.Type,
+		"status":    bson.M{"$in": activeAlertStatuses},
+	}
+	update := bson.M{
+		"$set": bson.M{"level": occurrence.Level, "message": occurrence.Message},
+		"$max": bson.M{"peak_level": occurrence.Level, "last_seen_at": occurrence.At},
+		"$min": bson.M{"first_seen_at": occurrence.At},
+		"$inc": bson.M{"occurrences": 1},
+		"$setOnInsert": bson.M{
+			"organization_id": occurrence.OrganizationID,
+			"status":          models.AlertStatusOpen,
+			"history": []models.AlertEvent{
+				{Status: models.AlertStatusOpen, At: occurrence.At, Comment: occurrence.Message},
+			},
+		},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
+
+	var change store.AlertChange
+	var previous models.Alert
+	err := s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&previous)
+	if mongo.IsDuplicateKeyError(err) {
+		// A concurrent occurrence opened the alert first; update that one instead
+		err = s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&previous)
+	}
+	switch {
+	case err == nil:
+		change.Escalated = occurrence.Level > previous.PeakLevel
+		change.Alert, err = findOne[models.Alert](ctx, s.collection, bson.M{"_id": previous.ID})
+	case errors.Is(err, mongo.ErrNoDocuments):
+		change.Opened = true
+		change.Alert, err = findOne[models.Alert](ctx, s.collection, filter)
+	}
+	return change, err
+}
+
+func (s *AlertStore) Get(ctx context.Context, scope store.Scope, id primitive.ObjectID) (models.Alert, error) {
+	return findOne[models.Alert](ctx, s.collection, scoped(scope, bson.M{"_id": id}))
+}
+
+func (s *AlertStore) List(ctx context.Context, query store.AlertQuery) ([]models.Alert, int64, error) {
+	filter := scoped(query.Scope, bson.M{})
+
+	if query.Status != "" {
+		filter["status"] = query.Status
+	}
+	if query.Type != "" {
+		filter["type"] = query.Type
+	}
+	if !query.SensorID.IsZero() {
+		filter["sensor_id"] = query.SensorID
+	}
+	if query.MinLevel != 0 {
+		filter["peak_level"] = bson.M{"$gte": query.MinLevel}
+	}
+	if !query.From.IsZero() {
+		filter["last_seen_at"] = bson.M{"$gte": query.From}
+	}
+	if !query.To.IsZero() {
+		filter["first_seen_at"] = bson.M{"$lte": query.To}
+	}
+
+	return findPage[models.Alert](ctx, s.collection, filter, bson.D{{Key: "last_seen_at", Value: -1}}, query.Skip, query.Limit)
+}
+
+func (s *AlertStore) Transition(ctx context.Context, scope store.Scope, id primitive.ObjectID, from []string, event models.AlertEvent) (models.Alert, error) {
+	set := bson.M{"status": event.Status}
+	switch event.Status {
+	case models.AlertStatusAcknowledged:
+		set["acknowledged_by"] = event.UserID
+		set["acknowledged_at"] = event.At
+	case models.AlertStatusResolved:
+		set["resolved_by"] = event.UserID
+		set["resolved_at"] = event.At
+	}
+	update := bson.M{
+		"$set":  set,
+		"$push": bson.M{"history": event},
+	}
+
+	var alert models.Alert
+	filter := scoped(scope, bson.M{"_id": id, "status": bson.M{"$in": from}})
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&alert)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Tell apart a missing alert from one in the wrong status
+		if _, getErr := s.Get(ctx, scope, id); getErr == nil {
+			return alert, store.ErrConflict
+		}
+		return alert, store.ErrNotFound
+	}
+	return alert, err
+}
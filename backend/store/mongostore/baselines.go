@@ -0,0 +1,37 @@
+package mongostore
+
+import (
+	"context"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type BaselineStore struct {
+	collection *mongo.Collection
+}
+
+func (s *BaselineStore) GetBySensor(ctx context.Context, sensorID primitive.ObjectID) (models.Baseline, error) {
+	return findOne[models.Baseline](ctx, s.collection, bson.M{"sensor_id": sensorID})
+}
+
+func (s *BaselineStore) Save(ctx context.Context, baseline *models.Baseline) error {
+	// Replace by sensor, keeping the ID of an existing baseline
+	replacement := *baseline
+	replacement.ID = primitive.NilObjectID
+	result := s.collection.FindOneAndReplace(ctx,
+		bson.M{"sensor_id": baseline.SensorID},
+		replacement,
+		options.FindOneAndReplace().SetUpsert(true).SetReturnDocument(options.After),
+	)
+
+	var saved models.Baseline
+	if err := result.Decode(&saved); err != nil {
+		return err
+	}
+	baseline.ID = saved.ID
+	return nil
+}
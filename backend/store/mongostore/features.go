@@ -0,0 +1,56 @@
+package mongostore
+
+import (
+	"context"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type FeatureStore struct {
+	collection *mongo.Collection
+}
+
+func (s *FeatureStore) Create(ctx context.Context, features *models.FeatureSet) error {
+	result, err := s.collection.InsertOne(ctx, features)
+	if err != nil {
+		return err
+	}
+	features.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (s *FeatureStore) Get(ctx context.Context, id primitive.ObjectID) (models.FeatureSet, error) {
+	return findOne[models.FeatureSet](ctx, s.collection, bson.M{"_id": id})
+}
+
+func (s *FeatureStore) List(ctx context.Context, query store.FeatureQuery) ([]models.FeatureSet, int64, error) {
+	filter := bson.M{}
+
+	if !query.SensorID.IsZero() {
+		filter["sensor_id"] = query.SensorID
+	}
+	if query.Source != "" {
+		filter["source"] = query.Source
+	}
+
+	timestamp := bson.M{}
+	if !query.From.IsZero() {
+		timestamp["$gte"] = query.From
+	}
+	if !query.To.IsZero() {
+		timestamp["$lte"] = query.To
+	}
+	if len(timestamp) > 0 {
+		filter["timestamp"] = timestamp
+	}
+
+	return findPage[models.FeatureSet](ctx, s.collection, filter, bson.D{{Key: "timestamp", Value: -1}}, query.Skip, query.Limit)
+}
+
+func (s *FeatureStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return deleteOne(ctx, s.collection, bson.M{"_id": id})
+}
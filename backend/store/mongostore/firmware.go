@@ -0,0 +1,180 @@
+package mongostore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FirmwareStore keeps image descriptions in one collection and their binaries, keyed
+// by the image ID, in another.
+type FirmwareStore struct {
+	collection *mongo.Collection
+	data       *mongo.Collection
+}
+
+// firmwareData is the document holding the binary of an image.
+type firmwareData struct {
+	ID   primitive.ObjectID `bson:"_id"`
+	Data []byte             `bson:"data"`
+}
+
+func (s *FirmwareStore) Create(ctx context.Context, image *models.FirmwareImage, data []byte) error {
+	result, err := s.collection.InsertOne(ctx, image)
+	if err != nil {
+		return err
+	}
+	image.ID = result.InsertedID.(primitive.ObjectID)
+
+	if _, err := s.data.InsertOne(ctx, firmwareData{ID: image.ID, Data: data}); err != nil {
+		// Do not leave an image behind that cannot be downloaded
+		s.collection.DeleteOne(ctx, bson.M{"_id": image.ID})
+		return err
+	}
+	return nil
+}
+
+func (s *FirmwareStore) Get(ctx context.Context, id primitive.ObjectID) (models.FirmwareImage, error) {
+	return findOne[models.FirmwareImage](ctx, s.collection, bson.M{"_id": id})
+}
+
+func (s *FirmwareStore) GetByVersion(ctx context.Context, version string) (models.FirmwareImage, error) {
+	return findOne[models.FirmwareImage](ctx, s.collection, bson.M{"version": version})
+}
+
+func (s *FirmwareStore) List(ctx context.Context) ([]models.FirmwareImage, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	return findAll[models.FirmwareImage](ctx, s.collection, bson.M{}, opts)
+}
+
+func (s *FirmwareStore) Data(ctx context.Context, id primitive.ObjectID) ([]byte, error) {
+	document, err := findOne[firmwareData](ctx, s.data, bson.M{"_id": id})
+	return document.Data, err
+}
+
+func (s *FirmwareStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	if err := deleteOne(ctx, s.collection, bson.M{"_id": id}); err != nil {
+		return err
+	}
+	_, err := s.data.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+type FirmwareCampaignStore struct {
+	collection *mongo.Collection
+}
+
+func (s *FirmwareCampaignStore) Create(ctx context.Context, campaign *models.FirmwareCampaign) error {
+	result, err := s.collection.InsertOne(ctx, campaign)
+	if err != nil {
+		return err
+	}
+	campaign.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (s *FirmwareCampaignStore) Get(ctx context.Context, id primitive.ObjectID) (models.FirmwareCampaign, error) {
+	return findOne[models.FirmwareCampaign](ctx, s.collection, bson.M{"_id": id})
+}
+
+func (s *FirmwareCampaignStore) List(ctx context.Context, status string) ([]models.FirmwareCampaign, error) {
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	return findAll[models.FirmwareCampaign](ctx, s.collection, filter, opts)
+}
+
+func (s *FirmwareCampaignStore) Transition(ctx context.Context, id primitive.ObjectID, from []string, status, stage, reason string, at time.Time) (models.FirmwareCampaign, error) {
+	set := bson.M{"status": status, "updated_at": at}
+	if stage != "" {
+		set["stage"] = stage
+	}
+	update := bson.M{"$set": set}
+	if reason != "" {
+		set["pause_reason"] = reason
+	} else {
+		update["$unset"] = bson.M{"pause_reason": ""}
+	}
+
+	var campaign models.FirmwareCampaign
+	filter := bson.M{"_id": id, "status": bson.M{"$in": from}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&campaign)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Tell apart a missing campaign from one in the wrong status
+		if _, getErr := s.Get(ctx, id); getErr == nil {
+			return campaign, store.ErrConflict
+		}
+		return campaign, store.ErrNotFound
+	}
+	return campaign, err
+}
+
+type FirmwareDeploymentStore struct {
+	collection *mongo.Collection
+}
+
+func (s *FirmwareDeploymentStore) CreateMany(ctx context.Context, deployments []models.FirmwareDeployment) error {
+	if len(deployments) == 0 {
+		return nil
+	}
+
+	documents := make([]interface{}, len(deployments))
+	for i, deployment := range deployments {
+		documents[i] = deployment
+	}
+	result, err := s.collection.InsertMany(ctx, documents)
+	if err != nil {
+		return err
+	}
+	for i, id := range result.InsertedIDs {
+		deployments[i].ID = id.(primitive.ObjectID)
+	}
+	return nil
+}
+
+func (s *FirmwareDeploymentStore) ListByCampaign(ctx context.Context, campaignID primitive.ObjectID) ([]models.FirmwareDeployment, error) {
+	return findAll[models.FirmwareDeployment](ctx, s.collection, bson.M{"campaign_id": campaignID})
+}
+
+func (s *FirmwareDeploymentStore) ListBySensor(ctx context.Context, sensorID primitive.ObjectID) ([]models.FirmwareDeployment, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	return findAll[models.FirmwareDeployment](ctx, s.collection, bson.M{"sensor_id": sensorID}, opts)
+}
+
+func (s *FirmwareDeploymentStore) SetStatus(ctx context.Context, campaignID, sensorID primitive.ObjectID, status, message string, at time.Time) (models.FirmwareDeployment, error) {
+	update := bson.M{"$set": bson.M{"status": status, "error": message, "updated_at": at}}
+	if message == "" {
+		update = bson.M{
+			"$set":   bson.M{"status": status, "updated_at": at},
+			"$unset": bson.M{"error": ""},
+		}
+	}
+
+	var deployment models.FirmwareDeployment
+	filter := bson.M{
+		"campaign_id": campaignID,
+		"sensor_id":   sensorID,
+		"status":      bson.M{"$nin": bson.A{models.DeploymentSucceeded, models.DeploymentFailed}},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&deployment)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Tell apart a missing deployment from a finished one
+		_, getErr := findOne[models.FirmwareDeployment](ctx, s.collection, bson.M{"campaign_id": campaignID, "sensor_id": sensorID})
+		if getErr == nil {
+			return deployment, store.ErrConflict
+		}
+		return deployment, store.ErrNotFound
+	}
+	return deployment, err
+}
@@ -0,0 +1,41 @@
+package mongostore
+
+import (
+	"context"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type HeartbeatStore struct {
+	collection *mongo.Collection
+}
+
+func (s *HeartbeatStore) Create(ctx context.Context, heartbeat *models.Heartbeat) error {
+	result, err := s.collection.InsertOne(ctx, heartbeat)
+	if err != nil {
+		return err
+	}
+	heartbeat.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (s *HeartbeatStore) List(ctx context.Context, query store.HeartbeatQuery) ([]models.Heartbeat, int64, error) {
+	filter := bson.M{"sensor_id": query.SensorID}
+
+	timestamp := bson.M{}
+	if !query.From.IsZero() {
+		timestamp["$gte"] = query.From
+	}
+	if !query.To.IsZero() {
+		timestamp["$lte"] = query.To
+	}
+	if len(timestamp) > 0 {
+		filter["timestamp"] = timestamp
+	}
+
+	return findPage[models.Heartbeat](ctx, s.collection, filter, bson.D{{Key: "timestamp", Value: -1}}, query.Skip, query.Limit)
+}
@@ -0,0 +1,44 @@
+package mongostore
+
+import (
+	"context"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type ISOZoneStore struct {
+	collection *mongo.Collection
+}
+
+func (s *ISOZoneStore) Create(ctx context.Context, table *models.ISOZoneTable) error {
+	result, err := s.collection.InsertOne(ctx, table)
+	if err != nil {
+		return err
+	}
+	table.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (s *ISOZoneStore) GetByClass(ctx context.Context, class string) (models.ISOZoneTable, error) {
+	return findOne[models.ISOZoneTable](ctx, s.collection, bson.M{"class": class})
+}
+
+func (s *ISOZoneStore) List(ctx context.Context) ([]models.ISOZoneTable, error) {
+	return findAll[models.ISOZoneTable](ctx, s.collection, bson.M{})
+}
+
+func (s *ISOZoneStore) Update(ctx context.Context, table models.ISOZoneTable) error {
+	update := bson.M{
+		"$set": bson.M{
+			"standard":    table.Standard,
+			"description": table.Description,
+			"ab":          table.AB,
+			"bc":          table.BC,
+			"cd":          table.CD,
+		},
+	}
+	return updateOne(ctx, s.collection, bson.M{"_id": table.ID}, update)
+}
@@ -0,0 +1,260 @@
+// Package mongostore implements the store interfaces on MongoDB.
+package mongostore
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// New returns MongoDB-backed stores using the collections of db.
+func New(db *mongo.Database) *store.Stores {
+	return &store.Stores{
+		Sensors:       &SensorStore{collection: db.Collection("sensors")},
+		Users:         &UserStore{collection: db.Collection("users")},
+		Vibrations:    &VibrationStore{collection: db.Collection("vibrations")},
+		Rollups:       &RollupStore{db: db, state: db.Collection("rollup_state")},
+		Waveforms:     &WaveformStore{collection: db.Collection("waveforms")},
+		Spectra:       &SpectrumStore{collection: db.Collection("spectra")},
+		Features:      &FeatureStore{collection: db.Collection("features")},
+		Baselines:     &BaselineStore{collection: db.Collection("baselines")},
+		Heartbeats:    &HeartbeatStore{collection: db.Collection("heartbeats")},
+		SensorConfigs: &SensorConfigStore{collection: db.Collection("sensor_configs")},
+		TokenAudit:    &TokenAuditStore{collection: db.Collection("sensor_token_audit")},
+		Firmware:      &FirmwareStore{collection: db.Collection("firmware"), data: db.Collection("firmware_data")},
+		Campaigns:     &FirmwareCampaignStore{collection: db.Collection("firmware_campaigns")},
+		Deployments:   &FirmwareDeploymentStore{collection: db.Collection("firmware_deployments")},
+		PipeSegments:  &PipeSegmentStore{collection: db.Collection("pipe_segments")},
+		Leaks:         &LeakCandidateStore{collection: db.Collection("leak_candidates")},
+		Warnings:      &WarningStore{collection: db.Collection("warnings")},
+		Organizations: &OrganizationStore{collection: db.Collection("organizations")},
+		ISOZones:      &ISOZoneStore{collection: db.Collection("iso_zone_tables")},
+		Alerts:        &AlertStore{collection: db.Collection("alerts")},
+		Webhooks:      &WebhookStore{collection: db.Collection("webhooks")},
+		Deliveries:    &DeliveryStore{collection: db.Collection("webhook_deliveries")},
+	}
+}
+
+// EnsureIndexes creates the indexes the time-range queries and rollup upserts rely on.
+func EnsureIndexes(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("vibrations").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "sensor_id", Value: 1}, {Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "timestamp", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("waveforms").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "sensor_id", Value: 1}, {Key: "timestamp", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("spectra").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "sensor_id", Value: 1}, {Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "waveform_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("features").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "sensor_id", Value: 1}, {Key: "timestamp", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("baselines").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "sensor_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("heartbeats").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "sensor_id", Value: 1}, {Key: "timestamp", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Serial numbers are unique, except that any number of sensors may have none.
+	// Existing duplicates must be resolved before this index can be built.
+	_, err = db.Collection("sensors").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "serial_number", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"serial_number": bson.M{"$gt": ""}}),
+		},
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}},
+		{Keys: bson.D{{Key: "previous_token_hash", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("sensor_token_audit").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "sensor_id", Value: 1}, {Key: "at", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("sensor_configs").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "sensor_id", Value: 1}, {Key: "version", Value: -1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("firmware").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "version", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("firmware_deployments").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "campaign_id", Value: 1}, {Key: "sensor_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "sensor_id", Value: 1}, {Key: "created_at", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// At most one open alert per sensor and type, so concurrent occurrences cannot both
+	// open one. Acknowledged alerts need no entry: Raise updates them rather than inserting.
+	_, err = db.Collection("alerts").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "sensor_id", Value: 1}, {Key: "type", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"status": models.AlertStatusOpen}),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("pipe_segments").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "sensor_a_id", Value: 1}}},
+		{Keys: bson.D{{Key: "sensor_b_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("leak_candidates").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "segment_id", Value: 1}, {Key: "timestamp", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, tier := range models.RollupTiers {
+		_, err := db.Collection("vibration_rollups_"+tier.Name).Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "sensor_id", Value: 1}, {Key: "start", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "start", Value: 1}}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scoped adds the organization restriction of scope to filter. A restricted scope
+// without an organization matches no document, as store.Scope documents.
+func scoped(scope store.Scope, filter bson.M) bson.M {
+	switch {
+	case scope.Unrestricted:
+	case scope.OrganizationID.IsZero():
+		filter["organization_id"] = bson.M{"$in": bson.A{}}
+	default:
+		filter["organization_id"] = scope.OrganizationID
+	}
+	return filter
+}
+
+// withoutOrganization matches the document with id if it belongs to no organization,
+// whether organization_id is missing or the nil ID.
+func withoutOrganization(id primitive.ObjectID) bson.M {
+	return bson.M{"_id": id, "organization_id": bson.M{"$in": bson.A{nil, primitive.NilObjectID}}}
+}
+
+// findOne decodes the first document matching filter, mapping a miss to store.ErrNotFound.
+func findOne[T any](ctx context.Context, collection *mongo.Collection, filter bson.M) (T, error) {
+	var result T
+	err := collection.FindOne(ctx, filter).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return result, store.ErrNotFound
+	}
+	return result, err
+}
+
+// findAll decodes every document matching filter.
+func findAll[T any](ctx context.Context, collection *mongo.Collection, filter bson.M, opts ...*options.FindOptions) ([]T, error) {
+	cursor, err := collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []T
+	for cursor.Next(ctx) {
+		var result T
+		if err := cursor.Decode(&result); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, cursor.Err()
+}
+
+// findPage decodes one page of documents matching filter along with the total match count.
+func findPage[T any](ctx context.Context, collection *mongo.Collection, filter bson.M, sort bson.D, skip, limit int64) ([]T, int64, error) {
+	opts := options.Find().SetSkip(skip).SetLimit(limit).SetSort(sort)
+	results, err := findAll[T](ctx, collection, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	return results, total, nil
+}
+
+// updateOne applies update to the document matching filter, mapping a miss to store.ErrNotFound.
+func updateOne(ctx context.Context, collection *mongo.Collection, filter bson.M, update bson.M) error {
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// deleteOne removes the document matching filter, mapping a miss to store.ErrNotFound.
+func deleteOne(ctx context.Context, collection *mongo.Collection, filter bson.M) error {
+	result, err := collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package mongostore
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestScoped(t *testing.T) {
+	id, organizationID := primitive.NewObjectID(), primitive.NewObjectID()
+	tests := []struct {
+		name  string
+		scope store.Scope
+		want  bson.M
+	}{
+		{name: "organization", scope: store.Scope{OrganizationID: organizationID}, want: bson.M{"_id": id, "organization_id": organizationID}},
+		{name: "no organization matches nothing", scope: store.Scope{}, want: bson.M{"_id": id, "organization_id": bson.M{"$in": bson.A{}}}},
+		{name: "unrestricted", scope: store.Scope{Unrestricted: true}, want: bson.M{"_id": id}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scoped(tt.scope, bson.M{"_id": id}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("scoped = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
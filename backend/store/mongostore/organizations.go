@@ -0,0 +1,43 @@
+package mongostore
+
+import (
+	"context"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type OrganizationStore struct {
+	collection *mongo.Collection
+}
+
+func (s *OrganizationStore) Create(ctx context.Context, organization *models.Organization) error {
+	result, err := s.collection.InsertOne(ctx, organization)
+	if err != nil {
+		return err
+	}
+	organization.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (s *OrganizationStore) Get(ctx context.Context, id primitive.ObjectID) (models.Organization, error) {
+	return findOne[models.Organization](ctx, s.collection, bson.M{"_id": id})
+}
+
+func (s *OrganizationStore) GetByName(ctx context.Context, name string) (models.Organization, error) {
+	return findOne[models.Organization](ctx, s.collection, bson.M{"name": name})
+}
+
+func (s *OrganizationStore) List(ctx context.Context) ([]models.Organization, error) {
+	return findAll[models.Organization](ctx, s.collection, bson.M{})
+}
+
+func (s *OrganizationStore) Update(ctx context.Context, organization models.Organization) error {
+	return updateOne(ctx, s.collection, bson.M{"_id": organization.ID}, bson.M{"$set": bson.M{"name": organization.Name}})
+}
+
+func (s *OrganizationStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return deleteOne(ctx, s.collection, bson.M{"_id": id})
+}
@@ -0,0 +1,77 @@
+package mongostore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RollupStore keeps each tier in its own collection, named vibration_rollups_<tier>,
+// and the tier watermarks in rollup_state.
+type RollupStore struct {
+	db    *mongo.Database
+	state *mongo.Collection
+}
+
+func (s *RollupStore) tier(name string) *mongo.Collection {
+	return s.db.Collection("vibration_rollups_" + name)
+}
+
+func (s *RollupStore) Upsert(ctx context.Context, tier string, summaries []models.VibrationSummary) error {
+	if len(summaries) == 0 {
+		return nil
+	}
+
+	writes := make([]mongo.WriteModel, len(summaries))
+	for i, summary := range summaries {
+		writes[i] = mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"sensor_id": summary.SensorID, "start": summary.Start}).
+			SetReplacement(summary).
+			SetUpsert(true)
+	}
+	_, err := s.tier(tier).BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+func (s *RollupStore) List(ctx context.Context, tier string, sensorID primitive.ObjectID, from, to time.Time) ([]models.VibrationSummary, error) {
+	filter := bson.M{"start": bson.M{"$gte": from, "$lt": to}}
+	if !sensorID.IsZero() {
+		filter["sensor_id"] = sensorID
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "start", Value: 1}, {Key: "sensor_id", Value: 1}})
+	return findAll[models.VibrationSummary](ctx, s.tier(tier), filter, opts)
+}
+
+func (s *RollupStore) DeleteBefore(ctx context.Context, tier string, cutoff time.Time) (int64, error) {
+	result, err := s.tier(tier).DeleteMany(ctx, bson.M{"start": bson.M{"$lt": cutoff}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (s *RollupStore) Watermark(ctx context.Context, tier string) (time.Time, error) {
+	var state struct {
+		Watermark time.Time `bson:"watermark"`
+	}
+	err := s.state.FindOne(ctx, bson.M{"_id": tier}).Decode(&state)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return time.Time{}, nil
+	}
+	return state.Watermark, err
+}
+
+func (s *RollupStore) SetWatermark(ctx context.Context, tier string, watermark time.Time) error {
+	_, err := s.state.UpdateOne(ctx,
+		bson.M{"_id": tier},
+		bson.M{"$set": bson.M{"watermark": watermark}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
@@ -0,0 +1,31 @@
+package mongostore
+
+import (
+	"context"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type SensorConfigStore struct {
+	collection *mongo.Collection
+}
+
+func (s *SensorConfigStore) Create(ctx context.Context, version *models.SensorConfigVersion) error {
+	result, err := s.collection.InsertOne(ctx, version)
+	if err != nil {
+		return err
+	}
+	version.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (s *SensorConfigStore) Get(ctx context.Context, sensorID primitive.ObjectID, version int) (models.SensorConfigVersion, error) {
+	return findOne[models.SensorConfigVersion](ctx, s.collection, bson.M{"sensor_id": sensorID, "version": version})
+}
+
+func (s *SensorConfigStore) List(ctx context.Context, sensorID primitive.ObjectID, skip, limit int64) ([]models.SensorConfigVersion, int64, error) {
+	return findPage[models.SensorConfigVersion](ctx, s.collection, bson.M{"sensor_id": sensorID}, bson.D{{Key: "version", Value: -1}}, skip, limit)
+}
@@ -0,0 +1,205 @@
+package mongostore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SensorStore struct {
+	collection *mongo.Collection
+}
+
+func (s *SensorStore) Create(ctx context.Context, sensor *models.Sensor) error {
+	result, err := s.collection.InsertOne(ctx, sensor)
+	if mongo.IsDuplicateKeyError(err) {
+		return store.ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+	sensor.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (s *SensorStore) Get(ctx context.Context, scope store.Scope, id primitive.ObjectID) (models.Sensor, error) {
+	return findOne[models.Sensor](ctx, s.collection, scoped(scope, bson.M{"_id": id}))
+}
+
+func (s *SensorStore) GetBySerial(ctx context.Context, serialNumber string) (models.Sensor, error) {
+	return findOne[models.Sensor](ctx, s.collection, bson.M{"serial_number": serialNumber})
+}
+
+func (s *SensorStore) GetByTokenHash(ctx context.Context, hash string, at time.Time) (models.Sensor, error) {
+	if hash == "" {
+		return models.Sensor{}, store.ErrNotFound
+	}
+	filter := bson.M{"$or": bson.A{
+		bson.M{"token_hash": hash},
+		bson.M{"previous_token_hash": hash, "previous_token_expires_at": bson.M{"$gt": at}},
+	}}
+	return findOne[models.Sensor](ctx, s.collection, filter)
+}
+
+func (s *SensorStore) List(ctx context.Context, scope store.Scope) ([]models.Sensor, error) {
+	return findAll[models.Sensor](ctx, s.collection, scoped(scope, bson.M{}))
+}
+
+func (s *SensorStore) Update(ctx context.Context, scope store.Scope, sensor models.Sensor, previous int) error {
+	update := bson.M{
+		"$set": bson.M{
+			"user_id":        sensor.UserID,
+			"serial_number":  sensor.SerialNumber,
+			"location":       sensor.Location,
+			"picture":        sensor.Picture,
+			"tags":           sensor.Tags,
+			"config":         sensor.Config,
+			"config_version": sensor.ConfigVersion,
+		},
+	}
+	err := updateOne(ctx, s.collection, atConfigVersion(scope, sensor.ID, previous), update)
+	if mongo.IsDuplicateKeyError(err) {
+		return store.ErrConflict
+	}
+	return err
+}
+
+func (s *SensorStore) SetConfig(ctx context.Context, scope store.Scope, id primitive.ObjectID, previous int, cfg models.SensorConfig) error {
+	update := bson.M{"$set": bson.M{"config": cfg, "config_version": previous + 1}}
+	return updateOne(ctx, s.collection, atConfigVersion(scope, id, previous), update)
+}
+
+// atConfigVersion matches the sensor while its config is still at version previous.
+func atConfigVersion(scope store.Scope, id primitive.ObjectID, previous int) bson.M {
+	filter := scoped(scope, bson.M{"_id": id, "config_version": previous})
+	if previous == 0 {
+		// Sensors created before config versioning have no version stored
+		filter["config_version"] = bson.M{"$in": bson.A{0, nil}}
+	}
+	return filter
+}
+
+func (s *SensorStore) AssignOrganization(ctx context.Context, id, organizationID primitive.ObjectID) error {
+	update := bson.M{"$set": bson.M{"organization_id": organizationID}}
+	return updateOne(ctx, s.collection, withoutOrganization(id), update)
+}
+
+func (s *SensorStore) ReportConfig(ctx context.Context, id primitive.ObjectID, reported models.ReportedConfig) error {
+	return updateOne(ctx, s.collection, bson.M{"_id": id}, bson.M{"$set": bson.M{"reported_config": reported}})
+}
+
+func (s *SensorStore) RotateToken(ctx context.Context, id primitive.ObjectID, current, next string, graceUntil, at time.Time) error {
+	filter := bson.M{"_id": id, "token_hash": current}
+	if current == "" {
+		// Sensors without a token have no token_hash stored
+		filter["token_hash"] = bson.M{"$in": bson.A{"", nil}}
+	}
+
+	set := bson.M{"token_hash": next, "token_issued_at": at}
+	unset := bson.M{"token_revoked_at": "", "token": ""}
+	if current != "" && !graceUntil.IsZero() {
+		set["previous_token_hash"] = current
+		set["previous_token_expires_at"] = graceUntil
+	} else {
+		unset["previous_token_hash"] = ""
+		unset["previous_token_expires_at"] = ""
+	}
+	return updateOne(ctx, s.collection, filter, bson.M{"$set": set, "$unset": unset})
+}
+
+func (s *SensorStore) RevokeToken(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	update := bson.M{
+		"$set":   bson.M{"token_revoked_at": at},
+		"$unset": bson.M{"token_hash": "", "previous_token_hash": "", "previous_token_expires_at": "", "token": ""},
+	}
+	return updateOne(ctx, s.collection, bson.M{"_id": id}, update)
+}
+
+func (s *SensorStore) Claim(ctx context.Context, id primitive.ObjectID, codeHash string, organizationID, userID primitive.ObjectID, location string, at time.Time) error {
+	if codeHash == "" {
+		return store.ErrNotFound
+	}
+	filter := bson.M{
+		"_id":                   id,
+		"organization_id":       bson.M{"$exists": false},
+		"claimed_at":            bson.M{"$exists": false},
+		"claim_code_hash":       codeHash,
+		"claim_code_expires_at": bson.M{"$gt": at},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"organization_id": organizationID,
+			"user_id":         userID,
+			"location":        location,
+			"claimed_at":      at,
+			"claimed_by":      userID,
+		},
+		"$unset": bson.M{"claim_failures": ""},
+	}
+	return updateOne(ctx, s.collection, filter, update)
+}
+
+func (s *SensorStore) RedeemClaimCode(ctx context.Context, id primitive.ObjectID, codeHash, tokenHash string, at time.Time) error {
+	if codeHash == "" {
+		return store.ErrNotFound
+	}
+	filter := bson.M{
+		"_id":                   id,
+		"claimed_at":            bson.M{"$exists": true},
+		"token_hash":            bson.M{"$in": bson.A{"", nil}},
+		"claim_code_hash":       codeHash,
+		"claim_code_expires_at": bson.M{"$gt": at},
+	}
+	update := bson.M{
+		"$set":   bson.M{"token_hash": tokenHash, "token_issued_at": at},
+		"$unset": bson.M{"claim_code_hash": "", "claim_code_expires_at": "", "claim_failures": "", "claim_locked_until": "", "token_revoked_at": ""},
+	}
+	return updateOne(ctx, s.collection, filter, update)
+}
+
+func (s *SensorStore) FailClaimAttempt(ctx context.Context, id primitive.ObjectID, maxFailures int64, lockedUntil time.Time) error {
+	var sensor models.Sensor
+	err := s.collection.FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{"$inc": bson.M{"claim_failures": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&sensor)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return store.ErrNotFound
+	}
+	if err != nil || sensor.ClaimFailures < maxFailures {
+		return err
+	}
+
+	// Concurrent failures past the limit all match, and lock the code only once
+	filter := bson.M{"_id": id, "claim_failures": bson.M{"$gte": maxFailures}}
+	update := bson.M{
+		"$set":   bson.M{"claim_locked_until": lockedUntil},
+		"$unset": bson.M{"claim_failures": ""},
+	}
+	_, err = s.collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (s *SensorStore) MarkSeen(ctx context.Context, id primitive.ObjectID, at time.Time, telemetry *models.SensorTelemetry) error {
+	set := bson.M{"status": models.SensorStatusOnline, "last_seen_at": at}
+	if telemetry != nil {
+		set["telemetry"] = telemetry
+	}
+	update := bson.M{"$set": set, "$unset": bson.M{"offline_since": ""}}
+	return updateOne(ctx, s.collection, bson.M{"_id": id}, update)
+}
+
+func (s *SensorStore) MarkOffline(ctx context.Context, id primitive.ObjectID, seenBefore, at time.Time) error {
+	filter := bson.M{"_id": id, "status": models.SensorStatusOnline, "last_seen_at": bson.M{"$lt": seenBefore}}
+	update := bson.M{"$set": bson.M{"status": models.SensorStatusOffline, "offline_since": at}}
+	return updateOne(ctx, s.collection, filter, update)
+}
+
+func (s *SensorStore) Delete(ctx context.Context, scope store.Scope, id primitive.ObjectID) error {
+	return deleteOne(ctx, s.collection, scoped(scope, bson.M{"_id": id}))
+}
@@ -0,0 +1,27 @@
+package mongostore
+
+import (
+	"context"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type TokenAuditStore struct {
+	collection *mongo.Collection
+}
+
+func (s *TokenAuditStore) Create(ctx context.Context, event *models.TokenAuditEvent) error {
+	result, err := s.collection.InsertOne(ctx, event)
+	if err != nil {
+		return err
+	}
+	event.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (s *TokenAuditStore) List(ctx context.Context, sensorID primitive.ObjectID, skip, limit int64) ([]models.TokenAuditEvent, int64, error) {
+	return findPage[models.TokenAuditEvent](ctx, s.collection, bson.M{"sensor_id": sensorID}, bson.D{{Key: "at", Value: -1}}, skip, limit)
+}
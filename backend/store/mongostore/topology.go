@@ -0,0 +1,101 @@
+package mongostore
+
+import (
+	"context"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type PipeSegmentStore struct {
+	collection *mongo.Collection
+}
+
+func (s *PipeSegmentStore) Create(ctx context.Context, segment *models.PipeSegment) error {
+	result, err := s.collection.InsertOne(ctx, segment)
+	if err != nil {
+		return err
+	}
+	segment.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (s *PipeSegmentStore) Get(ctx context.Context, scope store.Scope, id primitive.ObjectID) (models.PipeSegment, error) {
+	return findOne[models.PipeSegment](ctx, s.collection, scoped(scope, bson.M{"_id": id}))
+}
+
+func (s *PipeSegmentStore) List(ctx context.Context, scope store.Scope) ([]models.PipeSegment, error) {
+	return findAll[models.PipeSegment](ctx, s.collection, scoped(scope, bson.M{}))
+}
+
+func (s *PipeSegmentStore) ListBySensor(ctx context.Context, scope store.Scope, sensorID primitive.ObjectID) ([]models.PipeSegment, error) {
+	filter := bson.M{"$or": bson.A{bson.M{"sensor_a_id": sensorID}, bson.M{"sensor_b_id": sensorID}}}
+	return findAll[models.PipeSegment](ctx, s.collection, scoped(scope, filter))
+}
+
+func (s *PipeSegmentStore) Update(ctx context.Context, scope store.Scope, segment models.PipeSegment) error {
+	update := bson.M{
+		"$set": bson.M{
+			"name":        segment.Name,
+			"sensor_a_id": segment.SensorA,
+			"sensor_b_id": segment.SensorB,
+			"distance":    segment.Distance,
+			"wave_speed":  segment.WaveSpeed,
+			"updated_at":  segment.UpdatedAt,
+		},
+	}
+	return updateOne(ctx, s.collection, scoped(scope, bson.M{"_id": segment.ID}), update)
+}
+
+func (s *PipeSegmentStore) SetLastAnalyzed(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	return updateOne(ctx, s.collection, bson.M{"_id": id}, bson.M{"$set": bson.M{"last_analyzed_at": at}})
+}
+
+func (s *PipeSegmentStore) Delete(ctx context.Context, scope store.Scope, id primitive.ObjectID) error {
+	return deleteOne(ctx, s.collection, scoped(scope, bson.M{"_id": id}))
+}
+
+type LeakCandidateStore struct {
+	collection *mongo.Collection
+}
+
+func (s *LeakCandidateStore) Create(ctx context.Context, candidate *models.LeakCandidate) error {
+	result, err := s.collection.InsertOne(ctx, candidate)
+	if err != nil {
+		return err
+	}
+	candidate.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (s *LeakCandidateStore) Get(ctx context.Context, scope store.Scope, id primitive.ObjectID) (models.LeakCandidate, error) {
+	return findOne[models.LeakCandidate](ctx, s.collection, scoped(scope, bson.M{"_id": id}))
+}
+
+func (s *LeakCandidateStore) List(ctx context.Context, query store.LeakQuery) ([]models.LeakCandidate, int64, error) {
+	filter := scoped(query.Scope, bson.M{})
+
+	if !query.SegmentID.IsZero() {
+		filter["segment_id"] = query.SegmentID
+	}
+	if query.MinConfidence > 0 {
+		filter["confidence"] = bson.M{"$gte": query.MinConfidence}
+	}
+
+	timestamp := bson.M{}
+	if !query.From.IsZero() {
+		timestamp["$gte"] = query.From
+	}
+	if !query.To.IsZero() {
+		timestamp["$lte"] = query.To
+	}
+	if len(timestamp) > 0 {
+		filter["timestamp"] = timestamp
+	}
+
+	return findPage[models.LeakCandidate](ctx, s.collection, filter, bson.D{{Key: "timestamp", Value: -1}}, query.Skip, query.Limit)
+}
@@ -0,0 +1,79 @@
+package mongostore
+
+import (
+	"context"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type UserStore struct {
+	collection *mongo.Collection
+}
+
+func (s *UserStore) Create(ctx context.Context, user *models.User) error {
+	result, err := s.collection.InsertOne(ctx, user)
+	if err != nil {
+		return err
+	}
+	user.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (s *UserStore) Get(ctx context.Context, scope store.Scope, id primitive.ObjectID) (models.User, error) {
+	return findOne[models.User](ctx, s.collection, scoped(scope, bson.M{"_id": id}))
+}
+
+func (s *UserStore) GetByUsername(ctx context.Context, username string) (models.User, error) {
+	return findOne[models.User](ctx, s.collection, bson.M{"username": username})
+}
+
+func (s *UserStore) List(ctx context.Context, scope store.Scope) ([]models.User, error) {
+	return findAll[models.User](ctx, s.collection, scoped(scope, bson.M{}))
+}
+
+func (s *UserStore) Update(ctx context.Context, scope store.Scope, user models.User) error {
+	update := bson.M{
+		"$set": bson.M{
+			"username": user.Username,
+			"password": user.Password,
+		},
+	}
+	return updateOne(ctx, s.collection, scoped(scope, bson.M{"_id": user.ID}), update)
+}
+
+func (s *UserStore) SetRole(ctx context.Context, id primitive.ObjectID, role string) error {
+	update := bson.M{"$set": bson.M{"role": role}}
+	if role == models.RoleSuperAdmin {
+		update["$unset"] = bson.M{"organization_id": ""}
+	}
+	return updateOne(ctx, s.collection, bson.M{"_id": id}, update)
+}
+
+func (s *UserStore) SetTokens(ctx context.Context, id primitive.ObjectID, accessToken, refreshToken string, expiry time.Time) error {
+	update := bson.M{
+		"$set": bson.M{
+			"token":         accessToken,
+			"refresh_token": refreshToken,
+			"token_expiry":  expiry,
+		},
+	}
+	return updateOne(ctx, s.collection, bson.M{"_id": id}, update)
+}
+
+func (s *UserStore) AssignOrganization(ctx context.Context, id, organizationID primitive.ObjectID) error {
+	update := bson.M{"$set": bson.M{"organization_id": organizationID}, "$unset": bson.M{"organization": ""}}
+	return updateOne(ctx, s.collection, withoutOrganization(id), update)
+}
+
+func (s *UserStore) Delete(ctx context.Context, scope store.Scope, id primitive.ObjectID) error {
+	return deleteOne(ctx, s.collection, scoped(scope, bson.M{"_id": id}))
+}
+
+func (s *UserStore) CountByRole(ctx context.Context, scope store.Scope, role string) (int64, error) {
+	return s.collection.CountDocuments(ctx, scoped(scope, bson.M{"role": role}))
+}
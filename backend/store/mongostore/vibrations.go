@@ -0,0 +1,226 @@
+package mongostore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type VibrationStore struct {
+	collection *mongo.Collection
+}
+
+func (s *VibrationStore) Create(ctx context.Context, vibration *models.VibrationData) error {
+	result, err := s.collection.InsertOne(ctx, vibration)
+	if err != nil {
+		return err
+	}
+	vibration.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (s *VibrationStore) CreateMany(ctx context.Context, vibrations []models.VibrationData) error {
+	documents := make([]interface{}, len(vibrations))
+	for i, vibration := range vibrations {
+		documents[i] = vibration
+	}
+
+	result, err := s.collection.InsertMany(ctx, documents)
+	if err != nil {
+		return err
+	}
+
+	for i, id := range result.InsertedIDs {
+		vibrations[i].ID = id.(primitive.ObjectID)
+	}
+	return nil
+}
+
+func (s *VibrationStore) Get(ctx context.Context, id primitive.ObjectID) (models.VibrationData, error) {
+	return findOne[models.VibrationData](ctx, s.collection, bson.M{"_id": id})
+}
+
+func (s *VibrationStore) List(ctx context.Context, query store.VibrationQuery) ([]models.VibrationData, int64, error) {
+	filter := bson.M{}
+
+	if query.SensorIDs != nil {
+		filter["sensor_id"] = bson.M{"$in": query.SensorIDs}
+	}
+	if !query.WarnID.IsZero() {
+		filter["warn_id"] = query.WarnID
+	}
+	if query.WarnLevel != 0 {
+		filter["warn_level"] = query.WarnLevel
+	}
+	if query.ISOZone != "" {
+		filter["iso_zone"] = query.ISOZone
+	}
+	if query.UnitMismatch {
+		filter["unit_mismatch"] = true
+	}
+
+	timestamp := bson.M{}
+	if !query.From.IsZero() {
+		timestamp["$gte"] = query.From
+	}
+	if !query.To.IsZero() {
+		timestamp["$lte"] = query.To
+	}
+	if len(timestamp) > 0 {
+		filter["timestamp"] = timestamp
+	}
+
+	return findPage[models.VibrationData](ctx, s.collection, filter, bson.D{{Key: "timestamp", Value: -1}}, query.Skip, query.Limit)
+}
+
+func (s *VibrationStore) Update(ctx context.Context, vibration models.VibrationData) error {
+	update := bson.M{
+		"$set": bson.M{
+			"sensor_id":     vibration.SensorID,
+			"warn_id":       vibration.WarnID,
+			"warn_level":    vibration.WarnLevel,
+			"warn_override": vibration.WarnOverride,
+			"iso_zone":      vibration.ISOZone,
+			"unit_mismatch": vibration.UnitMismatch,
+			"anomaly_score": vibration.AnomalyScore,
+			"timestamp":     vibration.Timestamp,
+			"x_axisg":       vibration.X_Axisg,
+			"y_axisg":       vibration.Y_Axisg,
+			"z_axisg":       vibration.Z_Axisg,
+			"x_axismm_s2":   vibration.X_Axismm_s2,
+			"y_axismm_s2":   vibration.Y_Axismm_s2,
+			"z_axismm_s2":   vibration.Z_Axismm_s2,
+			"x_axismm_s":    vibration.X_Axismm_s,
+			"y_axismm_s":    vibration.Y_Axismm_s,
+			"z_axismm_s":    vibration.Z_Axismm_s,
+		},
+	}
+	return updateOne(ctx, s.collection, bson.M{"_id": vibration.ID}, update)
+}
+
+func (s *VibrationStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return deleteOne(ctx, s.collection, bson.M{"_id": id})
+}
+
+// Aggregate groups readings server-side into epoch-aligned buckets. The bucket
+// start is computed from epoch milliseconds so any interval works, not only calendar units.
+func (s *VibrationStore) Aggregate(ctx context.Context, query store.AggregateQuery) ([]models.VibrationSummary, error) {
+	millis := bson.M{"$toLong": "$timestamp"}
+	intervalMillis := query.Interval.Milliseconds()
+
+	group := bson.M{
+		"_id": bson.M{
+			"sensor_id": "$sensor_id",
+			"start": bson.M{"$toDate": bson.M{"$subtract": bson.A{
+				millis,
+				bson.M{"$mod": bson.A{millis, intervalMillis}},
+			}}},
+		},
+		"count":          bson.M{"$sum": 1},
+		"max_warn_level": bson.M{"$max": "$warn_level"},
+	}
+	for _, channel := range models.VibrationChannels {
+		value := "$" + channel.Field
+		group[channel.Field+"_min"] = bson.M{"$min": value}
+		group[channel.Field+"_max"] = bson.M{"$max": value}
+		group[channel.Field+"_sum"] = bson.M{"$sum": value}
+		group[channel.Field+"_sum_sq"] = bson.M{"$sum": bson.M{"$multiply": bson.A{value, value}}}
+	}
+
+	match := bson.M{"timestamp": bson.M{"$gte": query.From, "$lt": query.To}}
+	if !query.SensorID.IsZero() {
+		match["sensor_id"] = query.SensorID
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: group}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id.start", Value: 1}, {Key: "_id.sensor_id", Value: 1}}}},
+	}
+
+	cursor, err := s.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var summaries []models.VibrationSummary
+	for cursor.Next(ctx) {
+		var row bson.M
+		if err := cursor.Decode(&row); err != nil {
+			return nil, err
+		}
+
+		key, _ := row["_id"].(bson.M)
+		sensorID, _ := key["sensor_id"].(primitive.ObjectID)
+		start, _ := key["start"].(primitive.DateTime)
+		summary := models.VibrationSummary{
+			SensorID:     sensorID,
+			Start:        start.Time().UTC(),
+			Count:        toInt64(row["count"]),
+			MaxWarnLevel: int(toInt64(row["max_warn_level"])),
+			Channels:     map[string]models.ChannelSummary{},
+		}
+		for _, channel := range models.VibrationChannels {
+			summary.Channels[channel.Field] = models.ChannelSummary{
+				Min:   toFloat64(row[channel.Field+"_min"]),
+				Max:   toFloat64(row[channel.Field+"_max"]),
+				Sum:   toFloat64(row[channel.Field+"_sum"]),
+				SumSq: toFloat64(row[channel.Field+"_sum_sq"]),
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, cursor.Err()
+}
+
+func (s *VibrationStore) Oldest(ctx context.Context) (time.Time, error) {
+	opts := options.FindOne().SetSort(bson.D{{Key: "timestamp", Value: 1}}).SetProjection(bson.M{"timestamp": 1})
+	var oldest models.VibrationData
+	err := s.collection.FindOne(ctx, bson.M{}, opts).Decode(&oldest)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return time.Time{}, nil
+	}
+	return oldest.Timestamp, err
+}
+
+func (s *VibrationStore) DeleteBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := s.collection.DeleteMany(ctx, bson.M{"timestamp": bson.M{"$lt": cutoff}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// toInt64 converts a numeric aggregation result, whose BSON type depends on the inputs.
+func toInt64(value interface{}) int64 {
+	switch n := value.(type) {
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	}
+	return 0
+}
+
+// toFloat64 converts a numeric aggregation result, whose BSON type depends on the inputs.
+func toFloat64(value interface{}) float64 {
+	switch n := value.(type) {
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
@@ -0,0 +1,39 @@
+package mongostore
+
+import (
+	"context"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type WarningStore struct {
+	collection *mongo.Collection
+}
+
+func (s *WarningStore) Count(ctx context.Context) (int64, error) {
+	return s.collection.CountDocuments(ctx, bson.M{})
+}
+
+func (s *WarningStore) CreateMany(ctx context.Context, warnings []models.Warning) error {
+	var documents []interface{}
+	for _, warning := range warnings {
+		documents = append(documents, warning)
+	}
+	_, err := s.collection.InsertMany(ctx, documents)
+	return err
+}
+
+func (s *WarningStore) Get(ctx context.Context, id primitive.ObjectID) (models.Warning, error) {
+	return findOne[models.Warning](ctx, s.collection, bson.M{"_id": id})
+}
+
+func (s *WarningStore) GetByLevel(ctx context.Context, level int) (models.Warning, error) {
+	return findOne[models.Warning](ctx, s.collection, bson.M{"level": level})
+}
+
+func (s *WarningStore) List(ctx context.Context) ([]models.Warning, error) {
+	return findAll[models.Warning](ctx, s.collection, bson.M{})
+}
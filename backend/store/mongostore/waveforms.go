@@ -0,0 +1,91 @@
+package mongostore
+
+import (
+	"context"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type WaveformStore struct {
+	collection *mongo.Collection
+}
+
+func (s *WaveformStore) Create(ctx context.Context, waveform *models.Waveform) error {
+	result, err := s.collection.InsertOne(ctx, waveform)
+	if err != nil {
+		return err
+	}
+	waveform.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (s *WaveformStore) Get(ctx context.Context, id primitive.ObjectID) (models.Waveform, error) {
+	return findOne[models.Waveform](ctx, s.collection, bson.M{"_id": id})
+}
+
+func (s *WaveformStore) List(ctx context.Context, query store.WaveformQuery) ([]models.Waveform, int64, error) {
+	filter := waveformFilter(query)
+	opts := options.Find().
+		SetSkip(query.Skip).
+		SetLimit(query.Limit).
+		SetSort(bson.D{{Key: "timestamp", Value: -1}}).
+		SetProjection(bson.M{"x": 0, "y": 0, "z": 0})
+
+	results, err := findAll[models.Waveform](ctx, s.collection, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	return results, total, nil
+}
+
+type SpectrumStore struct {
+	collection *mongo.Collection
+}
+
+func (s *SpectrumStore) Create(ctx context.Context, spectrum *models.Spectrum) error {
+	result, err := s.collection.InsertOne(ctx, spectrum)
+	if err != nil {
+		return err
+	}
+	spectrum.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (s *SpectrumStore) GetByWaveform(ctx context.Context, waveformID primitive.ObjectID) (models.Spectrum, error) {
+	return findOne[models.Spectrum](ctx, s.collection, bson.M{"waveform_id": waveformID})
+}
+
+func (s *SpectrumStore) List(ctx context.Context, query store.WaveformQuery) ([]models.Spectrum, int64, error) {
+	return findPage[models.Spectrum](ctx, s.collection, waveformFilter(query), bson.D{{Key: "timestamp", Value: -1}}, query.Skip, query.Limit)
+}
+
+// waveformFilter builds the filter shared by waveform and spectrum queries.
+func waveformFilter(query store.WaveformQuery) bson.M {
+	filter := bson.M{"sensor_id": query.SensorID}
+
+	timestamp := bson.M{}
+	if !query.From.IsZero() {
+		timestamp["$gte"] = query.From
+	}
+	if !query.To.IsZero() {
+		timestamp["$lte"] = query.To
+	}
+	if len(timestamp) > 0 {
+		filter["timestamp"] = timestamp
+	}
+	return filter
+}
+
+func (s *SpectrumStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return deleteOne(ctx, s.collection, bson.M{"_id": id})
+}
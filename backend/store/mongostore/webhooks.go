@@ -0,0 +1,103 @@
+package mongostore
+
+import (
+	"context"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type WebhookStore struct {
+	collection *mongo.Collection
+}
+
+func (s *WebhookStore) Create(ctx context.Context, webhook *models.Webhook) error {
+	result, err := s.collection.InsertOne(ctx, webhook)
+	if err != nil {
+		return err
+	}
+	webhook.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (s *WebhookStore) Get(ctx context.Context, scope store.Scope, id primitive.ObjectID) (models.Webhook, error) {
+	return findOne[models.Webhook](ctx, s.collection, scoped(scope, bson.M{"_id": id}))
+}
+
+func (s *WebhookStore) List(ctx context.Context, scope store.Scope) ([]models.Webhook, error) {
+	return findAll[models.Webhook](ctx, s.collection, scoped(scope, bson.M{}))
+}
+
+func (s *WebhookStore) ListActive(ctx context.Context, organizationID primitive.ObjectID) ([]models.Webhook, error) {
+	return findAll[models.Webhook](ctx, s.collection, bson.M{"organization_id": organizationID, "active": true})
+}
+
+func (s *WebhookStore) Update(ctx context.Context, webhook models.Webhook) error {
+	update := bson.M{
+		"$set": bson.M{
+			"url":        webhook.URL,
+			"secret":     webhook.Secret,
+			"min_level":  webhook.MinLevel,
+			"sensor_ids": webhook.SensorIDs,
+			"active":     webhook.Active,
+		},
+	}
+	return updateOne(ctx, s.collection, bson.M{"_id": webhook.ID}, update)
+}
+
+func (s *WebhookStore) Delete(ctx context.Context, scope store.Scope, id primitive.ObjectID) error {
+	return deleteOne(ctx, s.collection, scoped(scope, bson.M{"_id": id}))
+}
+
+type DeliveryStore struct {
+	collection *mongo.Collection
+}
+
+func (s *DeliveryStore) Create(ctx context.Context, delivery *models.WebhookDelivery) error {
+	result, err := s.collection.InsertOne(ctx, delivery)
+	if err != nil {
+		return err
+	}
+	delivery.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (s *DeliveryStore) Get(ctx context.Context, id primitive.ObjectID) (models.WebhookDelivery, error) {
+	return findOne[models.WebhookDelivery](ctx, s.collection, bson.M{"_id": id})
+}
+
+func (s *DeliveryStore) List(ctx context.Context, query store.DeliveryQuery) ([]models.WebhookDelivery, int64, error) {
+	filter := scoped(query.Scope, bson.M{"webhook_id": query.WebhookID})
+	if query.Status != "" {
+		filter["status"] = query.Status
+	}
+	return findPage[models.WebhookDelivery](ctx, s.collection, filter, bson.D{{Key: "created_at", Value: -1}}, query.Skip, query.Limit)
+}
+
+func (s *DeliveryStore) ListByStatus(ctx context.Context, status string) ([]models.WebhookDelivery, error) {
+	return findAll[models.WebhookDelivery](ctx, s.collection, bson.M{"status": status})
+}
+
+func (s *DeliveryStore) RecordAttempt(ctx context.Context, id primitive.ObjectID, attempt models.DeliveryAttempt, status string) error {
+	update := bson.M{
+		"$set": bson.M{
+			"status":        status,
+			"response_code": attempt.ResponseCode,
+			"last_error":    attempt.Error,
+			"updated_at":    attempt.At,
+		},
+		"$inc":  bson.M{"attempts": 1},
+		"$push": bson.M{"attempt_log": attempt},
+	}
+	return updateOne(ctx, s.collection, bson.M{"_id": id}, update)
+}
+
+func (s *DeliveryStore) Requeue(ctx context.Context, scope store.Scope, id primitive.ObjectID) error {
+	filter := scoped(scope, bson.M{"_id": id, "status": models.DeliveryStatusFailed})
+	update := bson.M{"$set": bson.M{"status": models.DeliveryStatusPending, "updated_at": time.Now()}}
+	return updateOne(ctx, s.collection, filter, update)
+}
@@ -0,0 +1,154 @@
+// Package pgstore implements the vibration store on PostgreSQL. When the
+// TimescaleDB extension is available the readings table becomes a hypertable
+// partitioned by time; on plain PostgreSQL it stays a regular indexed table.
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	_ "github.com/lib/pq"
+)
+
+// migrations are applied in order and recorded in schema_migrations.
+// Append new steps; never edit one that has been released.
+var migrations = []string{
+	// 1: readings table. Timescale requires the time column in every unique
+	// index, so the key is (timestamp, id) and lookups by id use their own index.
+	`CREATE TABLE vibrations (
+		id            TEXT        NOT NULL,
+		sensor_id     TEXT        NOT NULL,
+		warn_id       TEXT        NOT NULL DEFAULT '',
+		timestamp     TIMESTAMPTZ NOT NULL,
+		warn_level    INTEGER     NOT NULL DEFAULT 0,
+		warn_override BOOLEAN     NOT NULL DEFAULT FALSE,
+		iso_zone      TEXT        NOT NULL DEFAULT '',
+		x_axis_g      REAL        NOT NULL DEFAULT 0,
+		y_axis_g      REAL        NOT NULL DEFAULT 0,
+		z_axis_g      REAL        NOT NULL DEFAULT 0,
+		x_axis_mm_s2  REAL        NOT NULL DEFAULT 0,
+		y_axis_mm_s2  REAL        NOT NULL DEFAULT 0,
+		z_axis_mm_s2  REAL        NOT NULL DEFAULT 0,
+		x_axis_mm_s   REAL        NOT NULL DEFAULT 0,
+		y_axis_mm_s   REAL        NOT NULL DEFAULT 0,
+		z_axis_mm_s   REAL        NOT NULL DEFAULT 0,
+		PRIMARY KEY (timestamp, id)
+	);
+	CREATE INDEX vibrations_id_idx ON vibrations (id);
+	CREATE INDEX vibrations_sensor_time_idx ON vibrations (sensor_id, timestamp DESC);
+	CREATE INDEX vibrations_warn_level_time_idx ON vibrations (warn_level, timestamp DESC);`,
+
+	// 2: time partitioning with daily chunks when TimescaleDB is installed. The
+	// extension only loads from shared_preload_libraries; if it cannot be enabled
+	// the table stays plain instead of failing startup.
+	`DO $$
+	BEGIN
+		IF EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'timescaledb') THEN
+			BEGIN
+				CREATE EXTENSION IF NOT EXISTS timescaledb;
+				PERFORM create_hypertable('vibrations', 'timestamp',
+					chunk_time_interval => INTERVAL '1 day',
+					if_not_exists => TRUE,
+					migrate_data => TRUE);
+			EXCEPTION WHEN OTHERS THEN
+				RAISE WARNING 'TimescaleDB could not be enabled: %', SQLERRM;
+			END;
+		END IF;
+	END
+	$$;`,
+
+	// 3: materialized summaries of every rollup tier, and the tier watermarks
+	`CREATE TABLE vibration_rollups (
+		tier           TEXT        NOT NULL,
+		sensor_id      TEXT        NOT NULL,
+		start          TIMESTAMPTZ NOT NULL,
+		count          BIGINT      NOT NULL,
+		max_warn_level INTEGER     NOT NULL,
+		channels       JSONB       NOT NULL,
+		PRIMARY KEY (tier, sensor_id, start)
+	);
+	CREATE INDEX vibration_rollups_tier_start_idx ON vibration_rollups (tier, start);
+	CREATE TABLE rollup_state (
+		tier      TEXT        PRIMARY KEY,
+		watermark TIMESTAMPTZ NOT NULL
+	);`,
+
+	// 4: readings whose g and mm/s² fields disagree
+	`ALTER TABLE vibrations ADD COLUMN unit_mismatch BOOLEAN NOT NULL DEFAULT FALSE;`,
+
+	// 5: anomaly score against the sensor baseline, NULL when there was none
+	`ALTER TABLE vibrations ADD COLUMN anomaly_score REAL;`,
+}
+
+// Open connects to PostgreSQL and checks the connection.
+func Open(url string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PostgreSQL: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping PostgreSQL: %v", err)
+	}
+	return db, nil
+}
+
+// Migrate applies every migration that has not been applied yet, each in its own transaction.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER     PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %v", err)
+	}
+
+	var current int
+	err = db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %v", err)
+	}
+
+	for i := current; i < len(migrations); i++ {
+		version := i + 1
+		if err := apply(ctx, db, version, migrations[i]); err != nil {
+			return fmt.Errorf("migration %d failed: %v", version, err)
+		}
+		log.Println("Applied PostgreSQL migration", version)
+	}
+	return checkTimescale(ctx, db)
+}
+
+// checkTimescale logs a warning when TimescaleDB is available on the server but
+// not enabled, which leaves the readings in a plain table.
+func checkTimescale(ctx context.Context, db *sql.DB) error {
+	var available, enabled bool
+	err := db.QueryRowContext(ctx, `SELECT
+		EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'timescaledb'),
+		EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescaledb')`).Scan(&available, &enabled)
+	if err != nil {
+		return fmt.Errorf("failed to check TimescaleDB: %v", err)
+	}
+	if available && !enabled {
+		log.Println("TimescaleDB is available but not enabled, so readings are stored in a plain table; add timescaledb to shared_preload_libraries to partition them by time")
+	}
+	return nil
+}
+
+func apply(ctx context.Context, db *sql.DB, version int, statement string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, statement); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
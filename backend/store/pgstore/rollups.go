@@ -0,0 +1,99 @@
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RollupStore keeps the summaries of every tier in vibration_rollups, with
+// the per-channel totals as JSON.
+type RollupStore struct {
+	db *sql.DB
+}
+
+// NewRollupStore returns a rollup store on a migrated database.
+func NewRollupStore(db *sql.DB) *RollupStore {
+	return &RollupStore{db: db}
+}
+
+func (s *RollupStore) Upsert(ctx context.Context, tier string, summaries []models.VibrationSummary) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, summary := range summaries {
+		channels, err := json.Marshal(summary.Channels)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO vibration_rollups (tier, sensor_id, start, count, max_warn_level, channels)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (tier, sensor_id, start) DO UPDATE
+			SET count = EXCLUDED.count, max_warn_level = EXCLUDED.max_warn_level, channels = EXCLUDED.channels`,
+			tier, summary.SensorID.Hex(), summary.Start, summary.Count, summary.MaxWarnLevel, channels)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *RollupStore) List(ctx context.Context, tier string, sensorID primitive.ObjectID, from, to time.Time) ([]models.VibrationSummary, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT sensor_id, start, count, max_warn_level, channels
+		FROM vibration_rollups
+		WHERE tier = $1 AND ($2 = '' OR sensor_id = $2) AND start >= $3 AND start < $4
+		ORDER BY start, sensor_id`,
+		tier, hexOrEmpty(sensorID), from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.VibrationSummary
+	for rows.Next() {
+		var summary models.VibrationSummary
+		var id string
+		var channels []byte
+		if err := rows.Scan(&id, &summary.Start, &summary.Count, &summary.MaxWarnLevel, &channels); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(channels, &summary.Channels); err != nil {
+			return nil, err
+		}
+		summary.SensorID, _ = primitive.ObjectIDFromHex(id)
+		summary.Start = summary.Start.UTC()
+		results = append(results, summary)
+	}
+	return results, rows.Err()
+}
+
+func (s *RollupStore) DeleteBefore(ctx context.Context, tier string, cutoff time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM vibration_rollups WHERE tier = $1 AND start < $2`, tier, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *RollupStore) Watermark(ctx context.Context, tier string) (time.Time, error) {
+	var watermark time.Time
+	err := s.db.QueryRowContext(ctx, `SELECT watermark FROM rollup_state WHERE tier = $1`, tier).Scan(&watermark)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	return watermark, err
+}
+
+func (s *RollupStore) SetWatermark(ctx context.Context, tier string, watermark time.Time) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO rollup_state (tier, watermark) VALUES ($1, $2)
+		ON CONFLICT (tier) DO UPDATE SET watermark = EXCLUDED.watermark`, tier, watermark)
+	return err
+}
@@ -0,0 +1,314 @@
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"github.com/lib/pq"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// VibrationStore keeps readings in the vibrations table. IDs stay ObjectIDs,
+// stored as hex, so readings can be referenced the same way on either backend.
+type VibrationStore struct {
+	db *sql.DB
+}
+
+// NewVibrationStore returns a vibration store on a migrated database.
+func NewVibrationStore(db *sql.DB) *VibrationStore {
+	return &VibrationStore{db: db}
+}
+
+const vibrationColumns = `id, sensor_id, warn_id, timestamp, warn_level, warn_override, iso_zone,
+	x_axis_g, y_axis_g, z_axis_g, x_axis_mm_s2, y_axis_mm_s2, z_axis_mm_s2, x_axis_mm_s, y_axis_mm_s, z_axis_mm_s,
+	unit_mismatch, anomaly_score`
+
+const insertVibration = `INSERT INTO vibrations (` + vibrationColumns + `)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)`
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func insert(ctx context.Context, db execer, v *models.VibrationData) error {
+	if v.ID.IsZero() {
+		v.ID = primitive.NewObjectID()
+	}
+	_, err := db.ExecContext(ctx, insertVibration,
+		v.ID.Hex(), v.SensorID.Hex(), hexOrEmpty(v.WarnID), v.Timestamp, v.WarnLevel, v.WarnOverride, v.ISOZone,
+		v.X_Axisg, v.Y_Axisg, v.Z_Axisg,
+		v.X_Axismm_s2, v.Y_Axismm_s2, v.Z_Axismm_s2,
+		v.X_Axismm_s, v.Y_Axismm_s, v.Z_Axismm_s,
+		v.UnitMismatch, v.AnomalyScore,
+	)
+	return err
+}
+
+func (s *VibrationStore) Create(ctx context.Context, vibration *models.VibrationData) error {
+	return insert(ctx, s.db, vibration)
+}
+
+// CreateMany inserts every reading or none of them.
+func (s *VibrationStore) CreateMany(ctx context.Context, vibrations []models.VibrationData) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i := range vibrations {
+		if err := insert(ctx, tx, &vibrations[i]); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *VibrationStore) Get(ctx context.Context, id primitive.ObjectID) (models.VibrationData, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+vibrationColumns+` FROM vibrations WHERE id = $1`, id.Hex())
+	vibration, err := scanVibration(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return vibration, store.ErrNotFound
+	}
+	return vibration, err
+}
+
+// List has the semantics of the MongoDB store: every set field of query
+// filters, results are newest first and a zero limit returns all matches.
+func (s *VibrationStore) List(ctx context.Context, query store.VibrationQuery) ([]models.VibrationData, int64, error) {
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.Replace(condition, "?", "$"+strconv.Itoa(len(args)), 1))
+	}
+
+	if query.SensorIDs != nil {
+		ids := make([]string, len(query.SensorIDs))
+		for i, id := range query.SensorIDs {
+			ids[i] = id.Hex()
+		}
+		where("sensor_id = ANY(?)", pq.Array(ids))
+	}
+	if !query.WarnID.IsZero() {
+		where("warn_id = ?", query.WarnID.Hex())
+	}
+	if query.WarnLevel != 0 {
+		where("warn_level = ?", query.WarnLevel)
+	}
+	if query.ISOZone != "" {
+		where("iso_zone = ?", query.ISOZone)
+	}
+	if query.UnitMismatch {
+		where("unit_mismatch = ?", true)
+	}
+	if !query.From.IsZero() {
+		where("timestamp >= ?", query.From)
+	}
+	if !query.To.IsZero() {
+		where("timestamp <= ?", query.To)
+	}
+
+	filter := ""
+	if len(conditions) > 0 {
+		filter = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int64
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM vibrations`+filter, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	statement := `SELECT ` + vibrationColumns + ` FROM vibrations` + filter + ` ORDER BY timestamp DESC, id DESC`
+	if query.Limit > 0 {
+		statement += ` LIMIT ` + strconv.FormatInt(query.Limit, 10)
+	}
+	if query.Skip > 0 {
+		statement += ` OFFSET ` + strconv.FormatInt(query.Skip, 10)
+	}
+
+	rows, err := s.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var results []models.VibrationData
+	for rows.Next() {
+		vibration, err := scanVibration(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		results = append(results, vibration)
+	}
+	return results, total, rows.Err()
+}
+
+// Update replaces a reading. It is a delete and re-insert because a changed
+// timestamp may move the row to another time partition.
+func (s *VibrationStore) Update(ctx context.Context, vibration models.VibrationData) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM vibrations WHERE id = $1`, vibration.ID.Hex())
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return store.ErrNotFound
+	}
+
+	if err := insert(ctx, tx, &vibration); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *VibrationStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM vibrations WHERE id = $1`, id.Hex())
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanVibration(row scanner) (models.VibrationData, error) {
+	var v models.VibrationData
+	var id, sensorID, warnID string
+	var anomalyScore sql.NullFloat64
+	err := row.Scan(
+		&id, &sensorID, &warnID, &v.Timestamp, &v.WarnLevel, &v.WarnOverride, &v.ISOZone,
+		&v.X_Axisg, &v.Y_Axisg, &v.Z_Axisg,
+		&v.X_Axismm_s2, &v.Y_Axismm_s2, &v.Z_Axismm_s2,
+		&v.X_Axismm_s, &v.Y_Axismm_s, &v.Z_Axismm_s,
+		&v.UnitMismatch, &anomalyScore,
+	)
+	if err != nil {
+		return v, err
+	}
+	if anomalyScore.Valid {
+		v.AnomalyScore = &anomalyScore.Float64
+	}
+
+	v.ID, _ = primitive.ObjectIDFromHex(id)
+	v.SensorID, _ = primitive.ObjectIDFromHex(sensorID)
+	v.WarnID, _ = primitive.ObjectIDFromHex(warnID)
+	return v, nil
+}
+
+// hexOrEmpty stores an unset ObjectID as an empty string rather than 24 zeros.
+func hexOrEmpty(id primitive.ObjectID) string {
+	if id.IsZero() {
+		return ""
+	}
+	return id.Hex()
+}
+
+// channelColumns maps models.VibrationChannels fields to their columns.
+var channelColumns = map[string]string{
+	"x_axisg":     "x_axis_g",
+	"y_axisg":     "y_axis_g",
+	"z_axisg":     "z_axis_g",
+	"x_axismm_s2": "x_axis_mm_s2",
+	"y_axismm_s2": "y_axis_mm_s2",
+	"z_axismm_s2": "z_axis_mm_s2",
+	"x_axismm_s":  "x_axis_mm_s",
+	"y_axismm_s":  "y_axis_mm_s",
+	"z_axismm_s":  "z_axis_mm_s",
+}
+
+// Aggregate groups readings into epoch-aligned buckets in SQL, the same bucketing
+// as TimescaleDB's time_bucket but available on plain PostgreSQL too.
+func (s *VibrationStore) Aggregate(ctx context.Context, query store.AggregateQuery) ([]models.VibrationSummary, error) {
+	columns := []string{
+		`sensor_id`,
+		`to_timestamp(floor(extract(epoch FROM timestamp)::float8 / $2::float8) * $2::float8) AS bucket`,
+		`COUNT(*)`,
+		`MAX(warn_level)`,
+	}
+	for _, channel := range models.VibrationChannels {
+		column := channelColumns[channel.Field] + "::float8"
+		columns = append(columns,
+			"MIN("+column+")",
+			"MAX("+column+")",
+			"SUM("+column+")",
+			"SUM("+column+" * "+column+")",
+		)
+	}
+
+	// An empty $1 selects every sensor
+	statement := `SELECT ` + strings.Join(columns, ", ") + `
+		FROM vibrations
+		WHERE ($1 = '' OR sensor_id = $1) AND timestamp >= $3 AND timestamp < $4
+		GROUP BY sensor_id, bucket
+		ORDER BY bucket, sensor_id`
+
+	rows, err := s.db.QueryContext(ctx, statement, hexOrEmpty(query.SensorID), query.Interval.Seconds(), query.From, query.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []models.VibrationSummary
+	for rows.Next() {
+		summary := models.VibrationSummary{Channels: map[string]models.ChannelSummary{}}
+		totals := make([]models.ChannelSummary, len(models.VibrationChannels))
+
+		var sensorID string
+		dest := []interface{}{&sensorID, &summary.Start, &summary.Count, &summary.MaxWarnLevel}
+		for i := range totals {
+			dest = append(dest, &totals[i].Min, &totals[i].Max, &totals[i].Sum, &totals[i].SumSq)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		summary.SensorID, _ = primitive.ObjectIDFromHex(sensorID)
+		summary.Start = summary.Start.UTC()
+		for i, channel := range models.VibrationChannels {
+			summary.Channels[channel.Field] = totals[i]
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, rows.Err()
+}
+
+func (s *VibrationStore) Oldest(ctx context.Context) (time.Time, error) {
+	var oldest sql.NullTime
+	if err := s.db.QueryRowContext(ctx, `SELECT MIN(timestamp) FROM vibrations`).Scan(&oldest); err != nil {
+		return time.Time{}, err
+	}
+	return oldest.Time, nil
+}
+
+func (s *VibrationStore) DeleteBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM vibrations WHERE timestamp < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
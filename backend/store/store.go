@@ -0,0 +1,418 @@
+// Package store defines the persistence interfaces the HTTP handlers depend on.
+// Implementations live in the mongostore and memstore subpackages.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrNotFound is returned when no record matches the ID or filter.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a record exists but is not in a state that allows the change.
+	ErrConflict = errors.New("conflict")
+)
+
+// Scope restricts a query to one organization. The zero Scope matches nothing, not
+// even records without an organization such as unclaimed sensors; Unrestricted
+// matches every organization.
+type Scope struct {
+	OrganizationID primitive.ObjectID
+	Unrestricted   bool
+}
+
+// Matches reports whether a record of organizationID is visible in the scope.
+func (s Scope) Matches(organizationID primitive.ObjectID) bool {
+	return s.Unrestricted || (!s.OrganizationID.IsZero() && s.OrganizationID == organizationID)
+}
+
+// Stores bundles every store the application uses.
+type Stores struct {
+	Sensors       SensorStore
+	Users         UserStore
+	Vibrations    VibrationStore
+	Rollups       RollupStore
+	Waveforms     WaveformStore
+	Spectra       SpectrumStore
+	Features      FeatureStore
+	Baselines     BaselineStore
+	Heartbeats    HeartbeatStore
+	SensorConfigs SensorConfigStore
+	TokenAudit    TokenAuditStore
+	Firmware      FirmwareStore
+	Campaigns     FirmwareCampaignStore
+	Deployments   FirmwareDeploymentStore
+	PipeSegments  PipeSegmentStore
+	Leaks         LeakCandidateStore
+	Warnings      WarningStore
+	Organizations OrganizationStore
+	ISOZones      ISOZoneStore
+	Alerts        AlertStore
+	Webhooks      WebhookStore
+	Deliveries    DeliveryStore
+}
+
+type SensorStore interface {
+	// Create returns ErrConflict when another sensor has the same serial number.
+	Create(ctx context.Context, sensor *models.Sensor) error
+	Get(ctx context.Context, scope Scope, id primitive.ObjectID) (models.Sensor, error)
+	GetBySerial(ctx context.Context, serialNumber string) (models.Sensor, error)
+	// GetByTokenHash returns the sensor whose current token, or whose previous token
+	// still in its grace period at at, has the given hash.
+	GetByTokenHash(ctx context.Context, hash string, at time.Time) (models.Sensor, error)
+	List(ctx context.Context, scope Scope) ([]models.Sensor, error)
+	// Update replaces the user, serial number, location, picture, tags and desired config
+	// of a sensor and sets its config version to sensor.ConfigVersion, provided its
+	// version is still previous; ErrNotFound otherwise. It returns ErrConflict when
+	// another sensor has the same serial number.
+	Update(ctx context.Context, scope Scope, sensor models.Sensor, previous int) error
+	// SetConfig replaces the desired config of a sensor and raises its version to
+	// previous+1, provided its version is still previous; ErrNotFound otherwise.
+	SetConfig(ctx context.Context, scope Scope, id primitive.ObjectID, previous int, cfg models.SensorConfig) error
+	// AssignOrganization puts a sensor that belongs to no organization into
+	// organizationID; ErrNotFound when it has one.
+	AssignOrganization(ctx context.Context, id, organizationID primitive.ObjectID) error
+	// ReportConfig records the config version the device acknowledged.
+	ReportConfig(ctx context.Context, id primitive.ObjectID, reported models.ReportedConfig) error
+	// RotateToken makes next the token hash of a sensor, provided current still is, and
+	// keeps current valid until graceUntil; a zero graceUntil invalidates it at once.
+	// It returns ErrNotFound when the token changed in the meantime.
+	RotateToken(ctx context.Context, id primitive.ObjectID, current, next string, graceUntil, at time.Time) error
+	// RevokeToken invalidates the current and previous token of a sensor.
+	RevokeToken(ctx context.Context, id primitive.ObjectID, at time.Time) error
+	// Claim moves an unclaimed sensor into organizationID at location, on behalf of
+	// userID, provided its claim code has the given hash and has not expired at at;
+	// ErrNotFound otherwise.
+	Claim(ctx context.Context, id primitive.ObjectID, codeHash string, organizationID, userID primitive.ObjectID, location string, at time.Time) error
+	// RedeemClaimCode consumes the claim code of a claimed sensor and makes tokenHash its
+	// first token, provided the code has the given hash, has not expired at at and no
+	// token was issued yet; ErrNotFound otherwise.
+	RedeemClaimCode(ctx context.Context, id primitive.ObjectID, codeHash, tokenHash string, at time.Time) error
+	// FailClaimAttempt counts a wrong claim code against a sensor. The failure that reaches
+	// maxFailures locks its claim code until lockedUntil and starts the count over.
+	FailClaimAttempt(ctx context.Context, id primitive.ObjectID, maxFailures int64, lockedUntil time.Time) error
+	// MarkSeen records contact from a sensor at at, bringing it online. A non-nil
+	// telemetry replaces the sensor's latest telemetry.
+	MarkSeen(ctx context.Context, id primitive.ObjectID, at time.Time, telemetry *models.SensorTelemetry) error
+	// MarkOffline takes a sensor offline as of at, provided it is online and was last
+	// seen before seenBefore; ErrNotFound otherwise, e.g. after a report in between.
+	MarkOffline(ctx context.Context, id primitive.ObjectID, seenBefore, at time.Time) error
+	Delete(ctx context.Context, scope Scope, id primitive.ObjectID) error
+}
+
+type UserStore interface {
+	Create(ctx context.Context, user *models.User) error
+	Get(ctx context.Context, scope Scope, id primitive.ObjectID) (models.User, error)
+	GetByUsername(ctx context.Context, username string) (models.User, error)
+	List(ctx context.Context, scope Scope) ([]models.User, error)
+	// Update replaces the username and password of a user.
+	Update(ctx context.Context, scope Scope, user models.User) error
+	// SetRole changes the role of a user. Super-admins belong to no organization, so a
+	// user promoted to super-admin leaves theirs.
+	SetRole(ctx context.Context, id primitive.ObjectID, role string) error
+	SetTokens(ctx context.Context, id primitive.ObjectID, accessToken, refreshToken string, expiry time.Time) error
+	// AssignOrganization puts a user who belongs to no organization into organizationID
+	// and drops their legacy free-text organization; ErrNotFound when they have one.
+	AssignOrganization(ctx context.Context, id, organizationID primitive.ObjectID) error
+	Delete(ctx context.Context, scope Scope, id primitive.ObjectID) error
+	CountByRole(ctx context.Context, scope Scope, role string) (int64, error)
+}
+
+// VibrationQuery filters and pages vibration readings, newest first.
+// Zero-valued fields do not filter.
+type VibrationQuery struct {
+	SensorIDs []primitive.ObjectID // When non-nil, only readings of these sensors
+	WarnID    primitive.ObjectID
+	WarnLevel int
+	ISOZone   string
+	// Only readings flagged with a unit mismatch
+	UnitMismatch bool
+	From         time.Time
+	To           time.Time
+	Skip         int64
+	Limit        int64
+}
+
+// AggregateQuery selects the readings in [From, To) to be summarized per sensor
+// and Interval-long bucket, aligned to the Unix epoch. A zero SensorID selects every sensor.
+type AggregateQuery struct {
+	SensorID primitive.ObjectID
+	Interval time.Duration
+	From     time.Time
+	To       time.Time
+}
+
+type VibrationStore interface {
+	Create(ctx context.Context, vibration *models.VibrationData) error
+	CreateMany(ctx context.Context, vibrations []models.VibrationData) error
+	Get(ctx context.Context, id primitive.ObjectID) (models.VibrationData, error)
+	// List returns one page of matching readings and the total number of matches.
+	List(ctx context.Context, query VibrationQuery) ([]models.VibrationData, int64, error)
+	// Aggregate returns a summary of every non-empty bucket, oldest first.
+	Aggregate(ctx context.Context, query AggregateQuery) ([]models.VibrationSummary, error)
+	// Oldest returns the timestamp of the oldest reading; zero when there are none.
+	Oldest(ctx context.Context) (time.Time, error)
+	// DeleteBefore removes readings older than cutoff and returns how many were removed.
+	DeleteBefore(ctx context.Context, cutoff time.Time) (int64, error)
+	Update(ctx context.Context, vibration models.VibrationData) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// RollupStore keeps the materialized summaries of each models.RollupTier.
+type RollupStore interface {
+	// Upsert stores summaries, replacing any existing summary of the same sensor and bucket.
+	Upsert(ctx context.Context, tier string, summaries []models.VibrationSummary) error
+	// List returns the summaries of buckets starting in [from, to), oldest first.
+	// A zero sensorID selects every sensor.
+	List(ctx context.Context, tier string, sensorID primitive.ObjectID, from, to time.Time) ([]models.VibrationSummary, error)
+	DeleteBefore(ctx context.Context, tier string, cutoff time.Time) (int64, error)
+	// Watermark returns the start of the oldest bucket of the tier that may still change;
+	// zero when the tier has never been built.
+	Watermark(ctx context.Context, tier string) (time.Time, error)
+	SetWatermark(ctx context.Context, tier string, watermark time.Time) error
+}
+
+// WaveformQuery filters and pages the waveforms or spectra of one sensor, newest first.
+// Zero From and To do not filter.
+type WaveformQuery struct {
+	SensorID primitive.ObjectID
+	From     time.Time
+	To       time.Time
+	Skip     int64
+	Limit    int64
+}
+
+type WaveformStore interface {
+	Create(ctx context.Context, waveform *models.Waveform) error
+	Get(ctx context.Context, id primitive.ObjectID) (models.Waveform, error)
+	// List returns one page of matching waveforms without their samples, and the total number of matches.
+	List(ctx context.Context, query WaveformQuery) ([]models.Waveform, int64, error)
+}
+
+type SpectrumStore interface {
+	Create(ctx context.Context, spectrum *models.Spectrum) error
+	GetByWaveform(ctx context.Context, waveformID primitive.ObjectID) (models.Spectrum, error)
+	// List returns one page of matching spectra and the total number of matches.
+	List(ctx context.Context, query WaveformQuery) ([]models.Spectrum, int64, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// FeatureQuery filters and pages the feature sets of one sensor, newest first.
+// Zero-valued fields do not filter.
+type FeatureQuery struct {
+	SensorID primitive.ObjectID
+	Source   string
+	From     time.Time
+	To       time.Time
+	Skip     int64
+	Limit    int64
+}
+
+type FeatureStore interface {
+	Create(ctx context.Context, features *models.FeatureSet) error
+	Get(ctx context.Context, id primitive.ObjectID) (models.FeatureSet, error)
+	// List returns one page of matching feature sets and the total number of matches.
+	List(ctx context.Context, query FeatureQuery) ([]models.FeatureSet, int64, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// BaselineStore keeps at most one baseline per sensor.
+type BaselineStore interface {
+	GetBySensor(ctx context.Context, sensorID primitive.ObjectID) (models.Baseline, error)
+	// Save inserts or replaces the baseline of baseline.SensorID.
+	Save(ctx context.Context, baseline *models.Baseline) error
+}
+
+type PipeSegmentStore interface {
+	Create(ctx context.Context, segment *models.PipeSegment) error
+	Get(ctx context.Context, scope Scope, id primitive.ObjectID) (models.PipeSegment, error)
+	List(ctx context.Context, scope Scope) ([]models.PipeSegment, error)
+	// ListBySensor returns the segments that have sensorID at either end.
+	ListBySensor(ctx context.Context, scope Scope, sensorID primitive.ObjectID) ([]models.PipeSegment, error)
+	// Update replaces the name, sensors, distance and wave speed of a segment.
+	Update(ctx context.Context, scope Scope, segment models.PipeSegment) error
+	SetLastAnalyzed(ctx context.Context, id primitive.ObjectID, at time.Time) error
+	Delete(ctx context.Context, scope Scope, id primitive.ObjectID) error
+}
+
+// LeakQuery filters and pages leak candidates, newest first.
+// Zero-valued fields do not filter.
+type LeakQuery struct {
+	Scope         Scope
+	SegmentID     primitive.ObjectID
+	MinConfidence float64
+	From          time.Time
+	To            time.Time
+	Skip          int64
+	Limit         int64
+}
+
+type LeakCandidateStore interface {
+	Create(ctx context.Context, candidate *models.LeakCandidate) error
+	Get(ctx context.Context, scope Scope, id primitive.ObjectID) (models.LeakCandidate, error)
+	// List returns one page of matching candidates and the total number of matches.
+	List(ctx context.Context, query LeakQuery) ([]models.LeakCandidate, int64, error)
+}
+
+// HeartbeatQuery filters and pages the heartbeats of one sensor, newest first.
+// Zero From and To do not filter.
+type HeartbeatQuery struct {
+	SensorID primitive.ObjectID
+	From     time.Time
+	To       time.Time
+	Skip     int64
+	Limit    int64
+}
+
+type HeartbeatStore interface {
+	Create(ctx context.Context, heartbeat *models.Heartbeat) error
+	// List returns one page of matching heartbeats and the total number of matches.
+	List(ctx context.Context, query HeartbeatQuery) ([]models.Heartbeat, int64, error)
+}
+
+// SensorConfigStore keeps the history of every sensor's desired config.
+type SensorConfigStore interface {
+	// Create records a version; a sensor cannot have the same version twice.
+	Create(ctx context.Context, version *models.SensorConfigVersion) error
+	Get(ctx context.Context, sensorID primitive.ObjectID, version int) (models.SensorConfigVersion, error)
+	// List returns one page of a sensor's versions, newest first, and the total number.
+	List(ctx context.Context, sensorID primitive.ObjectID, skip, limit int64) ([]models.SensorConfigVersion, int64, error)
+}
+
+type TokenAuditStore interface {
+	Create(ctx context.Context, event *models.TokenAuditEvent) error
+	// List returns one page of a sensor's events, newest first, and the total number.
+	List(ctx context.Context, sensorID primitive.ObjectID, skip, limit int64) ([]models.TokenAuditEvent, int64, error)
+}
+
+type FirmwareStore interface {
+	// Create stores an image together with its binary.
+	Create(ctx context.Context, image *models.FirmwareImage, data []byte) error
+	Get(ctx context.Context, id primitive.ObjectID) (models.FirmwareImage, error)
+	GetByVersion(ctx context.Context, version string) (models.FirmwareImage, error)
+	List(ctx context.Context) ([]models.FirmwareImage, error)
+	// Data returns the binary of an image.
+	Data(ctx context.Context, id primitive.ObjectID) ([]byte, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+type FirmwareCampaignStore interface {
+	Create(ctx context.Context, campaign *models.FirmwareCampaign) error
+	Get(ctx context.Context, id primitive.ObjectID) (models.FirmwareCampaign, error)
+	// List returns the campaigns in status, or every campaign when it is empty, newest first.
+	List(ctx context.Context, status string) ([]models.FirmwareCampaign, error)
+	// Transition moves a campaign in one of the from statuses to status and, unless empty,
+	// stage, recording reason as its pause reason. It returns ErrConflict when the
+	// campaign is in another status.
+	Transition(ctx context.Context, id primitive.ObjectID, from []string, status, stage, reason string, at time.Time) (models.FirmwareCampaign, error)
+}
+
+type FirmwareDeploymentStore interface {
+	CreateMany(ctx context.Context, deployments []models.FirmwareDeployment) error
+	ListByCampaign(ctx context.Context, campaignID primitive.ObjectID) ([]models.FirmwareDeployment, error)
+	// ListBySensor returns the deployments of a sensor, oldest first.
+	ListBySensor(ctx context.Context, sensorID primitive.ObjectID) ([]models.FirmwareDeployment, error)
+	// SetStatus records the progress a device reported in a campaign. It returns
+	// ErrConflict when the deployment has already finished.
+	SetStatus(ctx context.Context, campaignID, sensorID primitive.ObjectID, status, message string, at time.Time) (models.FirmwareDeployment, error)
+}
+
+type WarningStore interface {
+	Count(ctx context.Context) (int64, error)
+	CreateMany(ctx context.Context, warnings []models.Warning) error
+	Get(ctx context.Context, id primitive.ObjectID) (models.Warning, error)
+	GetByLevel(ctx context.Context, level int) (models.Warning, error)
+	List(ctx context.Context) ([]models.Warning, error)
+}
+
+type OrganizationStore interface {
+	Create(ctx context.Context, organization *models.Organization) error
+	Get(ctx context.Context, id primitive.ObjectID) (models.Organization, error)
+	GetByName(ctx context.Context, name string) (models.Organization, error)
+	List(ctx context.Context) ([]models.Organization, error)
+	Update(ctx context.Context, organization models.Organization) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+type ISOZoneStore interface {
+	Create(ctx context.Context, table *models.ISOZoneTable) error
+	GetByClass(ctx context.Context, class string) (models.ISOZoneTable, error)
+	List(ctx context.Context) ([]models.ISOZoneTable, error)
+	// Update replaces the boundaries and description of a table; the class is kept.
+	Update(ctx context.Context, table models.ISOZoneTable) error
+}
+
+// AlertOccurrence is one abnormal observation to fold into a sensor's active alert.
+type AlertOccurrence struct {
+	SensorID       primitive.ObjectID
+	OrganizationID primitive.ObjectID
+	Type           string
+	Level          int
+	At             time.Time
+	Message        string
+}
+
+// AlertChange describes what AlertStore.Raise did to a sensor's active alert.
+type AlertChange struct {
+	Alert     models.Alert
+	Opened    bool // A new alert was opened
+	Escalated bool // An existing alert reached a higher peak level
+}
+
+// AlertQuery filters and pages alerts, most recently seen first.
+type AlertQuery struct {
+	Scope    Scope
+	Status   string
+	Type     string
+	SensorID primitive.ObjectID
+	MinLevel int       // Minimum peak level
+	From     time.Time // Seen at or after
+	To       time.Time // First seen at or before
+	Skip     int64
+	Limit    int64
+}
+
+type AlertStore interface {
+	// Raise folds the occurrence into the sensor's open or acknowledged alert of the
+	// same type, or opens a new alert when there is none.
+	Raise(ctx context.Context, occurrence AlertOccurrence) (AlertChange, error)
+	Get(ctx context.Context, scope Scope, id primitive.ObjectID) (models.Alert, error)
+	List(ctx context.Context, query AlertQuery) ([]models.Alert, int64, error)
+	// Transition moves an alert whose status is one of from to event.Status and appends
+	// event to its history. It returns ErrConflict when the alert is in another status.
+	Transition(ctx context.Context, scope Scope, id primitive.ObjectID, from []string, event models.AlertEvent) (models.Alert, error)
+}
+
+type WebhookStore interface {
+	Create(ctx context.Context, webhook *models.Webhook) error
+	Get(ctx context.Context, scope Scope, id primitive.ObjectID) (models.Webhook, error)
+	List(ctx context.Context, scope Scope) ([]models.Webhook, error)
+	ListActive(ctx context.Context, organizationID primitive.ObjectID) ([]models.Webhook, error)
+	// Update replaces the URL, secret, minimum level, sensor filter and active flag.
+	Update(ctx context.Context, webhook models.Webhook) error
+	Delete(ctx context.Context, scope Scope, id primitive.ObjectID) error
+}
+
+// DeliveryQuery filters and pages webhook deliveries, newest first.
+type DeliveryQuery struct {
+	Scope     Scope
+	WebhookID primitive.ObjectID
+	Status    string
+	Skip      int64
+	Limit     int64
+}
+
+type DeliveryStore interface {
+	Create(ctx context.Context, delivery *models.WebhookDelivery) error
+	Get(ctx context.Context, id primitive.ObjectID) (models.WebhookDelivery, error)
+	List(ctx context.Context, query DeliveryQuery) ([]models.WebhookDelivery, int64, error)
+	ListByStatus(ctx context.Context, status string) ([]models.WebhookDelivery, error)
+	RecordAttempt(ctx context.Context, id primitive.ObjectID, attempt models.DeliveryAttempt, status string) error
+	// Requeue moves a failed delivery back to pending; ErrNotFound if there is no such failed delivery.
+	Requeue(ctx context.Context, scope Scope, id primitive.ObjectID) error
+}
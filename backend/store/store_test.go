@@ -0,0 +1,32 @@
+package store
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestScopeMatches(t *testing.T) {
+	organizationID := primitive.NewObjectID()
+	tests := []struct {
+		name         string
+		scope        Scope
+		organization primitive.ObjectID
+		want         bool
+	}{
+		{name: "own organization", scope: Scope{OrganizationID: organizationID}, organization: organizationID, want: true},
+		{name: "other organization", scope: Scope{OrganizationID: organizationID}, organization: primitive.NewObjectID()},
+		{name: "record without organization", scope: Scope{OrganizationID: organizationID}},
+		{name: "caller without organization", scope: Scope{}, organization: organizationID},
+		{name: "neither has an organization", scope: Scope{}},
+		{name: "unrestricted", scope: Scope{Unrestricted: true}, organization: organizationID, want: true},
+		{name: "unrestricted without organization", scope: Scope{Unrestricted: true}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.scope.Matches(tt.organization); got != tt.want {
+				t.Errorf("Matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
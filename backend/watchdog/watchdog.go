@@ -0,0 +1,74 @@
+// Package watchdog takes sensors offline when they stop reporting.
+package watchdog
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/config"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/controllers"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+)
+
+// Options configures a Job.
+type Options struct {
+	Interval     time.Duration // How often the job runs
+	Connectivity controllers.ConnectivityOptions
+}
+
+// OptionsFromConfig builds watchdog options from the application config.
+func OptionsFromConfig(cfg *config.Config) Options {
+	return Options{
+		Interval:     cfg.WatchdogInterval,
+		Connectivity: controllers.ConnectivityOptionsFromConfig(cfg),
+	}
+}
+
+// Job checks every online sensor for silence and raises connectivity alerts through
+// the handler, so webhooks are notified like for any other alert.
+type Job struct {
+	handler *controllers.Handler
+	opts    Options
+}
+
+// NewJob returns a watchdog acting through handler.
+func NewJob(handler *controllers.Handler, opts Options) *Job {
+	return &Job{handler: handler, opts: opts}
+}
+
+// Start runs the job once right away and then on every interval in the background.
+func (j *Job) Start() {
+	go func() {
+		ticker := time.NewTicker(j.opts.Interval)
+		defer ticker.Stop()
+
+		for {
+			if err := j.RunOnce(context.Background(), time.Now()); err != nil {
+				log.Println("Sensor watchdog failed:", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// RunOnce takes every sensor silent for too long as of now offline. A failing sensor is
+// logged and does not stop the others.
+func (j *Job) RunOnce(ctx context.Context, now time.Time) error {
+	sensors, err := j.handler.Stores.Sensors.List(ctx, store.Scope{Unrestricted: true})
+	if err != nil {
+		return err
+	}
+
+	for _, sensor := range sensors {
+		offline, err := j.handler.CheckConnectivity(ctx, sensor, j.opts.Connectivity, now)
+		if err != nil {
+			log.Println("Connectivity check of sensor", sensor.ID.Hex(), "failed:", err)
+			continue
+		}
+		if offline {
+			log.Println("Sensor", sensor.ID.Hex(), "went offline")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenDestination is returned for webhook URLs that point into the server's
+// own network, such as loopback, link-local (cloud metadata) or private addresses.
+var ErrForbiddenDestination = errors.New("webhook URL must not point to a loopback, link-local or private address")
+
+// ValidateURL checks that a webhook URL is http(s) and that every address its host
+// resolves to is public. The dispatcher checks the address again when it connects,
+// since DNS may change after registration.
+func ValidateURL(raw string) error {
+	target, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %v", err)
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return errors.New("webhook URL must use http or https")
+	}
+	host := target.Hostname()
+	if host == "" {
+		return errors.New("webhook URL must include a host")
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if !publicIP(ip) {
+			return ErrForbiddenDestination
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("cannot resolve webhook host %s", host)
+	}
+	for _, addr := range addrs {
+		if !publicIP(addr.IP) {
+			return ErrForbiddenDestination
+		}
+	}
+	return nil
+}
+
+// publicIP reports whether ip may receive webhook requests.
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+// newHTTPClient returns a client that refuses to connect to non-public addresses.
+// The check runs on the address actually dialed, so it also covers redirects and
+// hosts whose DNS changed after the webhook was registered.
+func newHTTPClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: requestTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return ErrForbiddenDestination
+			}
+			return nil
+		},
+	}
+	transport := &http.Transport{
+		// No proxy: it would make the proxy, not the webhook host, the dialed address
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   requestTimeout,
+		ResponseHeaderTimeout: requestTimeout,
+		IdleConnTimeout:       90 * time.Second,
+	}
+	return &http.Client{Timeout: requestTimeout, Transport: transport}
+}
@@ -0,0 +1,340 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// DefaultMinLevel is used by webhooks that do not set a minimum level.
+	DefaultMinLevel = models.WarningLevelCritical
+
+	maxAttempts    = 6
+	initialBackoff = 2 * time.Second
+	queueSize      = 1000
+	requestTimeout = 10 * time.Second
+)
+
+// job is either an alert event to fan out to matching webhooks,
+// or a stored delivery to send again.
+type job struct {
+	event      string
+	alert      models.Alert
+	deliveryID primitive.ObjectID
+	attempt    int       // Attempt number of a delivery, 0 for its first
+	due        time.Time // When a retried delivery may be sent
+}
+
+// Dispatcher delivers alert notifications to webhooks in the background.
+// Deliveries are sent by a fixed number of workers; a failed one waits in the retry
+// schedule and then goes through the queue again, so nothing sends outside the workers
+// and Stop cancels every delivery still waiting.
+type Dispatcher struct {
+	webhooks   store.WebhookStore
+	deliveries store.DeliveryStore
+	queue      chan job
+	retries    chan job
+	backoff    time.Duration // Wait before the first retry, doubling for each one after
+	httpClient *http.Client
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewDispatcher returns a dispatcher that reads webhooks and records deliveries in the given stores.
+func NewDispatcher(webhooks store.WebhookStore, deliveries store.DeliveryStore) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		webhooks:   webhooks,
+		deliveries: deliveries,
+		queue:      make(chan job, queueSize),
+		retries:    make(chan job, queueSize),
+		backoff:    initialBackoff,
+		httpClient: newHTTPClient(),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// payload is the JSON body of an alert notification.
+type payload struct {
+	Event  string       `json:"event"`
+	Alert  models.Alert `json:"alert"`
+	SentAt time.Time    `json:"sent_at"`
+}
+
+// Start launches the dispatcher workers and re-queues deliveries that a
+// previous run left pending.
+func (d *Dispatcher) Start(workers int) {
+	for i := 0; i < workers; i++ {
+		go d.work()
+	}
+	go d.schedule()
+
+	pending, err := d.deliveries.ListByStatus(context.Background(), models.DeliveryStatusPending)
+	if err != nil {
+		log.Println("Failed to load pending webhook deliveries:", err)
+		return
+	}
+
+	// There may be more pending deliveries than the queue holds, so wait for room
+	// rather than dropping them, without holding up startup
+	go func() {
+		for _, delivery := range pending {
+			select {
+			case d.queue <- job{deliveryID: delivery.ID}:
+			case <-d.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop cancels the requests in flight and the retries still waiting. Their
+// deliveries stay pending and are sent again on the next Start.
+func (d *Dispatcher) Stop() {
+	d.cancel()
+}
+
+// NotifyAlert queues an alert event for delivery without blocking the caller.
+func (d *Dispatcher) NotifyAlert(event string, alert models.Alert) {
+	d.enqueue(job{event: event, alert: alert})
+}
+
+// Replay queues a stored delivery to be sent again. Unlike alert events, a replay
+// is never dropped: it waits for room in the queue until ctx is done, and then
+// returns ctx's error so the caller can report that nothing was queued.
+func (d *Dispatcher) Replay(ctx context.Context, deliveryID primitive.ObjectID) error {
+	select {
+	case d.queue <- job{deliveryID: deliveryID}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) enqueue(j job) {
+	select {
+	case d.queue <- j:
+	default:
+		log.Println("Webhook queue full, dropping event", j.event, "for alert", j.alert.ID.Hex())
+	}
+}
+
+func (d *Dispatcher) work() {
+	for {
+		select {
+		case j := <-d.queue:
+			if j.deliveryID.IsZero() {
+				d.fanOut(j.event, j.alert)
+			} else {
+				d.resend(j)
+			}
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// schedule holds failed deliveries until their backoff has passed and then queues
+// them for the workers. It holds at most queueSize of them; beyond that retry leaves
+// deliveries pending.
+func (d *Dispatcher) schedule() {
+	var waiting []job // Sorted by due time
+	for {
+		var retries <-chan job
+		if len(waiting) < queueSize {
+			retries = d.retries
+		}
+		var next <-chan time.Time
+		if len(waiting) > 0 {
+			next = time.After(time.Until(waiting[0].due))
+		}
+
+		select {
+		case j := <-retries:
+			i, _ := slices.BinarySearchFunc(waiting, j, func(a, b job) int { return a.due.Compare(b.due) })
+			waiting = slices.Insert(waiting, i, j)
+		case <-next:
+			select {
+			case d.queue <- waiting[0]:
+				waiting = waiting[1:]
+			case <-d.ctx.Done():
+				return
+			}
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// retry schedules the next attempt of a delivery after its backoff. If the schedule
+// is full the delivery stays pending until the next Start rather than blocking a worker.
+func (d *Dispatcher) retry(deliveryID primitive.ObjectID, attempt int) {
+	j := job{deliveryID: deliveryID, attempt: attempt, due: time.Now().Add(d.backoff << (attempt - 1))}
+	select {
+	case d.retries <- j:
+	default:
+		log.Println("Webhook retries full, leaving delivery pending", deliveryID.Hex())
+	}
+}
+
+// fanOut records a pending delivery for every webhook subscribed to the alert
+// and makes the first attempt of each of them.
+func (d *Dispatcher) fanOut(event string, alert models.Alert) {
+	hooks, err := d.webhooks.ListActive(context.Background(), alert.OrganizationID)
+	if err != nil {
+		log.Println("Failed to load webhooks:", err)
+		return
+	}
+
+	for _, webhook := range hooks {
+		if !Matches(webhook, alert) {
+			continue
+		}
+
+		body, err := json.Marshal(payload{Event: event, Alert: alert, SentAt: time.Now()})
+		if err != nil {
+			log.Println("Failed to encode webhook payload:", err)
+			continue
+		}
+
+		now := time.Now()
+		delivery := models.WebhookDelivery{
+			WebhookID:      webhook.ID,
+			OrganizationID: webhook.OrganizationID,
+			AlertID:        alert.ID,
+			Event:          event,
+			Payload:        string(body),
+			Status:         models.DeliveryStatusPending,
+			AttemptLog:     []models.DeliveryAttempt{},
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+		if err := d.deliveries.Create(context.Background(), &delivery); err != nil {
+			log.Println("Failed to record webhook delivery:", err)
+			continue
+		}
+
+		d.deliver(webhook, delivery, 0)
+	}
+}
+
+// Matches reports whether a webhook subscribes to an alert's level and sensor.
+func Matches(webhook models.Webhook, alert models.Alert) bool {
+	minLevel := webhook.MinLevel
+	if minLevel == 0 {
+		minLevel = DefaultMinLevel
+	}
+	if alert.PeakLevel < minLevel {
+		return false
+	}
+
+	if len(webhook.SensorIDs) == 0 {
+		return true
+	}
+	for _, id := range webhook.SensorIDs {
+		if id == alert.SensorID {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Dispatcher) resend(j job) {
+	delivery, err := d.deliveries.Get(context.Background(), j.deliveryID)
+	if err != nil {
+		log.Println("Webhook delivery not found:", j.deliveryID.Hex())
+		return
+	}
+
+	webhook, err := d.webhooks.Get(context.Background(), store.Scope{Unrestricted: true}, delivery.WebhookID)
+	if err != nil {
+		d.recordAttempt(delivery.ID, models.DeliveryAttempt{At: time.Now(), Error: "webhook no longer exists"}, models.DeliveryStatusFailed)
+		return
+	}
+
+	d.deliver(webhook, delivery, j.attempt)
+}
+
+// deliver makes attempt (counting from 0) of posting the delivery payload, and on
+// failure schedules the next one with exponential backoff until maxAttempts is reached.
+func (d *Dispatcher) deliver(webhook models.Webhook, delivery models.WebhookDelivery, attempt int) {
+	result := d.send(webhook, delivery)
+	if d.ctx.Err() != nil {
+		// Stopped mid-request: the delivery stays pending for the next Start
+		return
+	}
+
+	switch {
+	case result.Error == "":
+		d.recordAttempt(delivery.ID, result, models.DeliveryStatusSucceeded)
+	case attempt+1 == maxAttempts:
+		d.recordAttempt(delivery.ID, result, models.DeliveryStatusFailed)
+	default:
+		d.recordAttempt(delivery.ID, result, models.DeliveryStatusPending)
+		d.retry(delivery.ID, attempt+1)
+	}
+}
+
+// send makes one signed POST of the delivery payload.
+func (d *Dispatcher) send(webhook models.Webhook, delivery models.WebhookDelivery) models.DeliveryAttempt {
+	attempt := models.DeliveryAttempt{At: time.Now()}
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(attempt.At.Unix(), 10)
+
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Delivery", delivery.ID.Hex())
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+Sign(webhook.Secret, timestamp, body))
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+
+	attempt.ResponseCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		attempt.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+	return attempt
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>" under the webhook secret.
+// Receivers recompute it to verify the sender and reject replayed timestamps.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (d *Dispatcher) recordAttempt(deliveryID primitive.ObjectID, attempt models.DeliveryAttempt, status string) {
+	err := d.deliveries.RecordAttempt(context.Background(), deliveryID, attempt, status)
+	if err != nil {
+		log.Println("Failed to record webhook attempt:", err)
+	}
+}
@@ -0,0 +1,143 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/models"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store"
+	"github.com/ThirawatEu/vibration-sensor-gas-pipe/store/memstore"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestReplayWaitsForRoomInTheQueue(t *testing.T) {
+	stores := memstore.New()
+	d := NewDispatcher(stores.Webhooks, stores.Deliveries)
+	// No workers run, so the queue fills up and stays full
+	for i := 0; i < queueSize; i++ {
+		d.NotifyAlert(models.WebhookEventAlertOpened, models.Alert{})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := d.Replay(ctx, primitive.NewObjectID()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("replay into a full queue returned %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	<-d.queue
+	if err := d.Replay(context.Background(), primitive.NewObjectID()); err != nil {
+		t.Fatalf("replay with room in the queue returned %v", err)
+	}
+	if len(d.queue) != queueSize {
+		t.Errorf("queue holds %d jobs, want %d", len(d.queue), queueSize)
+	}
+}
+
+// receiver is a webhook endpoint that fails the first failures requests and counts them all.
+type receiver struct {
+	failures int32
+	requests atomic.Int32
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	if r.requests.Add(1) <= r.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+// pendingDelivery starts a dispatcher that posts to a test server backed by r, and
+// stores a pending delivery for it.
+func pendingDelivery(t *testing.T, r *receiver, backoff time.Duration) (*Dispatcher, store.DeliveryStore, primitive.ObjectID) {
+	t.Helper()
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	stores := memstore.New()
+	webhook := models.Webhook{URL: server.URL, Active: true}
+	if err := stores.Webhooks.Create(context.Background(), &webhook); err != nil {
+		t.Fatal(err)
+	}
+	delivery := models.WebhookDelivery{WebhookID: webhook.ID, Payload: "{}", Status: models.DeliveryStatusPending}
+	if err := stores.Deliveries.Create(context.Background(), &delivery); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDispatcher(stores.Webhooks, stores.Deliveries)
+	d.httpClient = server.Client()
+	d.backoff = backoff
+	t.Cleanup(d.Stop)
+	return d, stores.Deliveries, delivery.ID
+}
+
+// waitFor polls the delivery until done accepts it or the test times out.
+func waitFor(t *testing.T, deliveries store.DeliveryStore, id primitive.ObjectID, done func(models.WebhookDelivery) bool) models.WebhookDelivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		delivery, err := deliveries.Get(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if done(delivery) {
+			return delivery
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery still %s after %d attempts", delivery.Status, delivery.Attempts)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDeliveriesRetryThroughTheQueue(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int32
+		status   string
+		attempts int
+	}{
+		{name: "first attempt succeeds", failures: 0, status: models.DeliveryStatusSucceeded, attempts: 1},
+		{name: "succeeds after retries", failures: 2, status: models.DeliveryStatusSucceeded, attempts: 3},
+		{name: "gives up after maxAttempts", failures: maxAttempts, status: models.DeliveryStatusFailed, attempts: maxAttempts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &receiver{failures: tt.failures}
+			d, deliveries, id := pendingDelivery(t, r, time.Millisecond)
+			// A single worker proves the retries wait in the schedule, not in the worker
+			d.Start(1)
+
+			delivery := waitFor(t, deliveries, id, func(delivery models.WebhookDelivery) bool {
+				return delivery.Status != models.DeliveryStatusPending
+			})
+			if delivery.Status != tt.status || delivery.Attempts != tt.attempts {
+				t.Errorf("delivery %s after %d attempts, want %s after %d", delivery.Status, delivery.Attempts, tt.status, tt.attempts)
+			}
+			if got := int(r.requests.Load()); got != tt.attempts {
+				t.Errorf("receiver got %d requests, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestStopCancelsWaitingRetries(t *testing.T) {
+	r := &receiver{failures: maxAttempts}
+	d, deliveries, id := pendingDelivery(t, r, 50*time.Millisecond)
+	d.Start(1)
+
+	waitFor(t, deliveries, id, func(delivery models.WebhookDelivery) bool { return delivery.Attempts == 1 })
+	d.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	delivery, err := deliveries.Get(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivery.Status != models.DeliveryStatusPending || r.requests.Load() != 1 {
+		t.Errorf("delivery %s after %d requests, want it left pending after 1", delivery.Status, r.requests.Load())
+	}
+}